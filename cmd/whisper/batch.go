@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	// Packages
+	goclient "github.com/mutablelogic/go-client"
+	segmenter "github.com/mutablelogic/go-media/pkg/segmenter"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	whisper "github.com/mutablelogic/go-whisper"
+	client "github.com/mutablelogic/go-whisper/pkg/client"
+	openai "github.com/mutablelogic/go-whisper/pkg/client/openai"
+	format "github.com/mutablelogic/go-whisper/pkg/format"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	task "github.com/mutablelogic/go-whisper/pkg/task"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type BatchCmd struct {
+	Model      string   `arg:"" help:"Model to use"`
+	Inputs     []string `arg:"" help:"Directories, audio files or glob patterns"`
+	Format     string   `flag:"" help:"Output format" default:"text" enum:"json,verbose_json,text,vtt,srt"`
+	OutputDir  string   `name:"output-dir" help:"Directory for outputs, instead of next to each input" type:"path"`
+	Manifest   string   `name:"manifest" help:"Manifest of transcribed and failed files, defaults to whisper-batch.json in the output directory or the current directory" type:"path"`
+	Jobs       int      `name:"jobs" help:"Number of files to transcribe in parallel, up to the number of contexts when transcribing locally" default:"2"`
+	Extensions []string `name:"ext" help:"Extensions of audio files in directories (comma-separated)" default:".wav,.mp3,.m4a,.flac,.ogg,.opus,.aac,.mp4,.mkv,.webm"`
+	Translate  bool     `flag:"" help:"Translate to English"`
+	Language   string   `flag:"language" help:"Language to transcribe"`
+	Remote     bool     `flag:"" help:"Use remote service (gowhisper, openai, elevenlabs) for transcription"`
+}
+
+// batchInput is an audio file and the path for its output
+type batchInput struct {
+	Path   string
+	Output string
+}
+
+// manifest records the outcome for each input, so that an interrupted run
+// can be resumed
+type manifest struct {
+	sync.Mutex
+	path  string
+	Files map[string]*manifestEntry `json:"files"`
+}
+
+type manifestEntry struct {
+	Output   string           `json:"output"`
+	Status   string           `json:"status"`
+	Error    string           `json:"error,omitempty"`
+	Duration schema.Timestamp `json:"duration,omitempty"`
+	Time     time.Time        `json:"time"`
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	manifestName = "whisper-batch.json"
+	statusDone   = "done"
+	statusFailed = "failed"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (cmd *BatchCmd) Run(app *Globals) error {
+	if cmd.Jobs < 1 {
+		return httpresponse.ErrBadRequest.Withf("invalid number of jobs %d", cmd.Jobs)
+	}
+
+	// Find the inputs
+	inputs, err := cmd.inputs()
+	if err != nil {
+		return err
+	} else if len(inputs) == 0 {
+		return httpresponse.ErrNotFound.With("no audio files")
+	}
+
+	// Read the manifest, and skip inputs which are done and have an output
+	path := cmd.Manifest
+	if path == "" {
+		path = filepath.Join(cmd.OutputDir, manifestName)
+	}
+	manifest, err := readManifest(path)
+	if err != nil {
+		return err
+	}
+	pending := make([]batchInput, 0, len(inputs))
+	for _, input := range inputs {
+		if manifest.done(input) {
+			continue
+		} else if _, err := os.Stat(input.Output); err == nil {
+			continue
+		}
+		pending = append(pending, input)
+	}
+	fmt.Fprintf(os.Stderr, "%d files, %d to transcribe\n", len(inputs), len(pending))
+
+	// Transcribe a file locally or with the remote service
+	transcribe := cmd.local
+	if cmd.Remote {
+		opts := []goclient.ClientOpt{goclient.OptTimeout(30 * time.Minute)}
+		if app.Debug {
+			opts = append(opts, goclient.OptTrace(os.Stderr, true))
+		}
+		remote, err := client.New(opts...)
+		if err != nil {
+			return err
+		}
+		transcribe = func(app *Globals, path string) (*schema.Transcription, error) {
+			return cmd.remote(app, remote, path)
+		}
+	} else if app.service.GetModelById(cmd.Model) == nil {
+		return httpresponse.ErrNotFound.With(cmd.Model)
+	}
+
+	// Transcribe the files in parallel, with no more local jobs than there
+	// are contexts in the pool
+	var wg sync.WaitGroup
+	var failed int
+	var n int
+	ch := make(chan batchInput)
+	for i := 0; i < cmd.jobs(app); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for input := range ch {
+				start := time.Now()
+				result, err := transcribe(app, input.Path)
+				if err == nil {
+					err = cmd.write(input.Output, result)
+				}
+
+				// Record the outcome, unless interrupted
+				if app.ctx.Err() != nil {
+					continue
+				}
+				manifest.Lock()
+				n++
+				if err != nil {
+					failed++
+					fmt.Fprintf(os.Stderr, "[%d/%d] %s: %v\n", n, len(pending), input.Path, err)
+				} else {
+					fmt.Fprintf(os.Stderr, "[%d/%d] %s: %v\n", n, len(pending), input.Output, time.Since(start).Truncate(time.Millisecond))
+				}
+				manifest.Unlock()
+				if err := manifest.set(input, result, err); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+		}()
+	}
+	for _, input := range pending {
+		select {
+		case ch <- input:
+		case <-app.ctx.Done():
+		}
+		if app.ctx.Err() != nil {
+			break
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	// Report interruption and failures
+	if err := app.ctx.Err(); err != nil {
+		return fmt.Errorf("interrupted, run again to resume: %w", err)
+	} else if failed > 0 {
+		return fmt.Errorf("%d of %d files failed, see %s", failed, len(pending), manifest.path)
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the number of files to transcribe in parallel. Local jobs are
+// limited to the number of contexts in the pool, as there is no context
+// for further jobs
+func (cmd *BatchCmd) jobs(app *Globals) int {
+	if cmd.Remote {
+		return cmd.Jobs
+	} else if max := app.service.MaxConcurrent(); cmd.Jobs > max {
+		fmt.Fprintf(os.Stderr, "%d jobs, limited to %d contexts\n", cmd.Jobs, max)
+		return max
+	}
+	return cmd.Jobs
+}
+
+// Return the inputs from directories, files and glob patterns, in order
+func (cmd *BatchCmd) inputs() ([]batchInput, error) {
+	var result []batchInput
+	seen := make(map[string]bool)
+	add := func(path, rel string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		result = append(result, batchInput{Path: path, Output: cmd.output(path, rel)})
+	}
+
+	for _, pattern := range cmd.Inputs {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, httpresponse.ErrBadRequest.Withf("%q: %v", pattern, err)
+		} else if len(paths) == 0 {
+			return nil, httpresponse.ErrNotFound.Withf("%q: no such file or directory", pattern)
+		}
+		for _, path := range paths {
+			if info, err := os.Stat(path); err != nil {
+				return nil, err
+			} else if !info.IsDir() {
+				add(path, filepath.Base(path))
+				continue
+			}
+
+			// Walk the directory for audio files, keeping the relative path for
+			// the output directory
+			root := path
+			if err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				} else if d.IsDir() || !slices.Contains(cmd.Extensions, strings.ToLower(filepath.Ext(path))) {
+					return nil
+				}
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					return err
+				}
+				add(path, rel)
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// Return the output path for an input, with the extension for the format
+func (cmd *BatchCmd) output(path, rel string) string {
+	ext := format.Ext(cmd.Format)
+	if cmd.OutputDir == "" {
+		return strings.TrimSuffix(path, filepath.Ext(path)) + ext
+	}
+	return filepath.Join(cmd.OutputDir, strings.TrimSuffix(rel, filepath.Ext(rel))+ext)
+}
+
+// Transcribe a file with a context from the pool
+func (cmd *BatchCmd) local(app *Globals, path string) (*schema.Transcription, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Create a segmenter
+	segmenter, err := segmenter.NewReader(f, whisper.SampleRate)
+	if err != nil {
+		return nil, err
+	}
+	defer segmenter.Close()
+
+	// Transcribe, and copy the result before the context is returned to the pool
+	var result schema.Transcription
+	if err := app.service.WithModelContext(app.ctx, app.service.GetModelById(cmd.Model), func(taskctx *task.Context) error {
+		taskctx.SetTranslate(cmd.Translate)
+		if cmd.Language != "" {
+			if err := taskctx.SetLanguage(cmd.Language); err != nil {
+				return err
+			}
+		}
+		if err := segmenter.DecodeFloat32(app.ctx, func(ts time.Duration, buf []float32) error {
+			return taskctx.Transcribe(app.ctx, ts, buf, nil)
+		}); err != nil {
+			return err
+		}
+		result = *taskctx.Result()
+		result.Segments = slices.Clone(result.Segments)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return &result, nil
+}
+
+// Transcribe a file with the remote service
+func (cmd *BatchCmd) remote(app *Globals, remote *client.Client, path string) (*schema.Transcription, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	params := []client.Opt{client.OptPath(filepath.Base(path)), client.OptFormat(openai.FormatVerboseJson), client.OptLanguage(cmd.Language)}
+	if cmd.Translate {
+		return remote.Translate(app.ctx, cmd.Model, f, params...)
+	} else {
+		return remote.Transcribe(app.ctx, cmd.Model, f, params...)
+	}
+}
+
+// Write the transcription to a file in the output format. The file is
+// replaced when complete, so an interrupted run does not leave a partial
+// output
+func (cmd *BatchCmd) write(path string, result *schema.Transcription) error {
+	var buf bytes.Buffer
+	if err := format.Write(&buf, cmd.Format, result); err != nil {
+		return err
+	}
+	return writeFile(path, &buf)
+}
+
+// Read the manifest, or return an empty manifest if it does not exist
+func readManifest(path string) (*manifest, error) {
+	m := &manifest{path: path, Files: make(map[string]*manifestEntry)}
+	if data, err := os.ReadFile(path); errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Return true if the input was transcribed and the output exists
+func (m *manifest) done(input batchInput) bool {
+	m.Lock()
+	defer m.Unlock()
+	if entry, exists := m.Files[input.Path]; !exists || entry.Status != statusDone {
+		return false
+	} else if _, err := os.Stat(entry.Output); err != nil {
+		return false
+	}
+	return true
+}
+
+// Record the outcome for an input, and save the manifest
+func (m *manifest) set(input batchInput, result *schema.Transcription, err error) error {
+	m.Lock()
+	defer m.Unlock()
+
+	entry := &manifestEntry{Output: input.Output, Status: statusDone, Time: time.Now()}
+	if err != nil {
+		entry.Status, entry.Error = statusFailed, err.Error()
+	} else if result != nil {
+		entry.Duration = result.Duration
+	}
+	m.Files[input.Path] = entry
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(m.path, bytes.NewReader(data))
+}
+
+// Write a file by replacing it with a temporary file, creating the
+// directory if needed
+func writeFile(path string, r io.Reader) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	} else if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	assert "github.com/stretchr/testify/assert"
+)
+
+// Create empty files in a directory
+func touch(t *testing.T, dir string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		} else if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_batch_001(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, dir, "a.wav", "b.MP3", "notes.txt", "sub/c.wav", "sub/deep/d.ogg")
+	join := func(paths ...string) string {
+		return filepath.Join(append([]string{dir}, paths...)...)
+	}
+
+	tests := []struct {
+		name    string
+		cmd     BatchCmd
+		inputs  []batchInput
+		err     bool
+		missing bool
+	}{
+		{"file", BatchCmd{Inputs: []string{join("a.wav")}, Format: "srt"}, []batchInput{
+			{join("a.wav"), join("a.srt")},
+		}, false, false},
+		{"directory", BatchCmd{Inputs: []string{join("sub")}, Format: "text", Extensions: []string{".wav", ".ogg"}}, []batchInput{
+			{join("sub", "c.wav"), join("sub", "c.txt")},
+			{join("sub", "deep", "d.ogg"), join("sub", "deep", "d.txt")},
+		}, false, false},
+		{"extension case", BatchCmd{Inputs: []string{dir}, Format: "vtt", Extensions: []string{".mp3"}}, []batchInput{
+			{join("b.MP3"), join("b.vtt")},
+		}, false, false},
+		{"glob", BatchCmd{Inputs: []string{join("*.wav"), join("a.wav")}, Format: "json"}, []batchInput{
+			{join("a.wav"), join("a.json")},
+		}, false, false},
+		{"output dir", BatchCmd{Inputs: []string{dir}, Format: "srt", Extensions: []string{".wav"}, OutputDir: join("out")}, []batchInput{
+			{join("a.wav"), join("out", "a.srt")},
+			{join("sub", "c.wav"), join("out", "sub", "c.srt")},
+		}, false, false},
+		{"missing", BatchCmd{Inputs: []string{join("missing.wav")}}, nil, true, true},
+		{"bad pattern", BatchCmd{Inputs: []string{join("[")}}, nil, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			inputs, err := test.cmd.inputs()
+			if test.err {
+				assert.Error(err)
+				assert.Equal(test.missing, strings.Contains(err.Error(), "no such file"))
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(test.inputs, inputs)
+		})
+	}
+}
+
+func Test_batch_002(t *testing.T) {
+	tests := []struct {
+		format, dir, path, rel, output string
+	}{
+		{"text", "", "audio/a.wav", "a.wav", "audio/a.txt"},
+		{"srt", "", "audio/a.b.mp3", "a.b.mp3", "audio/a.b.srt"},
+		{"verbose_json", "", "noext", "noext", "noext.json"},
+		{"vtt", "out", "audio/sub/a.wav", "sub/a.wav", "out/sub/a.vtt"},
+	}
+	for _, test := range tests {
+		t.Run(test.output, func(t *testing.T) {
+			cmd := BatchCmd{Format: test.format, OutputDir: test.dir}
+			assert.Equal(t, filepath.FromSlash(test.output), cmd.output(filepath.FromSlash(test.path), filepath.FromSlash(test.rel)))
+		})
+	}
+}
+
+func Test_batch_003(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, manifestName)
+
+	// A missing manifest is empty
+	m, err := readManifest(path)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.Empty(m.Files)
+
+	// Record a file which was transcribed, and a file which failed
+	done := batchInput{Path: filepath.Join(dir, "a.wav"), Output: filepath.Join(dir, "a.txt")}
+	failed := batchInput{Path: filepath.Join(dir, "b.wav"), Output: filepath.Join(dir, "b.txt")}
+	assert.NoError(m.set(done, &schema.Transcription{Duration: schema.Timestamp(5e9)}, nil))
+	assert.NoError(m.set(failed, nil, errors.New("decode error")))
+
+	// Inputs are done when they were transcribed and the output exists
+	tests := []struct {
+		name   string
+		input  batchInput
+		output bool
+		done   bool
+	}{
+		{"no output", done, false, false},
+		{"output", done, true, true},
+		{"failed", failed, true, false},
+		{"unknown", batchInput{Path: filepath.Join(dir, "c.wav"), Output: filepath.Join(dir, "c.txt")}, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Remove(test.input.Output)
+			if test.output {
+				touch(t, "", test.input.Output)
+			}
+			assert.Equal(test.done, m.done(test.input), test.name)
+		})
+	}
+
+	// The manifest is read back, so that a run is resumed
+	m, err = readManifest(path)
+	if assert.NoError(err) && assert.Len(m.Files, 2) {
+		assert.Equal(statusDone, m.Files[done.Path].Status)
+		assert.Equal(schema.Timestamp(5e9), m.Files[done.Path].Duration)
+		assert.Equal(statusFailed, m.Files[failed.Path].Status)
+		assert.Equal("decode error", m.Files[failed.Path].Error)
+		assert.True(m.done(done))
+	}
+
+	// A failed file is done when transcribed again
+	assert.NoError(m.set(failed, &schema.Transcription{}, nil))
+	assert.True(m.done(failed))
+
+	// A manifest which is not JSON is an error
+	assert.NoError(os.WriteFile(path, []byte("{"), 0o600))
+	_, err = readManifest(path)
+	assert.Error(err)
+}
+
+func Test_batch_004(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		path string
+		data string
+	}{
+		{"new", filepath.Join(dir, "a.txt"), "hello"},
+		{"replace", filepath.Join(dir, "a.txt"), "world"},
+		{"directory", filepath.Join(dir, "sub", "deep", "b.json"), `{"text":"hello"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.NoError(writeFile(test.path, strings.NewReader(test.data)))
+			data, err := os.ReadFile(test.path)
+			assert.NoError(err)
+			assert.Equal(test.data, string(data))
+
+			// No temporary files are left
+			entries, err := os.ReadDir(filepath.Dir(test.path))
+			assert.NoError(err)
+			for _, entry := range entries {
+				assert.False(strings.HasPrefix(entry.Name(), "."), entry.Name())
+			}
+		})
+	}
+
+	// The file is not replaced when the write fails
+	t.Run("error", func(t *testing.T) {
+		assert := assert.New(t)
+		path := filepath.Join(dir, "a.txt")
+		assert.Error(writeFile(path, errReader{}))
+		data, err := os.ReadFile(path)
+		assert.NoError(err)
+		assert.Equal("world", string(data))
+	})
+}
+
+// errReader returns an error when read
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
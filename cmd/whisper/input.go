@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	// Packages
+	ffmpeg "github.com/mutablelogic/go-media/pkg/ffmpeg"
+	segmenter "github.com/mutablelogic/go-media/pkg/segmenter"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// InputFlags set the ffmpeg input format of the audio. Container formats, such
+// as WAV, MP3 or Ogg, are usually detected from the data, and raw PCM audio
+// needs the format, sample rate and channels
+type InputFlags struct {
+	InputFormat   string `name:"input-format" help:"ffmpeg input format of the audio, such as s16le or f32le for raw PCM audio, or a container format (mp3, ogg, mpegts) which is not detected from the data"`
+	InputRate     int    `name:"input-rate" help:"Sample rate of raw PCM audio" default:"16000"`
+	InputChannels int    `name:"input-channels" help:"Number of interleaved channels of raw PCM audio" default:"1"`
+}
+
+// RangeFlags select the audio stream of a media file, and the part of the
+// audio which is transcribed. Timestamps are from the start of the audio
+type RangeFlags struct {
+	AudioStream         int           `name:"audio-stream" help:"Index of the audio stream to transcribe, or -1 for the best audio stream" default:"-1"`
+	AudioStreamLanguage string        `name:"audio-stream-language" help:"Language of the audio stream to transcribe from the stream metadata, such as eng"`
+	Start               time.Duration `name:"start" help:"Transcribe from this time in the audio, which the media is seeked to"`
+	End                 time.Duration `name:"end" help:"Transcribe up to this time in the audio"`
+}
+
+// stream hides the Seek method of a file, so that pipes and devices are
+// read without seeking
+type stream struct {
+	io.ReadCloser
+}
+
+// decodedStream is an audio stream decoded from media, which closes the
+// media when closed
+type decodedStream struct {
+	*audio.Stream
+	media    io.Closer
+	duration time.Duration
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// ffmpeg formats of raw PCM audio, which have no header
+	rawFormats = []string{"s8", "u8", "s16le", "s16be", "s24le", "s32le", "f32le", "f32be", "f64le", "alaw", "mulaw"}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the ffmpeg input format and its options, or an empty format when
+// the format is detected from the data. Raw PCM formats have the sample rate
+// and channel layout as options
+func (flags *InputFlags) format() (string, []string, error) {
+	format := strings.ToLower(strings.TrimSpace(flags.InputFormat))
+	if format == "" || !slices.Contains(rawFormats, format) {
+		return format, nil, nil
+	}
+	if flags.InputRate <= 0 {
+		return "", nil, httpresponse.ErrBadRequest.Withf("invalid input rate %d", flags.InputRate)
+	}
+	if flags.InputChannels <= 0 {
+		return "", nil, httpresponse.ErrBadRequest.Withf("invalid input channels %d", flags.InputChannels)
+	}
+	return format, []string{
+		fmt.Sprint("sample_rate=", flags.InputRate),
+		"ch_layout=" + channelLayout(flags.InputChannels),
+	}, nil
+}
+
+// Open audio from a file, a http or https URL, a named pipe or device, or
+// stdin when the path is "-". Raw PCM audio is decoded at a sample rate
+func (flags *InputFlags) open(ctx context.Context, path string, sampleRate int) (io.ReadCloser, error) {
+	r, err := openPath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the reader when the format is detected
+	format, opts, err := flags.format()
+	if err != nil {
+		r.Close()
+		return nil, err
+	} else if format == "" {
+		return r, nil
+	}
+
+	// Demux with the input format, and decode the best audio stream
+	reader, err := ffmpeg.NewReader(r, ffmpeg.OptInputFormat(format), ffmpeg.OptInputOpt(opts...))
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("%s: %w", format, err)
+	}
+	duration := reader.Duration()
+	if stream, err := audio.Decode(ctx, reader, audio.OptSampleRate(sampleRate)); err != nil {
+		r.Close()
+		return nil, err
+	} else {
+		return decodedStream{stream, r, duration}, nil
+	}
+}
+
+// Return the part of the audio which is transcribed
+func (flags *RangeFlags) rng() (audio.Range, error) {
+	return audio.NewRange(flags.Start, flags.End)
+}
+
+// Decode the selected audio stream of the media from the start of the range
+// as a WAV stream at a sample rate, or return the media when the best audio
+// stream is transcribed from the beginning. The audio stream is selected by
+// index, or by the language of the stream in the file or URL at path. The
+// media is closed on error
+func (flags *RangeFlags) decode(ctx context.Context, path string, r io.ReadCloser, sampleRate int) (io.ReadCloser, error) {
+	var opts []audio.Opt
+	if flags.AudioStreamLanguage != "" {
+		if flags.AudioStream >= 0 {
+			r.Close()
+			return nil, httpresponse.ErrBadRequest.With("--audio-stream and --audio-stream-language cannot both be set")
+		} else if path == "-" {
+			r.Close()
+			return nil, httpresponse.ErrBadRequest.With("the audio stream of stdin cannot be selected by language")
+		}
+		stream, err := audio.StreamLanguage(path, flags.AudioStreamLanguage)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		opts = append(opts, audio.OptStream(stream))
+	} else if flags.AudioStream >= 0 {
+		opts = append(opts, audio.OptStream(flags.AudioStream))
+	}
+	if flags.Start > 0 {
+		opts = append(opts, audio.OptStart(flags.Start))
+	}
+	if len(opts) == 0 {
+		return r, nil
+	}
+
+	// Decode the audio stream
+	reader, err := ffmpeg.NewReader(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	duration := reader.Duration()
+	if stream, err := audio.Decode(ctx, reader, append(opts, audio.OptSampleRate(sampleRate))...); err != nil {
+		r.Close()
+		return nil, err
+	} else {
+		return decodedStream{stream, r, duration}, nil
+	}
+}
+
+// Return the timestamp of the first sample of the audio, and the duration of
+// the media when the audio is decoded. Otherwise the audio is from the
+// beginning, and the duration is the duration of the segmenter
+func decoded(r io.Reader, segmenter *segmenter.Segmenter) (time.Duration, time.Duration) {
+	if s, ok := r.(decodedStream); ok {
+		return s.Start(), s.duration
+	}
+	return 0, segmenter.Duration()
+}
+
+// Close the decoded stream, and then the media
+func (s decodedStream) Close() error {
+	return errors.Join(s.Stream.Close(), s.media.Close())
+}
+
+// Return the ffmpeg channel layout for a number of channels, which is a
+// number of channels in an unspecified order when there is no common layout
+func channelLayout(channels int) string {
+	switch channels {
+	case 1:
+		return "mono"
+	case 2:
+		return "stereo"
+	default:
+		return fmt.Sprint(channels, "C")
+	}
+}
+
+// Return true if the path is a http or https URL
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// Open a file, or read from a http or https URL, or stdin when the path
+// is "-". Files which are not regular files are read without seeking
+func openPath(ctx context.Context, path string) (io.ReadCloser, error) {
+	switch {
+	case path == "-":
+		return stream{io.NopCloser(os.Stdin)}, nil
+	case isURL(path):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		} else if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, httpresponse.ErrGatewayError.Withf("%s: %s", path, resp.Status)
+		}
+		return resp.Body, nil
+	}
+
+	// Open the file
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err != nil {
+		f.Close()
+		return nil, err
+	} else if !info.Mode().IsRegular() {
+		return stream{f}, nil
+	}
+	return f, nil
+}
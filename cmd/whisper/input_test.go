@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	// Packages
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_input_001(t *testing.T) {
+	tests := []struct {
+		flags  InputFlags
+		format string
+		opts   []string
+		err    bool
+	}{
+		{InputFlags{}, "", nil, false},
+		{InputFlags{InputFormat: "mpegts"}, "mpegts", nil, false},
+		{InputFlags{InputFormat: " MP3 "}, "mp3", nil, false},
+		{InputFlags{InputFormat: "s16le", InputRate: 16000, InputChannels: 1}, "s16le", []string{"sample_rate=16000", "ch_layout=mono"}, false},
+		{InputFlags{InputFormat: "f32le", InputRate: 48000, InputChannels: 2}, "f32le", []string{"sample_rate=48000", "ch_layout=stereo"}, false},
+		{InputFlags{InputFormat: "s16le", InputRate: 8000, InputChannels: 4}, "s16le", []string{"sample_rate=8000", "ch_layout=4C"}, false},
+		{InputFlags{InputFormat: "s16le", InputRate: 0, InputChannels: 1}, "", nil, true},
+		{InputFlags{InputFormat: "f32le", InputRate: 16000, InputChannels: 0}, "", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.flags.InputFormat, func(t *testing.T) {
+			assert := assert.New(t)
+			format, opts, err := test.flags.format()
+			if test.err {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(test.format, format)
+			assert.Equal(test.opts, opts)
+		})
+	}
+}
+
+func Test_input_002(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(isURL("http://example.com/audio.mp3"))
+	assert.True(isURL("https://example.com/audio.mp3"))
+	assert.False(isURL("ftp://example.com/audio.mp3"))
+	assert.False(isURL("samples/jfk.wav"))
+	assert.False(isURL("-"))
+}
+
+func Test_input_003(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	// Regular files can be seeked
+	path := filepath.Join(dir, "audio.raw")
+	assert.NoError(os.WriteFile(path, []byte{0, 0}, 0o600))
+	r, err := openPath(context.Background(), path)
+	if assert.NoError(err) {
+		_, ok := r.(io.Seeker)
+		assert.True(ok)
+		assert.NoError(r.Close())
+	}
+
+	// Named pipes are read as a stream
+	fifo := filepath.Join(dir, "audio.fifo")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Skip("named pipes are not supported:", err)
+	}
+	go func() {
+		if w, err := os.OpenFile(fifo, os.O_WRONLY, 0); err == nil {
+			w.Close()
+		}
+	}()
+	r, err = openPath(context.Background(), fifo)
+	if assert.NoError(err) {
+		_, ok := r.(io.Seeker)
+		assert.False(ok)
+		assert.NoError(r.Close())
+	}
+
+	// Missing files are an error
+	_, err = openPath(context.Background(), filepath.Join(dir, "missing.wav"))
+	assert.Error(err)
+}
+
+func Test_input_004(t *testing.T) {
+	assert := assert.New(t)
+
+	// A second of raw stereo 16-bit samples at 8kHz
+	path := filepath.Join(t.TempDir(), "audio.raw")
+	assert.NoError(os.WriteFile(path, make([]byte, 8000*2*2), 0o600))
+
+	// Raw audio is decoded to a WAV stream of 16kHz mono samples
+	flags := InputFlags{InputFormat: "s16le", InputRate: 8000, InputChannels: 2}
+	r, err := flags.open(context.Background(), path, audio.SampleRate)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	assert.NoError(err)
+	if assert.Greater(len(data), 44) {
+		assert.Equal("RIFF", string(data[0:4]))
+		assert.Equal("WAVE", string(data[8:12]))
+		assert.Equal(uint16(1), binary.LittleEndian.Uint16(data[22:]))     // Channels
+		assert.Equal(uint32(16000), binary.LittleEndian.Uint32(data[24:])) // Sample rate
+	}
+}
+
+func Test_input_005(t *testing.T) {
+	assert := assert.New(t)
+
+	// The best audio stream from the beginning is not decoded
+	flags := RangeFlags{AudioStream: -1}
+	f, err := os.Open(SAMPLE_EN)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	r, err := flags.decode(context.Background(), SAMPLE_EN, f, audio.SampleRate)
+	if assert.NoError(err) {
+		assert.Equal(io.ReadCloser(f), r)
+		r.Close()
+	}
+
+	// The audio is decoded from the start, with the timestamp of the first
+	// sample at or a little before the start
+	flags.Start = 5 * time.Second
+	if f, err = os.Open(SAMPLE_EN); !assert.NoError(err) {
+		t.FailNow()
+	}
+	r, err = flags.decode(context.Background(), SAMPLE_EN, f, audio.SampleRate)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	defer r.Close()
+	if _, err := io.ReadFull(r, make([]byte, 44+16000*2)); assert.NoError(err) {
+		start, duration := decoded(r, nil)
+		assert.LessOrEqual(start, 5*time.Second)
+		assert.Greater(start, 4*time.Second)
+		assert.Greater(duration, 10*time.Second)
+	}
+
+	// Stdin cannot be selected by language
+	flags = RangeFlags{AudioStream: -1, AudioStreamLanguage: "eng"}
+	_, err = flags.decode(context.Background(), "-", io.NopCloser(os.Stdin), audio.SampleRate)
+	assert.Error(err)
+
+	// The sample has no stream language
+	if f, err = os.Open(SAMPLE_EN); !assert.NoError(err) {
+		t.FailNow()
+	}
+	_, err = flags.decode(context.Background(), SAMPLE_EN, f, audio.SampleRate)
+	assert.ErrorContains(err, "no audio stream with language")
+}
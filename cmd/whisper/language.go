@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	// Packages
+	goclient "github.com/mutablelogic/go-client"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
+	client "github.com/mutablelogic/go-whisper/pkg/client"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	task "github.com/mutablelogic/go-whisper/pkg/task"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type DetectLanguageCmd struct {
+	Model    string        `arg:"" help:"Multilingual model to use"`
+	Path     string        `arg:"" help:"Path or http(s) URL of audio file, or - for stdin"`
+	Duration time.Duration `flag:"" help:"Duration of audio to consider, from the start" default:"30s"`
+	TopK     uint64        `flag:"top-k" help:"Number of languages to return" default:"5"`
+	Allowed  []string      `flag:"allowed-languages" help:"Restrict the detected language to these languages (comma-separated)"`
+	Remote   bool          `flag:"" help:"Use remote service (gowhisper) for language detection"`
+	InputFlags
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (cmd *DetectLanguageCmd) Run(app *Globals) error {
+	var result *schema.LanguageDetection
+	var err error
+	if cmd.Remote {
+		result, err = cmd.run_remote(app)
+	} else {
+		result, err = cmd.run_local(app)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Print the result
+	fmt.Println(result)
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (cmd *DetectLanguageCmd) run_local(app *Globals) (*schema.LanguageDetection, error) {
+	// Get the model
+	model_ := app.service.GetModelById(cmd.Model)
+	if model_ == nil {
+		return nil, httpresponse.ErrNotFound.With(cmd.Model)
+	} else if cmd.Duration <= 0 {
+		return nil, httpresponse.ErrBadRequest.Withf("invalid duration %v", cmd.Duration)
+	}
+
+	// Open the audio file
+	f, err := cmd.open(app.ctx, cmd.Path, audio.SampleRate)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Read the start of the audio file
+	samples, err := audio.Head(app.ctx, f, cmd.Duration)
+	if err != nil {
+		return nil, err
+	} else if len(samples) == 0 {
+		return nil, httpresponse.ErrBadRequest.With("no audio samples")
+	}
+
+	// Detect the language
+	var result *schema.LanguageDetection
+	if err := app.service.WithModelContext(app.ctx, model_, func(taskctx *task.Context) error {
+		if !taskctx.CanTranslate() {
+			return httpresponse.ErrBadRequest.Withf("model %q is not multilingual", model_.Id)
+		}
+		if err := setAllowedLanguages(taskctx, cmd.Allowed); err != nil {
+			return err
+		}
+		result, err = taskctx.DetectLanguage(app.ctx, samples, int(cmd.TopK))
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return result, nil
+}
+
+func (cmd *DetectLanguageCmd) run_remote(app *Globals) (*schema.LanguageDetection, error) {
+	// Open the audio file
+	f, err := cmd.open(app.ctx, cmd.Path, audio.SampleRate)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Create a client for the whisper service
+	opts := []goclient.ClientOpt{
+		goclient.OptTimeout(5 * time.Minute), // Set a timeout for the request
+	}
+	if app.Debug {
+		opts = append(opts, goclient.OptTrace(os.Stderr, true))
+	}
+	remote, err := client.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect the language
+	return remote.DetectLanguage(app.ctx, cmd.Model, f, client.OptDuration(cmd.Duration), client.OptTopK(cmd.TopK), client.OptAllowedLanguages(cmd.Allowed...))
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	// Packages
+	ffmpeg "github.com/mutablelogic/go-media/pkg/ffmpeg"
+	segmenter "github.com/mutablelogic/go-media/pkg/segmenter"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	whisper "github.com/mutablelogic/go-whisper"
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	task "github.com/mutablelogic/go-whisper/pkg/task"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type ListenCmd struct {
+	Model        string        `arg:"" help:"Model to use"`
+	Input        string        `arg:"" optional:"" help:"Audio device, file or ffmpeg URL (such as rtsp://, an HLS playlist or an Icecast stream). Defaults to the default audio input"`
+	DeviceFormat string        `name:"device-format" help:"ffmpeg input format for the device, such as alsa, pulse or avfoundation"`
+	Window       time.Duration `name:"window" help:"Length of audio transcribed at once, up to 30s" default:"10s"`
+	Step         time.Duration `name:"step" help:"Interval between updates of the captions" default:"2s"`
+	Realtime     bool          `name:"realtime" help:"Read the input no faster than real time, for files"`
+	Language     string        `flag:"language" help:"Language to transcribe"`
+	Translate    bool          `flag:"translate" help:"Translate to English"`
+}
+
+// listenWindow is the audio which is transcribed at every step. Captions
+// which will not change are committed, and their audio is dropped from the
+// window
+type listenWindow struct {
+	size    time.Duration
+	step    time.Duration
+	start   time.Duration
+	samples []float32
+}
+
+// captions writes committed captions as lines, and rewrites the caption
+// which is still being transcribed in place on a terminal
+type captions struct {
+	w        io.Writer
+	terminal bool
+	width    int
+	visible  bool
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Maximum length of audio which whisper transcribes at once
+	maxWindow = 30 * time.Second
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (cmd *ListenCmd) Run(app *Globals) error {
+	// Get the model
+	model_ := app.service.GetModelById(cmd.Model)
+	if model_ == nil {
+		return httpresponse.ErrNotFound.With(cmd.Model)
+	}
+
+	// Check the window, which is transcribed at every step
+	if cmd.Window <= 0 || cmd.Window > maxWindow {
+		return httpresponse.ErrBadRequest.Withf("window must be between 0s and %v", maxWindow)
+	} else if cmd.Step <= 0 || cmd.Step >= cmd.Window {
+		return httpresponse.ErrBadRequest.With("step must be shorter than the window")
+	}
+
+	// Open the device, file or URL
+	input, err := cmd.open(app.ctx)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	// Read the audio in steps
+	segmenter, err := segmenter.NewReader(input, whisper.SampleRate, segmenter.WithSegmentSize(cmd.Step))
+	if err != nil {
+		return err
+	}
+	defer segmenter.Close()
+
+	// Transcribe the window at every step
+	out := newCaptions(os.Stdout)
+	defer out.Clear()
+	return app.service.WithModelContext(app.ctx, model_, func(taskctx *task.Context) error {
+		taskctx.SetTranslate(cmd.Translate)
+		if cmd.Language != "" {
+			if err := taskctx.SetLanguage(cmd.Language); err != nil {
+				return err
+			}
+		}
+
+		// Pending segments have not been committed
+		var pending []*schema.Segment
+		window := newListenWindow(cmd.Window, cmd.Step)
+		if err := segmenter.DecodeFloat32(app.ctx, func(ts time.Duration, buf []float32) error {
+			window.Add(ts, buf)
+
+			// Transcribe the window
+			var segments []*schema.Segment
+			start, data := window.Samples()
+			if err := taskctx.Transcribe(app.ctx, start, data, func(seg *schema.Segment) {
+				if strings.TrimSpace(seg.Text) != "" {
+					segments = append(segments, seg)
+				}
+			}); err != nil {
+				return err
+			}
+			taskctx.ClearResult()
+
+			// Commit the captions which will not change, and continue from
+			// the committed text
+			commit, tentative := window.Commit(segments)
+			if len(commit) > 0 {
+				out.Commit(commit)
+				if err := taskctx.SetPrompt(commit[len(commit)-1].Text); err != nil {
+					return err
+				}
+			}
+			pending = tentative
+			out.Tentative(pending)
+
+			// Continue listening
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// Commit the captions at the end of the input
+		out.Commit(pending)
+		return nil
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Open the input, and return a WAV stream of the best audio stream
+func (cmd *ListenCmd) open(ctx context.Context) (io.ReadCloser, error) {
+	url, format := cmd.Input, cmd.DeviceFormat
+	if url == "" {
+		if url, format = defaultDevice(format); url == "" {
+			return nil, httpresponse.ErrBadRequest.Withf("no default audio input on %s, set the input and --device-format", runtime.GOOS)
+		}
+	}
+
+	// Open the input
+	var fopts []ffmpeg.Opt
+	if format != "" {
+		fopts = append(fopts, ffmpeg.OptInputFormat(format))
+	}
+	reader, err := ffmpeg.Open(url, fopts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+
+	// Decode the best audio stream
+	var opts []audio.Opt
+	if cmd.Realtime {
+		opts = append(opts, audio.OptRealtime())
+	}
+	return audio.Decode(ctx, reader, opts...)
+}
+
+// Return the default audio input and its ffmpeg format for the operating
+// system, or an empty input when there is no default
+func defaultDevice(format string) (string, string) {
+	switch runtime.GOOS {
+	case "linux":
+		if format == "" || format == "pulse" {
+			return "default", "pulse"
+		}
+		return "default", format
+	case "darwin":
+		return ":default", "avfoundation"
+	default:
+		return "", format
+	}
+}
+
+// Return the number of samples in a duration
+func samples(d time.Duration) int {
+	return int(d * whisper.SampleRate / time.Second)
+}
+
+// Return the duration of a number of samples
+func duration(n int) time.Duration {
+	return time.Duration(n) * time.Second / whisper.SampleRate
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// WINDOW
+
+// Return a window of a size, which is transcribed at every step
+func newListenWindow(size, step time.Duration) *listenWindow {
+	return &listenWindow{
+		size:    size,
+		step:    step,
+		samples: make([]float32, 0, samples(min(size+step, maxWindow))),
+	}
+}
+
+// Add samples which start at a timestamp. The oldest samples are dropped
+// when the window is longer than whisper transcribes at once
+func (w *listenWindow) Add(ts time.Duration, buf []float32) {
+	if len(w.samples) == 0 {
+		w.start = ts
+	}
+	w.samples = append(w.samples, buf...)
+	if n := len(w.samples) - samples(maxWindow); n > 0 {
+		w.drop(n)
+	}
+}
+
+// Return the timestamp of the start of the window, and its samples
+func (w *listenWindow) Samples() (time.Duration, []float32) {
+	return w.start, w.samples
+}
+
+// Return the segments transcribed from the window which are committed, and
+// those which are pending. Until the window is full, all segments are
+// pending. Then all but the last segment are committed, as the last segment
+// may continue in the next step, and the audio up to the end of the
+// committed segments is dropped. Without segments, the window is silent and
+// all but the last step is dropped
+func (w *listenWindow) Commit(segments []*schema.Segment) ([]*schema.Segment, []*schema.Segment) {
+	if duration(len(w.samples)) < w.size {
+		return nil, segments
+	}
+	commit := segments
+	if len(segments) > 1 {
+		commit = segments[:len(segments)-1]
+	}
+	end := w.start + duration(len(w.samples)) - w.step
+	if len(commit) > 0 {
+		end = time.Duration(commit[len(commit)-1].End)
+	}
+	w.drop(min(max(samples(end-w.start), 0), len(w.samples)))
+	return commit, segments[len(commit):]
+}
+
+// Drop samples from the start of the window
+func (w *listenWindow) drop(n int) {
+	w.samples = append(w.samples[:0], w.samples[n:]...)
+	w.start += duration(n)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// CAPTIONS
+
+func newCaptions(f *os.File) *captions {
+	c := &captions{w: f, width: 80}
+	if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		c.terminal = true
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		c.width = columns
+	}
+	return c
+}
+
+// Write committed segments as lines
+func (c *captions) Commit(segments []*schema.Segment) {
+	c.Clear()
+	for _, seg := range segments {
+		fmt.Fprintf(c.w, "[%s] %s\n", timestamp(time.Duration(seg.Start)), strings.TrimSpace(seg.Text))
+	}
+}
+
+// Rewrite the caption which is still being transcribed, on a terminal. The
+// end of the caption is shown when it is wider than the terminal
+func (c *captions) Tentative(segments []*schema.Segment) {
+	if !c.terminal {
+		return
+	}
+	var text []string
+	for _, seg := range segments {
+		text = append(text, strings.TrimSpace(seg.Text))
+	}
+	line := strings.Join(text, " ")
+	for utf8.RuneCountInString(line) > c.width-1 {
+		_, n := utf8.DecodeRuneInString(line)
+		line = line[n:]
+	}
+	fmt.Fprint(c.w, "\r\033[K"+line)
+	c.visible = line != ""
+}
+
+// Clear the caption which is still being transcribed
+func (c *captions) Clear() {
+	if c.visible {
+		fmt.Fprint(c.w, "\r\033[K")
+		c.visible = false
+	}
+}
+
+// Format a timestamp as hours, minutes and seconds
+func timestamp(ts time.Duration) string {
+	ts = ts.Truncate(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(ts.Hours()), int(ts.Minutes())%60, int(ts.Seconds())%60)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	assert "github.com/stretchr/testify/assert"
+)
+
+// Return a segment between two timestamps
+func segment(text string, start, end time.Duration) *schema.Segment {
+	return &schema.Segment{Text: text, Start: schema.Timestamp(start), End: schema.Timestamp(end)}
+}
+
+// Add steps of silence to a window, from a timestamp
+func addSteps(w *listenWindow, ts time.Duration, n int) time.Duration {
+	for i := 0; i < n; i++ {
+		w.Add(ts, make([]float32, samples(w.step)))
+		ts += w.step
+	}
+	return ts
+}
+
+func Test_listen_001(t *testing.T) {
+	assert := assert.New(t)
+	w := newListenWindow(10*time.Second, 2*time.Second)
+
+	// Until the window is full, all segments are pending
+	ts := addSteps(w, 0, 4)
+	commit, pending := w.Commit([]*schema.Segment{segment("one", 0, 3*time.Second), segment("two", 3*time.Second, 8*time.Second)})
+	assert.Empty(commit)
+	assert.Len(pending, 2)
+	start, data := w.Samples()
+	assert.Equal(time.Duration(0), start)
+	assert.Equal(8*time.Second, duration(len(data)))
+
+	// When the window is full, all but the last segment are committed, and
+	// the window starts at the end of the committed segments
+	addSteps(w, ts, 1)
+	commit, pending = w.Commit([]*schema.Segment{
+		segment("one", 0, 3*time.Second),
+		segment("two", 3*time.Second, 6*time.Second),
+		segment("three", 6*time.Second, 10*time.Second),
+	})
+	if assert.Len(commit, 2) && assert.Len(pending, 1) {
+		assert.Equal("two", commit[1].Text)
+		assert.Equal("three", pending[0].Text)
+	}
+	start, data = w.Samples()
+	assert.Equal(6*time.Second, start)
+	assert.Equal(4*time.Second, duration(len(data)))
+}
+
+func Test_listen_002(t *testing.T) {
+	assert := assert.New(t)
+	w := newListenWindow(10*time.Second, 2*time.Second)
+
+	// A silent window drops all but the last step
+	ts := addSteps(w, 0, 5)
+	commit, pending := w.Commit(nil)
+	assert.Empty(commit)
+	assert.Empty(pending)
+	start, data := w.Samples()
+	assert.Equal(8*time.Second, start)
+	assert.Equal(2*time.Second, duration(len(data)))
+
+	// A single segment is committed
+	addSteps(w, ts, 4)
+	commit, pending = w.Commit([]*schema.Segment{segment("one", 9*time.Second, 17*time.Second)})
+	assert.Len(commit, 1)
+	assert.Empty(pending)
+	start, data = w.Samples()
+	assert.Equal(17*time.Second, start)
+	assert.Equal(1*time.Second, duration(len(data)))
+}
+
+func Test_listen_003(t *testing.T) {
+	assert := assert.New(t)
+	w := newListenWindow(10*time.Second, 2*time.Second)
+
+	// Segments which end before the window starts drop no audio, and the
+	// window does not grow beyond the maximum, dropping the oldest audio
+	ts := time.Duration(0)
+	for i := 0; i < 30; i++ {
+		ts = addSteps(w, ts, 1)
+		w.Commit([]*schema.Segment{segment("one", 0, 0), segment("two", 0, 0)})
+		start, data := w.Samples()
+		assert.LessOrEqual(duration(len(data)), maxWindow)
+		assert.Equal(ts, start+duration(len(data)))
+	}
+	start, data := w.Samples()
+	assert.Equal(maxWindow, duration(len(data)))
+	assert.Equal(ts-maxWindow, start)
+}
+
+func Test_listen_004(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("00:00:00", timestamp(999*time.Millisecond))
+	assert.Equal("00:01:05", timestamp(65*time.Second))
+	assert.Equal("01:02:03", timestamp(time.Hour+2*time.Minute+3*time.Second))
+}
@@ -2,21 +2,30 @@ package main
 
 import (
 	"context"
-	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	// Packages
 	kong "github.com/alecthomas/kong"
 	whisper "github.com/mutablelogic/go-whisper"
+	logging "github.com/mutablelogic/go-whisper/pkg/logging"
+	tracing "github.com/mutablelogic/go-whisper/pkg/tracing"
+	translate "github.com/mutablelogic/go-whisper/pkg/translate"
 )
 
 type Globals struct {
-	NoGPU bool   `name:"nogpu" help:"Disable GPU acceleration"`
-	Debug bool   `name:"debug" help:"Enable debug output"`
-	Dir   string `name:"dir" help:"Path to model store, uses ${WHISPER_DIR} " default:"${WHISPER_DIR}"`
+	NoGPU     bool   `name:"nogpu" help:"Disable GPU acceleration"`
+	Debug     bool   `name:"debug" help:"Enable debug output"`
+	LogFormat string `name:"log-format" env:"WHISPER_LOG_FORMAT" enum:"text,json,term" help:"Log format (text, json, term)" default:"term"`
+	Trace     string `name:"trace" env:"WHISPER_TRACE" help:"Export trace spans to an OpenTelemetry collector with OTLP/HTTP (such as http://localhost:4318), or write them to 'stderr' or a file"`
+	Dir       string `name:"dir" help:"Path to model store, uses ${WHISPER_DIR} " default:"${WHISPER_DIR}"`
+
+	// Text translation backend, for translation to languages other than English
+	TranslateUrl   string `name:"translate-url" env:"WHISPER_TRANSLATE_URL" help:"OpenAI chat-completions compatible endpoint for translation to languages other than English, or 'local' for a stand-in which does not translate"`
+	TranslateModel string `name:"translate-model" env:"WHISPER_TRANSLATE_MODEL" help:"Model for the translation endpoint" default:"gpt-4o-mini"`
 
 	// Writer, service and context
 	service *whisper.Whisper
@@ -25,14 +34,18 @@ type Globals struct {
 
 type CLI struct {
 	Globals
-	Transcribe TranscribeCmd `cmd:"transcribe" help:"Transcribe from file"`
-	Translate  TranslateCmd  `cmd:"translate" help:"Translate to english from file"`
-	Models     ModelsCmd     `cmd:"models" help:"List models"`
-	Download   DownloadCmd   `cmd:"download" help:"Download a model"`
-	Delete     DeleteCmd     `cmd:"delete" help:"Delete a model"`
-	Server     ServerCmd     `cmd:"server" help:"Run the whisper service"`
-	Version    VersionCmd    `cmd:"version" help:"Print version information"`
-	Segment    SegmentCmd    `cmd:"segment" help:"Segment an audio file"`
+	Transcribe TranscribeCmd     `cmd:"transcribe" help:"Transcribe from file"`
+	Translate  TranslateCmd      `cmd:"translate" help:"Translate to english from file"`
+	Batch      BatchCmd          `cmd:"batch" help:"Transcribe the audio files in directories or matching patterns"`
+	Language   DetectLanguageCmd `cmd:"detect-language" help:"Detect the spoken language of a file"`
+	Models     ModelsCmd         `cmd:"models" help:"List models"`
+	Download   DownloadCmd       `cmd:"download" help:"Download a model"`
+	Delete     DeleteCmd         `cmd:"delete" help:"Delete a model"`
+	Server     ServerCmd         `cmd:"server" help:"Run the whisper service"`
+	Version    VersionCmd        `cmd:"version" help:"Print version information"`
+	Segment    SegmentCmd        `cmd:"segment" help:"Segment an audio file"`
+	Listen     ListenCmd         `cmd:"listen" help:"Show live captions for audio from a device or stream"`
+	Subtitle   SubtitleCmd       `cmd:"subtitle" help:"Transcribe an audio stream of a video to subtitles"`
 }
 
 func main() {
@@ -57,12 +70,21 @@ func main() {
 	)
 
 	// Create a whisper server - set options
-	opts := []whisper.Opt{
-		whisper.OptLog(func(line string) {
-			if cli.Globals.Debug {
-				log.Println(line)
-			}
-		}),
+	var opts []whisper.Opt
+	logger, err := logging.New(os.Stderr, logging.Format(cli.Globals.LogFormat), cli.Globals.Debug)
+	if err != nil {
+		cmd.FatalIfErrorf(err)
+		return
+	} else {
+		opts = append(opts, whisper.OptLogger(logger))
+	}
+	tracer, err := newTracer(name, cli.Globals.Trace)
+	if err != nil {
+		cmd.FatalIfErrorf(err)
+		return
+	} else if tracer != nil {
+		opts = append(opts, whisper.OptTracer(tracer))
+		defer tracer.Close()
 	}
 	if cli.Globals.Debug {
 		opts = append(opts, whisper.OptDebug())
@@ -70,6 +92,12 @@ func main() {
 	if cli.Globals.NoGPU {
 		opts = append(opts, whisper.OptNoGPU())
 	}
+	if translator, err := newTranslator(cli.Globals.TranslateUrl, cli.Globals.TranslateModel); err != nil {
+		cmd.FatalIfErrorf(err)
+		return
+	} else if translator != nil {
+		opts = append(opts, whisper.OptTranslator(translator))
+	}
 
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(cli.Globals.Dir, 0755); err != nil {
@@ -89,11 +117,23 @@ func main() {
 
 	// Create a context
 	var cancel context.CancelFunc
-	cli.Globals.ctx, cancel = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGQUIT)
+	cli.Globals.ctx, cancel = signal.NotifyContext(tracing.WithTracer(context.Background(), tracer), os.Interrupt, syscall.SIGQUIT)
 	defer cancel()
 
-	// Run the command
-	if err := cmd.Run(&cli.Globals); err != nil {
+	// Run the command, within a span which is the parent of the spans for
+	// the command. The server records a span for each request instead, and
+	// logs with the logger
+	var span *tracing.Span
+	if cmd.Command() != "server" {
+		cli.Globals.ctx, span = tracing.Start(cli.Globals.ctx, cmd.Command())
+	}
+	err = cmd.Run(&cli.Globals, logger)
+	span.End(err)
+	if err != nil {
+		// Export the spans before exiting
+		if tracer != nil {
+			tracer.Close()
+		}
 		cmd.FatalIfErrorf(err)
 	}
 }
@@ -107,3 +147,41 @@ func dirEnvOrDefault(name string) string {
 	}
 	return os.TempDir()
 }
+
+// Return a text translation backend, or nil if the endpoint is empty. The API key
+// is read from WHISPER_TRANSLATE_KEY or OPENAI_API_KEY
+func newTranslator(endpoint, model string) (translate.Translator, error) {
+	switch endpoint {
+	case "":
+		return nil, nil
+	case "local":
+		return translate.NewLocal(), nil
+	}
+	key := os.Getenv("WHISPER_TRANSLATE_KEY")
+	if key == "" {
+		key = os.Getenv("OPENAI_API_KEY")
+	}
+	return translate.NewChat(endpoint, key, model)
+}
+
+// Return a tracer which exports spans to an OTLP/HTTP endpoint, or writes
+// them to stderr or a file, or nil if the endpoint is empty. Spans are not
+// written to stdout, which is used for the output of commands
+func newTracer(name, endpoint string) (*tracing.Tracer, error) {
+	var exporter tracing.Exporter
+	var err error
+	switch {
+	case endpoint == "":
+		return nil, nil
+	case endpoint == "stderr":
+		exporter, err = tracing.NewWriterExporter(os.Stderr)
+	case strings.Contains(endpoint, "://"):
+		exporter, err = tracing.NewOTLPExporter(endpoint)
+	default:
+		exporter, err = tracing.NewFileExporter(endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tracing.New(name, exporter)
+}
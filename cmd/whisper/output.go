@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	// Packages
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	format "github.com/mutablelogic/go-whisper/pkg/format"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// OutputFlags set where the transcription is written, and in which formats
+type OutputFlags struct {
+	Format        string   `flag:"" help:"Output format written to stdout" default:"text" enum:"json,verbose_json,text,txt,vtt,srt"`
+	OutputDir     string   `name:"output-dir" help:"Write the output to files in this directory, named after the input" type:"path"`
+	OutputFormats []string `name:"output-format" help:"Formats written to the output directory (json, verbose_json, txt, vtt, srt), repeatable or comma-separated. Defaults to --format"`
+}
+
+// outputs writes each segment to one or more formats
+type outputs struct {
+	writers []format.Writer
+	files   []*os.File
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return writers for the output formats. Without an output directory, one
+// format is written to stdout. Otherwise a file is created for each format,
+// named after the input
+func (flags *OutputFlags) outputs(input string) (*outputs, error) {
+	formats := flags.OutputFormats
+	if len(formats) == 0 {
+		formats = []string{flags.Format}
+	}
+
+	// Check the formats, and remove duplicates
+	names := make([]string, 0, len(formats))
+	for _, f := range formats {
+		if name := format.Name(f); name == "" {
+			return nil, httpresponse.ErrBadRequest.Withf("unsupported output format %q", f)
+		} else if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	// Write to stdout
+	o := new(outputs)
+	if flags.OutputDir == "" {
+		if len(names) > 1 {
+			return nil, httpresponse.ErrBadRequest.With("--output-dir is required for more than one output format")
+		}
+		w, err := format.New(os.Stdout, names[0])
+		if err != nil {
+			return nil, err
+		}
+		o.writers = append(o.writers, w)
+		return o, nil
+	}
+
+	// Write to files in the output directory. Formats with the same
+	// extension cannot both be written
+	if err := os.MkdirAll(flags.OutputDir, 0755); err != nil {
+		return nil, err
+	}
+	exts := make([]string, 0, len(names))
+	for _, name := range names {
+		ext := format.Ext(name)
+		if slices.Contains(exts, ext) {
+			o.close()
+			return nil, httpresponse.ErrBadRequest.Withf("output format %q conflicts with another format written to %q", name, ext)
+		} else {
+			exts = append(exts, ext)
+		}
+		f, err := os.Create(filepath.Join(flags.OutputDir, basename(input)+ext))
+		if err != nil {
+			o.close()
+			return nil, err
+		}
+		o.files = append(o.files, f)
+		if w, err := format.New(f, name); err != nil {
+			o.close()
+			return nil, err
+		} else {
+			o.writers = append(o.writers, w)
+		}
+	}
+
+	// Return success
+	return o, nil
+}
+
+// Write a segment to each format
+func (o *outputs) Write(seg *schema.Segment) error {
+	var result error
+	for _, w := range o.writers {
+		result = errors.Join(result, w.Write(seg))
+	}
+	return result
+}
+
+// Complete each document and close the files
+func (o *outputs) Close(t *schema.Transcription) error {
+	var result error
+	for _, w := range o.writers {
+		result = errors.Join(result, w.Close(t))
+	}
+	return errors.Join(result, o.close())
+}
+
+// Close the files
+func (o *outputs) close() error {
+	var result error
+	for _, f := range o.files {
+		result = errors.Join(result, f.Close())
+	}
+	o.files = nil
+	return result
+}
+
+// Return the name of the input without an extension, for naming output
+// files. Standard input is named "stdin"
+func basename(input string) string {
+	name := filepath.Base(input)
+	if input == "-" {
+		return "stdin"
+	} else if isURL(input) {
+		if u, err := url.Parse(input); err == nil {
+			name = path.Base(u.Path)
+		}
+	}
+	if name = strings.TrimSuffix(name, path.Ext(name)); name == "" || name == "." || name == "/" {
+		return "output"
+	}
+	return name
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_output_001(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		input, name string
+	}{
+		{"audio.wav", "audio"},
+		{"/path/to/talk.en.mp3", "talk.en"},
+		{"noext", "noext"},
+		{"-", "stdin"},
+		{"https://example.com/media/podcast.ogg?download=1", "podcast"},
+		{"https://example.com/", "output"},
+		{"/", "output"},
+		{".wav", "output"},
+	} {
+		assert.Equal(test.name, basename(test.input), test.input)
+	}
+}
+
+func Test_output_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Write several formats to the output directory, named after the input,
+	// with "txt" the same format as "text"
+	dir := filepath.Join(t.TempDir(), "out")
+	flags := OutputFlags{Format: "text", OutputDir: dir, OutputFormats: []string{"srt", "vtt", "txt", "text", "verbose_json"}}
+	o, err := flags.outputs("/path/to/talk.wav")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.Len(o.writers, 4)
+	assert.Len(o.files, 4)
+
+	segments := []*schema.Segment{
+		{Start: 0, End: schema.Timestamp(1500 * time.Millisecond), Text: " Hello"},
+		{Start: schema.Timestamp(2 * time.Second), End: schema.Timestamp(3 * time.Second), Text: " world"},
+	}
+	for _, seg := range segments {
+		assert.NoError(o.Write(seg))
+	}
+	assert.NoError(o.Close(&schema.Transcription{Task: "transcribe", Language: "en", Text: "Hello world", Segments: segments}))
+	assert.Nil(o.files)
+
+	entries, err := os.ReadDir(dir)
+	if assert.NoError(err) {
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.ElementsMatch([]string{"talk.srt", "talk.vtt", "talk.txt", "talk.json"}, names)
+	}
+
+	// Each file has the segments in its format
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(err, name)
+		return string(data)
+	}
+	assert.Contains(read("talk.srt"), "00:00:00,000 --> 00:00:01,500")
+	assert.True(strings.HasPrefix(read("talk.vtt"), "WEBVTT"))
+	assert.Contains(read("talk.vtt"), "00:00:02.000 --> 00:00:03.000")
+	assert.Contains(read("talk.txt"), "Hello")
+	assert.Contains(read("talk.txt"), "world")
+
+	var result schema.Transcription
+	if assert.NoError(json.Unmarshal([]byte(read("talk.json")), &result)) {
+		assert.Equal("en", result.Language)
+		assert.Len(result.Segments, 2)
+	}
+}
+
+func Test_output_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// One format is written to stdout, and defaults to --format
+	o, err := (&OutputFlags{Format: "srt"}).outputs("audio.wav")
+	if assert.NoError(err) {
+		assert.Len(o.writers, 1)
+		assert.Empty(o.files)
+	}
+
+	// Formats which cannot be written
+	dir := t.TempDir()
+	for _, flags := range []OutputFlags{
+		{Format: "docx"},
+		{Format: "text", OutputFormats: []string{"srt", "docx"}, OutputDir: dir},
+		{Format: "text", OutputFormats: []string{"srt", "vtt"}},
+		{Format: "text", OutputFormats: []string{"json", "verbose_json"}, OutputDir: dir},
+	} {
+		_, err := flags.outputs("audio.wav")
+		assert.Error(err, flags)
+	}
+}
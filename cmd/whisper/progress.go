@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// progressBar draws a progress bar on a single line of a terminal
+type progressBar struct {
+	sync.Mutex
+	w       io.Writer
+	width   int
+	percent int
+	visible bool
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newProgressBar(w io.Writer, width int) *progressBar {
+	return &progressBar{w: w, width: width, percent: -1}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Set the progress, between zero and one, and redraw the bar if the
+// percentage has changed
+func (p *progressBar) Set(v float64) {
+	p.Lock()
+	defer p.Unlock()
+
+	percent := int(min(max(v, 0), 1) * 100)
+	if percent == p.percent && p.visible {
+		return
+	}
+	p.percent = percent
+	p.draw()
+}
+
+// Clear the bar, so other output can be written to the terminal. The bar is
+// redrawn on the next call to Set or Redraw
+func (p *progressBar) Clear() {
+	p.Lock()
+	defer p.Unlock()
+	if p.visible {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.visible = false
+	}
+}
+
+// Redraw the bar after it has been cleared
+func (p *progressBar) Redraw() {
+	p.Lock()
+	defer p.Unlock()
+	if p.percent >= 0 {
+		p.draw()
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (p *progressBar) draw() {
+	n := p.width * p.percent / 100
+	fmt.Fprintf(p.w, "\r[%s%s] %3d%%", strings.Repeat("=", n), strings.Repeat(" ", p.width-n), p.percent)
+	p.visible = true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	// Packages
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_progress_001(t *testing.T) {
+	assert := assert.New(t)
+	var buf strings.Builder
+	bar := newProgressBar(&buf, 10)
+
+	// The bar is drawn when the percentage changes
+	bar.Set(0.5)
+	assert.Equal("\r[=====     ]  50%", buf.String())
+	bar.Set(0.501)
+	assert.Equal("\r[=====     ]  50%", buf.String())
+
+	// The progress is clamped between zero and one
+	buf.Reset()
+	bar.Set(2)
+	assert.Equal("\r[==========] 100%", buf.String())
+
+	// The bar is cleared, and redrawn
+	buf.Reset()
+	bar.Clear()
+	assert.Equal("\r\033[K", buf.String())
+	bar.Clear()
+	assert.Equal("\r\033[K", buf.String())
+	buf.Reset()
+	bar.Redraw()
+	assert.Equal("\r[==========] 100%", buf.String())
+}
+
+func Test_progress_002(t *testing.T) {
+	assert := assert.New(t)
+	var buf strings.Builder
+	bar := newProgressBar(&buf, 10)
+
+	// Nothing is drawn before the progress is set
+	bar.Redraw()
+	bar.Clear()
+	assert.Empty(buf.String())
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	// Packages
 	segmenter "github.com/mutablelogic/go-media/pkg/segmenter"
 	whisper "github.com/mutablelogic/go-whisper"
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
 	wav "github.com/mutablelogic/go-whisper/pkg/wav"
 )
 
@@ -16,17 +18,26 @@ import (
 // TYPES
 
 type SegmentCmd struct {
-	Path     string        `arg:"" help:"Path to audio file"`
-	Segments time.Duration `flag:"" help:"Segment size for reading audio file"`
-	Silence  time.Duration `flag:"" help:"Segment silence threshold"`
+	Path       string        `arg:"" help:"Path or http(s) URL of audio file, or - for stdin"`
+	Segments   time.Duration `flag:"" help:"Segment size for reading audio file"`
+	Silence    time.Duration `flag:"" help:"Segment silence threshold"`
+	Preprocess []string      `flag:"preprocess" help:"Filters applied to the audio, as for transcription, so the segments are the audio the model hears (comma-separated)"`
+	InputFlags
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
 func (cmd *SegmentCmd) Run(app *Globals) error {
+	// The filters applied to the audio
+	filters, err := audio.NewPreprocess(strings.Join(cmd.Preprocess, ","), whisper.SampleRate)
+	if err != nil {
+		return err
+	}
+	defer filters.Close()
+
 	// Open the audio file
-	f, err := os.Open(cmd.Path)
+	f, err := cmd.open(app.ctx, cmd.Path, filters.SampleRate())
 	if err != nil {
 		return err
 	}
@@ -41,15 +52,15 @@ func (cmd *SegmentCmd) Run(app *Globals) error {
 		opts = append(opts, segmenter.WithDefaultSilenceThreshold())
 		opts = append(opts, segmenter.WithSilenceSize(cmd.Silence))
 	}
-	segmenter, err := segmenter.NewReader(f, whisper.SampleRate, opts...)
+	segmenter, err := segmenter.NewReader(f, filters.SampleRate(), opts...)
 	if err != nil {
 		return err
 	}
 	defer segmenter.Close()
 
-	// Read samples and transcribe them
-	return segmenter.DecodeInt16(app.ctx, func(ts time.Duration, data []int16) error {
-		// Make a WAV file from the float32 samples
+	// Read and filter samples, and write them to WAV files
+	filter, flush := audio.Filtered(filters, func(ts time.Duration, data []int16) error {
+		// Make a WAV file from the filtered samples
 		r, err := wav.NewInt16(data, whisper.SampleRate, 1)
 		if err != nil {
 			return err
@@ -69,4 +80,8 @@ func (cmd *SegmentCmd) Run(app *Globals) error {
 		}
 		return nil
 	})
+	if err := segmenter.DecodeInt16(app.ctx, filter); err != nil {
+		return err
+	}
+	return flush()
 }
@@ -1,27 +1,178 @@
 package main
 
 import (
-	"log"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"time"
 
 	// Packages
 	"github.com/mutablelogic/go-server/pkg/httpserver"
 	"github.com/mutablelogic/go-whisper/pkg/api"
+	"github.com/mutablelogic/go-whisper/pkg/auth"
+	"github.com/mutablelogic/go-whisper/pkg/fetch"
+	"github.com/mutablelogic/go-whisper/pkg/limit"
+	"github.com/mutablelogic/go-whisper/pkg/tlsconfig"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 type ServerCmd struct {
 	Endpoint string `name:"endpoint" help:"Endpoint for the server" default:"/api/v1"`
 	Listen   string `name:"listen" help:"Listen address for the server" default:"localhost:8080"`
+	KeysFile string `name:"api-keys-file" env:"WHISPER_API_KEYS_FILE" help:"File with API keys, one per line as '<token> [<scope>,...] [<name>]' where scope is transcribe or admin"`
+	Keys     string `name:"api-keys" env:"WHISPER_API_KEYS" help:"API keys separated by semicolons, in the same form as the API keys file"`
+
+	// Limits for each API key, or client address when there are no keys
+	RateLimit  int     `name:"rate-limit" help:"Maximum transcription requests per minute for each API key or client address (0 for no limit)" default:"0"`
+	AudioQuota float64 `name:"audio-quota" help:"Maximum minutes of audio per day for each API key or client address (0 for no limit)" default:"0"`
+	MaxJobs    int     `name:"max-jobs" help:"Maximum concurrent transcription requests for each API key or client address (0 for no limit)" default:"0"`
+	UsageFile  string  `name:"usage-file" help:"File for usage counters, defaults to usage.json in the model store"`
+
+	// Limits for each request
+	MaxUploadSize int           `name:"max-upload-size" help:"Maximum size of uploaded audio in megabytes (0 for no limit)" default:"512"`
+	MaxDuration   time.Duration `name:"max-duration" help:"Maximum duration of audio to transcribe or translate (0 for no limit)" default:"0"`
+
+	// Audio read from a URL
+	FileURLHosts   []string      `name:"file-url-hosts" env:"WHISPER_FILE_URL_HOSTS" help:"Hosts which audio can be read from with the file_url parameter, as host, host:port or *.domain (comma-separated)"`
+	FileURLTimeout time.Duration `name:"file-url-timeout" help:"Timeout for connecting to a file_url host, and between reads" default:"30s"`
+
+	// TLS, which also enables HTTP/2
+	TLSCert       string        `name:"tls-cert" env:"WHISPER_TLS_CERT" help:"PEM-encoded TLS certificate file" type:"path"`
+	TLSKey        string        `name:"tls-key" env:"WHISPER_TLS_KEY" help:"PEM-encoded TLS private key file" type:"path"`
+	TLSSelfSigned bool          `name:"tls-self-signed" help:"Serve TLS with a generated self-signed certificate, for development"`
+	TLSClientCA   string        `name:"tls-client-ca" env:"WHISPER_TLS_CLIENT_CA" help:"PEM-encoded CA file, to require client certificates signed by the CA (mutual TLS)" type:"path"`
+	Insecure      bool          `name:"insecure" env:"WHISPER_INSECURE" help:"Serve without TLS on an address other than loopback, such as behind a TLS-terminating proxy"`
+	WriteTimeout  time.Duration `name:"write-timeout" help:"Maximum duration of a response, including streamed responses" default:"30m"`
 }
 
-func (cmd *ServerCmd) Run(ctx *Globals) error {
+func (cmd *ServerCmd) Run(ctx *Globals, logger *slog.Logger) error {
+	// Read the API keys
+	keys, err := cmd.keys()
+	if err != nil {
+		return err
+	} else if keys.Len() == 0 {
+		logger.Warn("No API keys, authentication is disabled")
+	} else {
+		logger.Info("API keys", "keys", keys.Len())
+	}
+
+	// Set the limits for each request
+	opts := []api.Opt{api.OptKeys(keys), api.OptMaxUploadSize(int64(cmd.MaxUploadSize) << 20), api.OptMaxDuration(cmd.MaxDuration)}
+
+	// Allow audio to be read from URLs on the hosts, with the same size limit
+	// as uploaded files
+	if len(cmd.FileURLHosts) > 0 {
+		fetcher, err := fetch.New(cmd.FileURLHosts, fetch.OptMaxSize(int64(cmd.MaxUploadSize)<<20), fetch.OptTimeout(cmd.FileURLTimeout))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, api.OptFetcher(fetcher))
+		logger.Info("Audio can be read from URLs", "hosts", fetcher.Hosts())
+	}
+
+	// Create the limiter, which records usage
+	if cmd.RateLimit != 0 || cmd.AudioQuota != 0 || cmd.MaxJobs != 0 {
+		path := cmd.UsageFile
+		if path == "" {
+			path = filepath.Join(ctx.Dir, "usage.json")
+		}
+		limiter, err := limit.New(limit.Limits{
+			RequestsPerMinute:  cmd.RateLimit,
+			AudioMinutesPerDay: cmd.AudioQuota,
+			MaxConcurrent:      cmd.MaxJobs,
+		}, path)
+		if err != nil {
+			return err
+		}
+		defer limiter.Close()
+		opts = append(opts, api.OptLimiter(limiter))
+		logger.Info("Usage file", "path", path)
+	}
+
+	// Create the TLS configuration
+	tlsConfig, err := cmd.tls()
+	if err != nil {
+		return err
+	} else if tlsConfig == nil {
+		logger.Warn("TLS is disabled")
+	} else {
+		logger.Info("TLS is enabled", "client_certificates", tlsConfig.ClientCAs != nil)
+	}
+
 	// Create a new HTTP server
-	log.Println("Listen address", cmd.Listen)
-	server, err := httpserver.New(cmd.Listen, api.RegisterEndpoints(cmd.Endpoint, ctx.service, nil, ctx.Debug), nil)
+	logger.Info("Listening", "address", cmd.Listen)
+	server, err := httpserver.New(cmd.Listen, api.RegisterEndpoints(cmd.Endpoint, ctx.service, nil, ctx.Debug, opts...), tlsConfig, httpserver.WithWriteTimeout(cmd.WriteTimeout))
 	if err != nil {
 		return err
 	}
 
 	// Run the server until CTRL+C
-	log.Println("Press CTRL+C to exit")
+	logger.Info("Press CTRL+C to exit")
 	return server.Run(ctx.ctx)
 }
+
+// Return the API keys from the file and the environment
+func (cmd *ServerCmd) keys() (*auth.Keys, error) {
+	keys := auth.NewKeys()
+	if cmd.KeysFile != "" {
+		if err := keys.ReadFile(cmd.KeysFile); err != nil {
+			return nil, err
+		}
+	}
+	if cmd.Keys != "" {
+		if err := keys.Parse(cmd.Keys); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// Return the TLS configuration, or nil if TLS is disabled. TLS is disabled
+// when listening on a loopback address without a certificate, or with
+// the --insecure flag, and is otherwise required
+func (cmd *ServerCmd) tls() (*tls.Config, error) {
+	if cmd.TLSSelfSigned && (cmd.TLSCert != "" || cmd.TLSKey != "") {
+		return nil, ErrBadParameter.With("--tls-self-signed cannot be used with --tls-cert or --tls-key")
+	} else if cmd.Insecure && (cmd.TLSCert != "" || cmd.TLSKey != "" || cmd.TLSSelfSigned) {
+		return nil, ErrBadParameter.With("--insecure cannot be used with --tls-cert, --tls-key or --tls-self-signed")
+	}
+
+	var opts []tlsconfig.Opt
+	switch {
+	case cmd.TLSCert != "" || cmd.TLSKey != "":
+		opts = append(opts, tlsconfig.OptCertificate(cmd.TLSCert, cmd.TLSKey))
+	case cmd.TLSSelfSigned:
+		host, _, err := net.SplitHostPort(cmd.Listen)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, tlsconfig.OptSelfSigned(host))
+	case cmd.TLSClientCA != "":
+		return nil, ErrBadParameter.With("--tls-client-ca requires --tls-cert and --tls-key or --tls-self-signed")
+	case cmd.Insecure || isLoopback(cmd.Listen):
+		return nil, nil
+	default:
+		return nil, ErrBadParameter.Withf("TLS is required to listen on %q, use --tls-cert and --tls-key, --tls-self-signed or --insecure", cmd.Listen)
+	}
+	if cmd.TLSClientCA != "" {
+		opts = append(opts, tlsconfig.OptClientCA(cmd.TLSClientCA))
+	}
+	return tlsconfig.New(opts...)
+}
+
+// Return true if the listen address is a loopback address, such as
+// localhost:8080 or 127.0.0.1:8080
+func isLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		host = listen
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Packages
+	media "github.com/mutablelogic/go-media"
+	ffmpeg "github.com/mutablelogic/go-media/pkg/ffmpeg"
+	segmenter "github.com/mutablelogic/go-media/pkg/segmenter"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	whisper "github.com/mutablelogic/go-whisper"
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
+	format "github.com/mutablelogic/go-whisper/pkg/format"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	subtitle "github.com/mutablelogic/go-whisper/pkg/subtitle"
+	task "github.com/mutablelogic/go-whisper/pkg/task"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// SubtitleCmd transcribes an audio stream of a video, and writes subtitles
+// alongside it. The streams of a video are then copied to a new video with
+// the subtitles added as a subtitle stream
+type SubtitleCmd struct {
+	Model          string `arg:"" help:"Model to use"`
+	Path           string `arg:"" help:"Video or audio file"`
+	Output         string `name:"output" help:"Subtitle file, defaults to the input with the subtitle format as extension" type:"path"`
+	Format         string `flag:"" help:"Subtitle format" default:"srt" enum:"srt,vtt"`
+	Stream         int    `name:"stream" help:"Index of the audio stream to transcribe, or -1 for the best audio stream" default:"-1"`
+	StreamLanguage string `name:"stream-language" help:"Language of the audio stream to transcribe from the stream metadata, such as eng"`
+	Remux          string `name:"remux" help:"Video with the subtitles added as a subtitle stream, defaults to the input with .subtitled before the extension when the input is a video" type:"path"`
+	NoRemux        bool   `name:"no-remux" help:"Write the subtitle file only"`
+	Language       string `flag:"language" help:"Language to transcribe"`
+	Translate      bool   `flag:"translate" help:"Translate to English"`
+	Progress       bool   `flag:"progress" help:"Show a progress bar on stderr"`
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (cmd *SubtitleCmd) Run(app *Globals) error {
+	// Get the model
+	model_ := app.service.GetModelById(cmd.Model)
+	if model_ == nil {
+		return httpresponse.ErrNotFound.With(cmd.Model)
+	}
+
+	// The subtitle file
+	output := cmd.Output
+	if output == "" {
+		output = strings.TrimSuffix(cmd.Path, filepath.Ext(cmd.Path)) + format.Ext(cmd.Format)
+	}
+
+	// Select the audio stream by index or by language
+	var opts []audio.Opt
+	if cmd.StreamLanguage != "" {
+		if cmd.Stream >= 0 {
+			return httpresponse.ErrBadRequest.With("--stream and --stream-language cannot both be set")
+		}
+		stream, err := audio.StreamLanguage(cmd.Path, cmd.StreamLanguage)
+		if err != nil {
+			return fmt.Errorf("%s: %w", cmd.Path, err)
+		}
+		opts = append(opts, audio.OptStream(stream))
+	} else if cmd.Stream >= 0 {
+		opts = append(opts, audio.OptStream(cmd.Stream))
+	}
+
+	// Open the media, and decode the audio stream
+	reader, err := ffmpeg.Open(cmd.Path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Path, err)
+	}
+	total := reader.Duration()
+	remux := cmd.Remux
+	if remux == "" && len(reader.Streams(media.VIDEO)) > 0 {
+		remux = remuxPath(cmd.Path)
+	}
+	stream, err := audio.Decode(app.ctx, reader, opts...)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	// Create a segmenter
+	segmenter, err := segmenter.NewReader(stream, whisper.SampleRate)
+	if err != nil {
+		return err
+	}
+	defer segmenter.Close()
+
+	// Write the subtitles to a buffer, and then to the file
+	var doc bytes.Buffer
+	out, err := format.New(&doc, cmd.Format)
+	if err != nil {
+		return err
+	}
+
+	// Transcribe the audio stream
+	if err := app.service.WithModelContext(app.ctx, model_, func(taskctx *task.Context) error {
+		taskctx.SetTranslate(cmd.Translate)
+		if cmd.Language != "" {
+			if err := taskctx.SetLanguage(cmd.Language); err != nil {
+				return err
+			}
+		}
+
+		// Show progress across the audio stream
+		if cmd.Progress && total > 0 {
+			progress := newProgressBar(os.Stderr, 40)
+			defer progress.Clear()
+			taskctx.SetProgress(total, progress.Set)
+		}
+
+		return segmenter.DecodeFloat32(app.ctx, func(ts time.Duration, buf []float32) error {
+			return taskctx.Transcribe(app.ctx, ts, buf, func(seg *schema.Segment) {
+				out.Write(seg)
+			})
+		})
+	}); err != nil {
+		return err
+	} else if err := out.Close(nil); err != nil {
+		return err
+	} else if err := writeFile(output, &doc); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Wrote", output)
+
+	// Copy the streams of the video, and add the subtitles as a subtitle stream
+	if remux == "" || cmd.NoRemux {
+		return nil
+	}
+	remuxOpts := []subtitle.Opt{subtitle.OptCodec(subtitleCodec(remux))}
+	if language := cmd.subtitleLanguage(); language != "" {
+		remuxOpts = append(remuxOpts, subtitle.OptLanguage(language))
+	}
+	if err := subtitle.Remux(app.ctx, remux, cmd.Path, output, remuxOpts...); err != nil {
+		return fmt.Errorf("%s: %w", remux, err)
+	}
+	fmt.Fprintln(os.Stderr, "Wrote", remux)
+
+	// Return success
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the language of the subtitle stream, which is English when
+// translating, or the language of the audio stream
+func (cmd *SubtitleCmd) subtitleLanguage() string {
+	if cmd.Translate {
+		return "eng"
+	}
+	return cmd.StreamLanguage
+}
+
+// Return the subtitle codec for the container of a video
+func subtitleCodec(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".m4v", ".mov":
+		return subtitle.CodecMovText
+	case ".webm":
+		return "webvtt"
+	default:
+		return "srt"
+	}
+}
+
+// Return the path of a video with subtitles
+func remuxPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".subtitled" + ext
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	// Packages
+	ff "github.com/mutablelogic/go-media/sys/ffmpeg71"
+	whisper "github.com/mutablelogic/go-whisper"
+	assert "github.com/stretchr/testify/assert"
+)
+
+const (
+	MODEL_TINY = "ggml-tiny.en-q5_1.bin"
+	SAMPLE_EN  = "../../samples/jfk.wav"
+)
+
+func Test_subtitle_001(t *testing.T) {
+	tests := []struct {
+		path, codec, remux string
+	}{
+		{"video.mp4", "mov_text", "video.subtitled.mp4"},
+		{"dir/video.M4V", "mov_text", "dir/video.subtitled.M4V"},
+		{"video.mov", "mov_text", "video.subtitled.mov"},
+		{"video.webm", "webvtt", "video.subtitled.webm"},
+		{"video.mkv", "srt", "video.subtitled.mkv"},
+		{"a.b.avi", "srt", "a.b.subtitled.avi"},
+		{"video", "srt", "video.subtitled"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.Equal(test.codec, subtitleCodec(test.path))
+			assert.Equal(test.remux, remuxPath(test.path))
+		})
+	}
+}
+
+func Test_subtitle_002(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("", (&SubtitleCmd{}).subtitleLanguage())
+	assert.Equal("deu", (&SubtitleCmd{StreamLanguage: "deu"}).subtitleLanguage())
+	assert.Equal("eng", (&SubtitleCmd{StreamLanguage: "deu", Translate: true}).subtitleLanguage())
+}
+
+func Test_subtitle_003(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	service, err := whisper.New(dir)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	defer service.Close()
+	if _, err := service.DownloadModel(context.Background(), MODEL_TINY, nil); err != nil {
+		t.Skip("model not downloaded:", err)
+	}
+	app := &Globals{service: service, ctx: context.Background()}
+
+	// Transcribe the audio to subtitles, and remux them into a new file
+	cmd := SubtitleCmd{
+		Model:  MODEL_TINY,
+		Path:   SAMPLE_EN,
+		Output: filepath.Join(dir, "jfk.srt"),
+		Format: "srt",
+		Stream: -1,
+		Remux:  filepath.Join(dir, "jfk.mkv"),
+	}
+	if !assert.NoError(cmd.Run(app)) {
+		t.FailNow()
+	}
+	data, err := os.ReadFile(cmd.Output)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(string(data), "1\n00:00:00,000 --> "), string(data))
+	assert.Contains(strings.ToLower(string(data)), "country")
+
+	// The audio stream is copied, and the subtitles are a subtitle stream
+	assert.Equal("pcm_s16le", streamCodec(t, cmd.Remux, 0))
+	assert.Equal("subrip", streamCodec(t, cmd.Remux, 1))
+
+	// The audio stream is selected by language, which the sample does not have
+	cmd.StreamLanguage = "eng"
+	assert.ErrorContains(cmd.Run(app), "no audio stream with language")
+
+	// The audio stream cannot be selected by index and language
+	cmd.Stream = 0
+	assert.Error(cmd.Run(app))
+
+	// Subtitles in MP4 and QuickTime containers are mov_text
+	cmd.StreamLanguage, cmd.Stream = "", -1
+	cmd.Path, cmd.Remux, cmd.NoRemux = cmd.Remux, filepath.Join(dir, "jfk.mov"), false
+	if assert.NoError(cmd.Run(app)) {
+		assert.Equal("mov_text", streamCodec(t, cmd.Remux, 1))
+	}
+
+	// The subtitle file is written without remuxing
+	cmd.Remux, cmd.NoRemux = filepath.Join(dir, "none.mkv"), true
+	assert.NoError(cmd.Run(app))
+	_, err = os.Stat(cmd.Remux)
+	assert.True(os.IsNotExist(err))
+}
+
+// Return the codec name of a stream in a file
+func streamCodec(t *testing.T, path string, stream int) string {
+	t.Helper()
+	input, err := ff.AVFormat_open_url(path, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ff.AVFormat_close_input(input)
+	if err := ff.AVFormat_find_stream_info(input, nil); err != nil {
+		t.Fatal(err)
+	} else if stream >= int(input.NumStreams()) {
+		t.Fatalf("%s: stream %d not found", path, stream)
+	}
+	return input.Stream(stream).CodecPar().CodecID().Name()
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	// Packages
@@ -12,10 +15,14 @@ import (
 	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
 	types "github.com/mutablelogic/go-server/pkg/types"
 	whisper "github.com/mutablelogic/go-whisper"
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
 	client "github.com/mutablelogic/go-whisper/pkg/client"
 	openai "github.com/mutablelogic/go-whisper/pkg/client/openai"
+	format "github.com/mutablelogic/go-whisper/pkg/format"
 	schema "github.com/mutablelogic/go-whisper/pkg/schema"
 	task "github.com/mutablelogic/go-whisper/pkg/task"
+	translate "github.com/mutablelogic/go-whisper/pkg/translate"
+	vocabulary "github.com/mutablelogic/go-whisper/pkg/vocabulary"
 	wav "github.com/mutablelogic/go-whisper/pkg/wav"
 )
 
@@ -24,8 +31,7 @@ import (
 
 type TranslateCmd struct {
 	Model       string        `arg:"" help:"Model to use"`
-	Path        string        `arg:"" help:"Path to audio file"`
-	Format      string        `flag:"" help:"Output format" default:"text" enum:"json,verbose_json,text,vtt,srt"`
+	Path        string        `arg:"" help:"Path or http(s) URL of audio file, or - for stdin"`
 	Segments    time.Duration `flag:"" help:"Segment size for reading audio file"`
 	Silence     time.Duration `flag:"" help:"Segment silence threshold"`
 	Remote      bool          `flag:"" help:"Use remote service (gowhisper, openai, elevenlabs) for translation or transcription"`
@@ -33,7 +39,17 @@ type TranslateCmd struct {
 	Diarize     bool          `flag:"" help:"Diarize the transcription"`
 	Stream      bool          `flag:"" help:"Stream the transcription results"`
 	Language    string        `flag:"language" help:"Language to transcribe"`
+	Allowed     []string      `flag:"allowed-languages" help:"Restrict the auto-detected language to these languages (comma-separated)"`
+	PerSegment  bool          `flag:"language-per-segment" help:"Detect the language for each segment, for audio which switches language"`
+	Vocabulary  []string      `flag:"vocabulary" help:"Domain terms to bias the transcription towards, as term or term:weight (comma-separated)"`
+	Target      string        `flag:"target-language" help:"Translate to this language, using the text translation backend for languages other than English"`
 	Prompt      *string       `flag:"prompt" help:"Prompt to guide the model's style or continue a previous audio segment"`
+	Progress    bool          `flag:"progress" help:"Show a progress bar on stderr"`
+	Preprocess  []string      `flag:"preprocess" help:"Filters applied to the audio before transcription (highpass, gain, gate, afftdn, arnndn, loudnorm, resample), as filter or filter=value (comma-separated)"`
+	ServerFetch bool          `flag:"server-fetch" help:"With --remote and an http(s) URL, the whisper server reads the audio from the URL rather than it being uploaded"`
+	InputFlags
+	RangeFlags
+	OutputFlags
 }
 
 type TranscribeCmd struct {
@@ -66,8 +82,33 @@ func (cmd *TranslateCmd) run_local(app *Globals, translate bool) error {
 		return httpresponse.ErrNotFound.With(cmd.Model)
 	}
 
-	// Open the audio file
-	f, err := os.Open(cmd.Path)
+	// Whisper translates to English, other languages are translated
+	// after transcription
+	target, err := cmd.targetLanguage(app, translate)
+	if err != nil {
+		return err
+	} else if target != "" {
+		translate = false
+	}
+
+	// The part of the audio to transcribe, and the filters applied to it
+	rng, err := cmd.rng()
+	if err != nil {
+		return err
+	}
+	filters, err := audio.NewPreprocess(strings.Join(cmd.Preprocess, ","), whisper.SampleRate)
+	if err != nil {
+		return err
+	}
+	defer filters.Close()
+
+	// Open the audio file, and decode the audio stream at the sample rate
+	// of the filters
+	f, err := cmd.open(app.ctx, cmd.Path, filters.SampleRate())
+	if err != nil {
+		return err
+	}
+	f, err = cmd.decode(app.ctx, cmd.Path, f, filters.SampleRate())
 	if err != nil {
 		return err
 	}
@@ -78,17 +119,28 @@ func (cmd *TranslateCmd) run_local(app *Globals, translate bool) error {
 	if cmd.Segments > 0 {
 		opts = append(opts, segmenter.WithSegmentSize(cmd.Segments))
 	}
-	segmenter, err := segmenter.NewReader(f, whisper.SampleRate, opts...)
+	segmenter, err := segmenter.NewReader(f, filters.SampleRate(), opts...)
 	if err != nil {
 		return err
 	}
 	defer segmenter.Close()
 
-	// Perform the transcription
-	return app.service.WithModel(model_, func(taskctx *task.Context) error {
+	// Create the output writers
+	out, err := cmd.outputs(cmd.Path)
+	if err != nil {
+		return err
+	}
+	defer out.close()
+
+	// Perform the transcription, which is cancelled on the first error
+	// translating segments
+	ctx, cancel := context.WithCancelCause(app.ctx)
+	defer cancel(nil)
+	return app.service.WithModelContext(ctx, model_, func(taskctx *task.Context) error {
 		// Transcribe or Translate
 		taskctx.SetTranslate(translate)
 		taskctx.SetDiarize(cmd.Diarize)
+		taskctx.SetLanguagePerSegment(cmd.PerSegment)
 
 		// Set language
 		if cmd.Language != "" {
@@ -96,6 +148,16 @@ func (cmd *TranslateCmd) run_local(app *Globals, translate bool) error {
 				return err
 			}
 		}
+		// Restrict auto-detected language
+		if err := setAllowedLanguages(taskctx, cmd.Allowed); err != nil {
+			return err
+		}
+		// Set vocabulary
+		if vocabulary, err := vocabulary.Parse(cmd.Vocabulary...); err != nil {
+			return err
+		} else if err := taskctx.SetVocabulary(vocabulary); err != nil {
+			return err
+		}
 		// Set temperature
 		if cmd.Temperature != nil {
 			if err := taskctx.SetTemperature(*cmd.Temperature); err != nil {
@@ -109,44 +171,92 @@ func (cmd *TranslateCmd) run_local(app *Globals, translate bool) error {
 			}
 		}
 
-		// Read samples and transcribe them
-		if err := segmenter.DecodeFloat32(app.ctx, func(ts time.Duration, buf []float32) error {
+		// Show progress across all segments, to the end of the range
+		var progress *progressBar
+		if cmd.Progress {
+			progress = newProgressBar(os.Stderr, 40)
+			defer progress.Clear()
+			_, duration := decoded(f, segmenter)
+			taskctx.SetProgress(rng.Length(duration), progress.Set)
+		}
+
+		// Write segments, clearing the progress bar while they are written
+		write := func(segment *schema.Segment) error {
+			if progress != nil {
+				progress.Clear()
+				defer progress.Redraw()
+			}
+			return out.Write(segment)
+		}
+
+		// Translate segments to the target language in the background, and
+		// write them in order
+		var translated []*schema.Segment
+		translator := newSegmentTranslator(ctx, app.service, target, cancel, func(segment *schema.Segment) error {
+			translated = append(translated, segment)
+			return write(segment)
+		})
+
+		// Read and filter samples, and transcribe those within the range,
+		// which whisper skips to. Timestamps are offset by the start of
+		// decoded audio
+		if err := taskctx.SetRange(rng.Start, rng.End); err != nil {
+			return err
+		}
+		filter, flush := audio.Filtered(filters, func(ts time.Duration, buf []float32) error {
+			_, clipped, done := audio.Clip(rng, ts, buf, whisper.SampleRate)
+			if len(clipped) == 0 {
+				return audio.EndOfRange(done)
+			}
+
 			// Perform the transcription, return any errors
-			return taskctx.Transcribe(app.ctx, ts, buf, func(segment *schema.Segment) {
-				var buf bytes.Buffer
-				switch cmd.Format {
-				case "json", "verbose_json":
-					fmt.Println(segment)
-				case "srt":
-					task.WriteSegmentSrt(&buf, segment)
-					fmt.Println(buf.String())
-				case "vtt":
-					if segment.Id == 0 {
-						fmt.Println("WEBVTT" + "\n")
+			err := taskctx.Transcribe(ctx, ts, buf, func(segment *schema.Segment) {
+				if translator != nil {
+					language := taskctx.Language()
+					if segment.Language != "" {
+						language = segment.Language
 					}
-					task.WriteSegmentVtt(&buf, segment)
-					fmt.Println(buf.String())
-				case "text":
-					task.WriteSegmentText(&buf, segment)
-					fmt.Println(buf.String())
+					translator.Add(language, segment)
+				} else if err := write(segment); err != nil {
+					fmt.Fprintln(os.Stderr, err)
 				}
 			})
-		}); err != nil {
+			if err != nil {
+				return err
+			}
+			return audio.EndOfRange(done)
+		})
+		err := segmenter.DecodeFloat32(ctx, func(ts time.Duration, buf []float32) error {
+			start, _ := decoded(f, segmenter)
+			return filter(ts+start, buf)
+		})
+		if err == nil {
+			err = flush()
+		}
+
+		// Wait for the segments to be translated. The error which cancelled
+		// the transcription is returned rather than the cancellation
+		if translator != nil {
+			if terr := translator.Close(); terr != nil {
+				return terr
+			}
+		}
+		if err != nil && !errors.Is(err, audio.ErrEndOfRange) {
 			return err
 		}
 
-		return nil
+		// Complete the documents, with the translated segments
+		result := taskctx.Result()
+		if translator != nil {
+			result.Task = "translate"
+			result.Segments = translated
+			result.Text = segmentText(translated)
+		}
+		return out.Close(result)
 	})
 }
 
 func (cmd *TranslateCmd) run_remote(app *Globals, translate bool) error {
-	// Open the audio file
-	f, err := os.Open(cmd.Path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	// Create a client for the whisper service
 	opts := []goclient.ClientOpt{
 		goclient.OptTimeout(5 * time.Minute), // Set a timeout for the request
@@ -159,17 +269,81 @@ func (cmd *TranslateCmd) run_remote(app *Globals, translate bool) error {
 		return err
 	}
 
-	// Create an array of parameters for the transcription
+	// Create the output writers
+	out, err := cmd.outputs(cmd.Path)
+	if err != nil {
+		return err
+	}
+	defer out.close()
+
+	// The result sets the task, language and duration of JSON documents
+	result := &schema.Transcription{Task: "transcribe"}
+	if translate {
+		result.Task = "translate"
+	}
+
+	// Write the segments, with timestamps offset from the start of the audio
+	var offset time.Duration
+	write := func(segments []*schema.Segment) {
+		for _, segment := range segments {
+			if segment.Language != "" {
+				result.Language = segment.Language
+			}
+			if err := out.Write(format.Offset(segment, offset)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+
+	// Create an array of parameters for the transcription, with the segments
+	// in the response
 	params := []client.Opt{
-		client.OptPath("audio.wav"), client.OptFormat(openai.FormatJson), client.OptLanguage(cmd.Language),
+		client.OptPath("audio.wav"), client.OptFormat(openai.FormatVerboseJson), client.OptLanguage(cmd.Language),
+	}
+	if len(cmd.Allowed) > 0 {
+		params = append(params, client.OptAllowedLanguages(cmd.Allowed...))
+	}
+	if cmd.PerSegment {
+		params = append(params, client.OptLanguagePerSegment())
+	}
+	if cmd.Target != "" {
+		params = append(params, client.OptTargetLanguage(cmd.Target))
+	}
+	if len(cmd.Vocabulary) > 0 {
+		params = append(params, client.OptVocabulary(cmd.Vocabulary...))
 	}
 	if cmd.Diarize {
 		params = append(params, client.OptDiarize())
 	}
 	if cmd.Stream {
+		var progress *progressBar
+		if cmd.Progress {
+			progress = newProgressBar(os.Stderr, 40)
+			defer progress.Clear()
+		}
 		params = append(params, client.OptStream(func(evt schema.Event) {
-			// TODO: Delta will be text or json depending on the format
-			fmt.Println("event:", evt)
+			if evt.Type == schema.TranscribeStreamProgressType {
+				if progress != nil && evt.Progress != nil {
+					progress.Set(*evt.Progress)
+				}
+				return
+			}
+			if progress != nil {
+				progress.Clear()
+				defer progress.Redraw()
+			}
+
+			// Segments are written to the outputs, and other events are
+			// logged to stderr
+			switch evt.Type {
+			case schema.TranscribeStreamDeltaType:
+				write([]*schema.Segment{streamSegment(evt)})
+			case schema.TranscribeStreamLanguageType:
+				result.Language = evt.Text
+				fmt.Fprintln(os.Stderr, "language:", evt.Text)
+			case schema.TranscribeStreamErrorType:
+				fmt.Fprintln(os.Stderr, "error:", evt.Text)
+			}
 		}))
 	}
 	if cmd.Temperature != nil {
@@ -179,6 +353,43 @@ func (cmd *TranslateCmd) run_remote(app *Globals, translate bool) error {
 		params = append(params, client.OptPrompt(types.PtrString(cmd.Prompt)))
 	}
 
+	// When requested, the whisper server reads audio from a URL. Other
+	// services require the audio is read and uploaded
+	if cmd.ServerFetch && isURL(cmd.Path) && cmd.InputFormat == "" {
+		if response, err := cmd.remoteURL(app, remote, translate, params...); err == nil {
+			if !cmd.Stream {
+				write(response.Segments)
+			}
+			result.Duration = response.Duration
+			return out.Close(result)
+		} else if !errors.Is(err, httpresponse.ErrNotImplemented) {
+			return err
+		}
+	}
+
+	// The part of the audio to transcribe, and the filters applied to it
+	rng, err := cmd.rng()
+	if err != nil {
+		return err
+	}
+	filters, err := audio.NewPreprocess(strings.Join(cmd.Preprocess, ","), whisper.SampleRate)
+	if err != nil {
+		return err
+	}
+	defer filters.Close()
+
+	// Open the audio file, and decode the audio stream at the sample rate
+	// of the filters
+	f, err := cmd.open(app.ctx, cmd.Path, filters.SampleRate())
+	if err != nil {
+		return err
+	}
+	f, err = cmd.decode(app.ctx, cmd.Path, f, filters.SampleRate())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
 	// Create a segmenter - read segments based on requested segment size
 	sopts := []segmenter.Opt{}
 	if cmd.Segments > 0 {
@@ -188,20 +399,30 @@ func (cmd *TranslateCmd) run_remote(app *Globals, translate bool) error {
 		sopts = append(sopts, segmenter.WithDefaultSilenceThreshold())
 		sopts = append(sopts, segmenter.WithSilenceSize(cmd.Silence))
 	}
-	splitter, err := segmenter.NewReader(f, whisper.SampleRate, sopts...)
+	splitter, err := segmenter.NewReader(f, filters.SampleRate(), sopts...)
 	if err != nil {
 		return err
 	}
 	defer splitter.Close()
 
-	// Read samples and transcribe or translate them
-	return splitter.DecodeInt16(app.ctx, func(ts time.Duration, data []int16) error {
-		// Make a mono WAV file from the float32 samples
+	// Read and filter samples within the range, and transcribe or translate
+	// them. Timestamps are offset by the start of decoded audio
+	filter, flush := audio.Filtered(filters, func(ts time.Duration, data []int16) error {
+		ts, data, done := audio.Clip(rng, ts, data, whisper.SampleRate)
+		if len(data) == 0 {
+			return audio.EndOfRange(done)
+		}
+
+		// Make a mono WAV file from the filtered samples
 		r, err := wav.NewInt16(data, whisper.SampleRate, 1)
 		if err != nil {
 			return err
 		}
 
+		// Segments are offset from the start of this chunk of audio
+		offset = ts
+		result.Duration = schema.Timestamp(ts + time.Duration(len(data))*time.Second/whisper.SampleRate)
+
 		var segments []*schema.Segment
 		if translate {
 			translation, err := remote.Translate(app.ctx, cmd.Model, r, params...)
@@ -219,24 +440,123 @@ func (cmd *TranslateCmd) run_remote(app *Globals, translate bool) error {
 			}
 		}
 
-		// Write the segments to the writer
-		for _, segment := range segments {
-			var buf bytes.Buffer
-			switch cmd.Format {
-			case "json", "verbose_json":
-				fmt.Println(segment)
-			case "srt":
-				segment.WriteSRT(&buf, ts)
-				fmt.Println(buf.String())
-			case "vtt":
-				segment.WriteVTT(&buf, ts)
-				fmt.Println(buf.String())
-			case "text":
-				segment.WriteText(&buf)
-				fmt.Println(buf.String())
-			}
+		// Write the segments, which have already been written when streamed
+		if !cmd.Stream {
+			write(segments)
+		}
+		return audio.EndOfRange(done)
+	})
+	err = splitter.DecodeInt16(app.ctx, func(ts time.Duration, data []int16) error {
+		start, _ := decoded(f, splitter)
+		return filter(ts+start, data)
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil && !errors.Is(err, audio.ErrEndOfRange) {
+		return err
+	}
+
+	// Complete the documents
+	return out.Close(result)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Transcribe or translate audio which the remote service reads from a URL.
+// Returns a not implemented error if the service cannot read from a URL
+func (cmd *TranslateCmd) remoteURL(app *Globals, remote *client.Client, translate bool, params ...client.Opt) (*schema.Transcription, error) {
+	params = append(params, client.OptFileURL(cmd.Path))
+	if cmd.AudioStreamLanguage != "" {
+		// The server cannot read the stream language from a URL, so the
+		// stream is selected here and sent by index
+		if cmd.AudioStream >= 0 {
+			return nil, httpresponse.ErrBadRequest.With("--audio-stream and --audio-stream-language cannot both be set")
+		} else if stream, err := audio.StreamLanguage(cmd.Path, cmd.AudioStreamLanguage); err != nil {
+			return nil, fmt.Errorf("%s: %w", cmd.Path, err)
+		} else {
+			params = append(params, client.OptStreamIndex(uint64(stream)))
 		}
+	} else if cmd.AudioStream >= 0 {
+		params = append(params, client.OptStreamIndex(uint64(cmd.AudioStream)))
+	}
+	if cmd.Start > 0 {
+		params = append(params, client.OptStart(cmd.Start))
+	}
+	if cmd.End > 0 {
+		params = append(params, client.OptEnd(cmd.End))
+	}
+	if len(cmd.Preprocess) > 0 {
+		params = append(params, client.OptPreprocess(cmd.Preprocess...))
+	}
+	if translate {
+		return remote.Translate(app.ctx, cmd.Model, nil, params...)
+	} else {
+		return remote.Transcribe(app.ctx, cmd.Model, nil, params...)
+	}
+}
 
+// Return a translator which translates segments to the target language in the
+// background, and writes them in order. On the first error the transcription
+// is cancelled. Returns nil when there is no target language
+func newSegmentTranslator(ctx context.Context, service *whisper.Whisper, target string, cancel context.CancelCauseFunc, write func(*schema.Segment) error) *translate.Stream {
+	if target == "" {
 		return nil
+	}
+	return translate.NewStream(ctx, service.TranslateSegments, target, cancel, func(_ string, segment *schema.Segment) error {
+		return write(segment)
 	})
 }
+
+// Return the text of segments
+func segmentText(segments []*schema.Segment) string {
+	var text strings.Builder
+	for _, segment := range segments {
+		text.WriteString(segment.Text)
+	}
+	return text.String()
+}
+
+// Return the segment in a streamed delta event. The whisper service streams
+// segments as JSON, and other services stream text, which is returned as a
+// segment without timestamps
+func streamSegment(evt schema.Event) *schema.Segment {
+	var segment schema.Segment
+	if err := json.Unmarshal([]byte(evt.Delta), &segment); err == nil {
+		return &segment
+	}
+	return &schema.Segment{Text: evt.Delta}
+}
+
+// Return the name of the target language for text translation, or empty if
+// there is no target language or whisper translates to English
+func (cmd *TranslateCmd) targetLanguage(app *Globals, translate bool) (string, error) {
+	if cmd.Target == "" {
+		return "", nil
+	}
+	code, _ := client.LanguageCode(cmd.Target)
+	if code == "" {
+		return "", httpresponse.ErrBadRequest.Withf("language %q not supported", cmd.Target)
+	} else if code == "en" && translate {
+		return "", nil
+	} else if !app.service.CanTranslateText() {
+		return "", httpresponse.ErrNotImplemented.Withf("translation to %q requires --translate-url", cmd.Target)
+	}
+	name, _ := openai.LanguageCode(code)
+	return name, nil
+}
+
+// Set the allowed languages, which can be language names, two-letter or
+// three-letter codes
+func setAllowedLanguages(taskctx *task.Context, languages []string) error {
+	codes := make([]string, 0, len(languages))
+	for _, language := range languages {
+		if code, _ := client.LanguageCode(language); code == "" {
+			return httpresponse.ErrBadRequest.Withf("language %q not supported", language)
+		} else {
+			codes = append(codes, code)
+		}
+	}
+	return taskctx.SetAllowedLanguages(codes...)
+}
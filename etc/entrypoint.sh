@@ -6,8 +6,8 @@ if [ -z "$1" ]; then
     # Create the persistent data folder if it doesn't exist
     install -d -m 0755 /data || exit 1
 
-    # Run as a server
-    /usr/local/bin/whisper server --listen :80
+    # Run as a server, without TLS, which is terminated by a proxy in front of the container
+    /usr/local/bin/whisper server --listen :80 --insecure
 else
     exec /usr/local/bin/whisper "$@"
 fi
@@ -1,6 +1,13 @@
 package whisper
 
 import (
+	"log/slog"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	tracing "github.com/mutablelogic/go-whisper/pkg/tracing"
+	translate "github.com/mutablelogic/go-whisper/pkg/translate"
+
 	// Namespace imports
 	. "github.com/djthorpe/go-errors"
 )
@@ -11,8 +18,12 @@ import (
 type opts struct {
 	MaxConcurrent int
 	logfn         LogFn
+	logger        *slog.Logger
+	tracer        *tracing.Tracer
 	debug         bool
 	gpu           int
+	translator    translate.Translator
+	models        []*schema.Model
 }
 
 type Opt func(*opts) error
@@ -40,6 +51,33 @@ func OptLog(fn LogFn) Opt {
 	}
 }
 
+// Set a structured logger, which receives log lines from whisper.cpp and
+// ffmpeg, and is used for logging within the service. The request id is
+// added to log lines when the logger was created with logging.New or
+// logging.NewHandler
+func OptLogger(logger *slog.Logger) Opt {
+	return func(o *opts) error {
+		if logger == nil {
+			return ErrBadParameter.With("logger is nil")
+		}
+		o.logger = logger
+		return nil
+	}
+}
+
+// Set a tracer, which records spans for pool acquisition, model loading
+// and each call to whisper.cpp. The caller is responsible for closing
+// the tracer after the service is closed
+func OptTracer(tracer *tracing.Tracer) Opt {
+	return func(o *opts) error {
+		if tracer == nil {
+			return ErrBadParameter.With("tracer is nil")
+		}
+		o.tracer = tracer
+		return nil
+	}
+}
+
 // Set debugging
 func OptDebug() Opt {
 	return func(o *opts) error {
@@ -55,3 +93,29 @@ func OptNoGPU() Opt {
 		return nil
 	}
 }
+
+// Set the text translation backend, used for translation to languages
+// other than English
+func OptTranslator(t translate.Translator) Opt {
+	return func(o *opts) error {
+		if t == nil {
+			return ErrBadParameter.With("translator is nil")
+		}
+		o.translator = t
+		return nil
+	}
+}
+
+// Register a model which is loaded from a source rather than the models
+// directory, such as a model embedded in the binary with embed.FS. Sources
+// can be created with store.NewBytesSource, store.NewReaderAtSource and
+// store.NewFSSource
+func OptModel(id string, source schema.Source) Opt {
+	return func(o *opts) error {
+		if id == "" || source == nil {
+			return ErrBadParameter.With("model id and source are required")
+		}
+		o.models = append(o.models, &schema.Model{Id: id, Source: source})
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/httpresponse"
+	"github.com/mutablelogic/go-whisper/pkg/limit"
+	"github.com/mutablelogic/go-whisper/pkg/schema"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// requestError is an error with a status code, and the request parameter
+// which caused it
+type requestError struct {
+	status int
+	err    *schema.Error
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Codes for request errors
+	codeMissingParameter = "missing_required_parameter"
+	codeInvalidParameter = "invalid_value"
+	codeFileTooLarge     = "file_too_large"
+	codeAudioTooLong     = "audio_too_long"
+	codeInvalidMedia     = "invalid_media"
+	codeModelNotFound    = "model_not_found"
+	codeInvalidFileURL   = "invalid_file_url"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return an error for an invalid request parameter
+func newRequestError(status int, param, code, format string, a ...any) error {
+	err := schema.NewError(schema.ErrorTypeInvalidRequest, code, fmt.Sprintf(format, a...))
+	if param != "" {
+		err.Err.Param = &param
+	}
+	return &requestError{status, err}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Write an error in the form returned by the OpenAI API
+func writeAPIError(w http.ResponseWriter, err error) error {
+	status, response := errorResponse(err)
+	return httpresponse.JSON(w, status, 2, response)
+}
+
+// Return the status code and OpenAI error response for an error
+func errorResponse(err error) (int, *schema.Error) {
+	var reqerr *requestError
+	var maxerr *http.MaxBytesError
+	var limiterr *limit.LimitError
+	var code httpresponse.Err
+	switch {
+	case errors.As(err, &reqerr):
+		return reqerr.status, reqerr.err
+	case errors.As(err, &limiterr):
+		return http.StatusTooManyRequests, schema.NewError(limiterr.Type(), limiterr.Code, limiterr.Message)
+	case errors.As(err, &maxerr):
+		param := "file"
+		response := schema.NewError(schema.ErrorTypeInvalidRequest, codeFileTooLarge, fmt.Sprintf("Request body is larger than %d bytes", maxerr.Limit))
+		response.Err.Param = &param
+		return http.StatusRequestEntityTooLarge, response
+	case errors.As(err, &code) && int(code) < http.StatusInternalServerError:
+		return int(code), schema.NewError(schema.ErrorTypeInvalidRequest, "", err.Error())
+	case errors.As(err, &code):
+		return int(code), schema.NewError(schema.ErrorTypeServer, "", err.Error())
+	default:
+		return http.StatusInternalServerError, schema.NewError(schema.ErrorTypeServer, "", err.Error())
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	// Packages
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	limit "github.com/mutablelogic/go-whisper/pkg/limit"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_api_001(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		err     error
+		status  int
+		typ     string
+		code    string
+		param   string
+		message string
+	}{
+		{
+			newRequestError(http.StatusBadRequest, "file", codeMissingParameter, "Missing file"),
+			http.StatusBadRequest, schema.ErrorTypeInvalidRequest, codeMissingParameter, "file", "Missing file",
+		},
+		{
+			fmt.Errorf("wrapped: %w", newRequestError(http.StatusNotFound, "model", codeModelNotFound, "Model not found: %q", "tiny")),
+			http.StatusNotFound, schema.ErrorTypeInvalidRequest, codeModelNotFound, "model", `Model not found: "tiny"`,
+		},
+		{
+			&http.MaxBytesError{Limit: 1024},
+			http.StatusRequestEntityTooLarge, schema.ErrorTypeInvalidRequest, codeFileTooLarge, "file", "Request body is larger than 1024 bytes",
+		},
+		{
+			&limit.LimitError{Code: limit.CodeRequests, Message: "Too many requests", RetryAfter: time.Second},
+			http.StatusTooManyRequests, schema.ErrorTypeRateLimit, limit.CodeRequests, "", "Too many requests",
+		},
+		{
+			&limit.LimitError{Code: limit.CodeQuota, Message: "Quota exceeded"},
+			http.StatusTooManyRequests, schema.ErrorTypeQuota, limit.CodeQuota, "", "Quota exceeded",
+		},
+		{
+			httpresponse.ErrNotAuthorized.With("Invalid or missing API key"),
+			http.StatusUnauthorized, schema.ErrorTypeInvalidRequest, "", "", "Unauthorized: Invalid or missing API key",
+		},
+		{
+			httpresponse.Err(http.StatusMethodNotAllowed).With("PUT"),
+			http.StatusMethodNotAllowed, schema.ErrorTypeInvalidRequest, "", "", "Method Not Allowed: PUT",
+		},
+		{
+			httpresponse.ErrGatewayError.With("backend"),
+			http.StatusBadGateway, schema.ErrorTypeServer, "", "", "Bad Gateway: backend",
+		},
+		{
+			httpresponse.ErrInternalError,
+			http.StatusInternalServerError, schema.ErrorTypeServer, "", "", "Internal Server Error",
+		},
+		{
+			errors.New("failed"),
+			http.StatusInternalServerError, schema.ErrorTypeServer, "", "", "failed",
+		},
+	} {
+		status, response := errorResponse(test.err)
+		assert.Equal(test.status, status, test.err)
+		if !assert.NotNil(response, test.err) {
+			continue
+		}
+		assert.Equal(test.typ, response.Err.Type, test.err)
+		assert.Equal(test.message, response.Err.Message, test.err)
+		if test.code == "" {
+			assert.Nil(response.Err.Code, test.err)
+		} else if assert.NotNil(response.Err.Code, test.err) {
+			assert.Equal(test.code, *response.Err.Code, test.err)
+		}
+		if test.param == "" {
+			assert.Nil(response.Err.Param, test.err)
+		} else if assert.NotNil(response.Err.Param, test.err) {
+			assert.Equal(test.param, *response.Err.Param, test.err)
+		}
+	}
+}
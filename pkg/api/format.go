@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/httpresponse"
+	"github.com/mutablelogic/go-whisper/pkg/client/openai"
+	"github.com/mutablelogic/go-whisper/pkg/format"
+	"github.com/mutablelogic/go-whisper/pkg/schema"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// deltaWriter formats segments as the deltas of stream events, so that
+// the deltas form one document
+type deltaWriter struct {
+	name   string
+	buf    bytes.Buffer
+	writer format.Writer
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return a writer for the deltas in a response format. Text deltas are the
+// text of each segment, JSON deltas are a segment each, and SRT and VTT deltas
+// are cues, numbered continuously, with the VTT header in the first delta
+func newDeltaWriter(name string) *deltaWriter {
+	d := &deltaWriter{name: name}
+	switch name {
+	case openai.FormatSrt, openai.FormatVtt:
+		d.writer, _ = format.New(&d.buf, name)
+	}
+	return d
+}
+
+// Return the delta for a segment, or empty if there is no delta
+func (d *deltaWriter) Delta(seg *schema.Segment) (string, error) {
+	d.buf.Reset()
+	switch {
+	case d.writer != nil:
+		if err := d.writer.Write(seg); err != nil {
+			return "", err
+		}
+	case d.name == openai.FormatJson || d.name == openai.FormatVerboseJson:
+		if err := json.NewEncoder(&d.buf).Encode(seg); err != nil {
+			return "", err
+		}
+	default:
+		d.buf.WriteString(seg.Text)
+	}
+	return d.buf.String(), nil
+}
+
+// Write the transcription as the response, in the response format. The
+// document is written with the same writers as the command line, so the
+// json format is the text, and verbose_json includes the segments
+func response(w http.ResponseWriter, name string, result *schema.Transcription) error {
+	if name == "" {
+		name = openai.FormatText
+	} else if format.Name(name) == "" {
+		return httpresponse.ErrBadRequest.Withf("Invalid response format: %q", name)
+	}
+
+	// Write the document
+	var buf bytes.Buffer
+	if err := format.Write(&buf, name, result); err != nil {
+		return err
+	}
+	return httpresponse.Write(w, http.StatusOK, format.ContentType(name), func(w io.Writer) (int, error) {
+		return w.Write(buf.Bytes())
+	})
+}
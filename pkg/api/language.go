@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/httpresponse"
+	"github.com/mutablelogic/go-server/pkg/types"
+	"github.com/mutablelogic/go-whisper"
+	"github.com/mutablelogic/go-whisper/pkg/audio"
+	"github.com/mutablelogic/go-whisper/pkg/client"
+	"github.com/mutablelogic/go-whisper/pkg/client/gowhisper"
+	"github.com/mutablelogic/go-whisper/pkg/limit"
+	"github.com/mutablelogic/go-whisper/pkg/schema"
+	"github.com/mutablelogic/go-whisper/pkg/task"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Whisper only considers the first 30 seconds of audio for language detection
+	defaultLanguageDuration = 30 * time.Second
+	defaultLanguageTopK     = 5
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func DetectLanguage(ctx context.Context, service *whisper.Whisper, w http.ResponseWriter, r *http.Request) error {
+	var req gowhisper.LanguageRequest
+	if err := readRequest(r, &req); err != nil {
+		return writeAPIError(w, err)
+	} else if req.File.Body == nil {
+		return writeAPIError(w, newRequestError(http.StatusBadRequest, "file", codeMissingParameter, "Missing file"))
+	}
+
+	// Get the model
+	model := service.GetModelById(req.Model)
+	if model == nil {
+		return writeAPIError(w, newRequestError(http.StatusNotFound, "model", codeModelNotFound, "Model not found: %q", req.Model))
+	}
+
+	// Check the duration and number of languages
+	duration := defaultLanguageDuration
+	if req.Duration != nil {
+		if d := types.PtrFloat64(req.Duration); d <= 0 {
+			return writeAPIError(w, newRequestError(http.StatusBadRequest, "duration", codeInvalidParameter, "Invalid duration: %v", d))
+		} else {
+			duration = time.Duration(d * float64(time.Second))
+		}
+	}
+	topk := defaultLanguageTopK
+	if req.TopK != nil {
+		topk = int(types.PtrUint64(req.TopK))
+	}
+
+	// Check the allowed languages
+	allowed, err := allowedLanguages(req.AllowedLanguages)
+	if err != nil {
+		return writeAPIError(w, err)
+	}
+
+	// Decode and resample the start of the audio file
+	samples, err := audio.Head(ctx, req.File.Body, duration)
+	if err != nil {
+		return writeAPIError(w, newRequestError(http.StatusBadRequest, "file", codeInvalidMedia, "Unsupported or corrupt media: %v", err))
+	} else if len(samples) == 0 {
+		return writeAPIError(w, newRequestError(http.StatusBadRequest, "file", codeInvalidMedia, "No audio samples"))
+	}
+
+	// Add the decoded audio to the usage for the request
+	limit.AddAudio(ctx, time.Duration(len(samples))*time.Second/whisper.SampleRate)
+
+	// Detect the language
+	var result *schema.LanguageDetection
+	if err := service.WithModelContext(ctx, model, func(taskctx *task.Context) error {
+		if !taskctx.CanTranslate() {
+			return httpresponse.ErrBadRequest.Withf("Model %q is not multilingual", model.Id)
+		}
+		if err := taskctx.SetAllowedLanguages(allowed...); err != nil {
+			return err
+		}
+		result, err = taskctx.DetectLanguage(ctx, samples, topk)
+		return err
+	}); err != nil {
+		return writeAPIError(w, err)
+	}
+
+	// Return success
+	return httpresponse.JSON(w, http.StatusOK, 2, result)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return two-letter language codes from a comma-separated list of languages,
+// which can be language names, two-letter or three-letter codes
+func allowedLanguages(v *string) ([]string, error) {
+	var result []string
+	for _, language := range strings.Split(types.PtrString(v), ",") {
+		if language = strings.TrimSpace(language); language == "" {
+			continue
+		}
+		if code, _ := client.LanguageCode(language); code == "" {
+			return nil, newRequestError(http.StatusBadRequest, "allowed_languages", codeInvalidParameter, "Unsupported language: %q", language)
+		} else {
+			result = append(result, code)
+		}
+	}
+	return result, nil
+}
@@ -51,14 +51,14 @@ func DownloadModel(ctx context.Context, w http.ResponseWriter, r *http.Request,
 	// Get query
 	var query queryDownloadModel
 	if err := httprequest.Query(r.URL.Query(), &query); err != nil {
-		return httpresponse.Error(w, httpresponse.ErrBadRequest, err.Error())
+		return writeAPIError(w, httpresponse.ErrBadRequest.With(err.Error()))
 	}
 
 	// Create a text stream
 	var stream *httpresponse.TextStream
 	if query.Stream {
 		if stream = httpresponse.NewTextStream(w); stream == nil {
-			return httpresponse.Error(w, httpresponse.ErrInternalError, "Cannot create text stream")
+			return writeAPIError(w, httpresponse.ErrInternalError.With("Cannot create text stream"))
 		}
 		defer stream.Close()
 	}
@@ -70,14 +70,14 @@ func DownloadModel(ctx context.Context, w http.ResponseWriter, r *http.Request,
 			stream.Write(schema.DownloadStreamErrorType, err.Error())
 			return nil
 		} else {
-			return httpresponse.Error(w, httpresponse.ErrBadRequest, err.Error())
+			return writeAPIError(w, httpresponse.ErrBadRequest.With(err.Error()))
 		}
 	} else if err := req.Validate(); err != nil {
 		if stream != nil {
 			stream.Write(schema.DownloadStreamErrorType, err.Error())
 			return nil
 		} else {
-			return httpresponse.Error(w, httpresponse.ErrBadRequest, err.Error())
+			return writeAPIError(w, httpresponse.ErrBadRequest.With(err.Error()))
 		}
 	}
 
@@ -98,7 +98,7 @@ func DownloadModel(ctx context.Context, w http.ResponseWriter, r *http.Request,
 			stream.Write(schema.DownloadStreamErrorType, err.Error())
 			return nil
 		} else {
-			return httpresponse.Error(w, httpresponse.ErrGatewayError, err.Error())
+			return writeAPIError(w, httpresponse.ErrGatewayError.With(err.Error()))
 		}
 	}
 
@@ -115,7 +115,7 @@ func DownloadModel(ctx context.Context, w http.ResponseWriter, r *http.Request,
 func GetModelById(ctx context.Context, w http.ResponseWriter, service *whisper.Whisper, id string) {
 	model := service.GetModelById(id)
 	if model == nil {
-		httpresponse.Error(w, httpresponse.ErrNotFound, id)
+		writeAPIError(w, newRequestError(http.StatusNotFound, "model", codeModelNotFound, "Model not found: %q", id))
 		return
 	}
 	httpresponse.JSON(w, http.StatusOK, 2, model)
@@ -124,11 +124,11 @@ func GetModelById(ctx context.Context, w http.ResponseWriter, service *whisper.W
 func DeleteModelById(ctx context.Context, w http.ResponseWriter, service *whisper.Whisper, id string) {
 	model := service.GetModelById(id)
 	if model == nil {
-		httpresponse.Error(w, httpresponse.ErrNotFound, id)
+		writeAPIError(w, newRequestError(http.StatusNotFound, "model", codeModelNotFound, "Model not found: %q", id))
 		return
 	}
 	if err := service.DeleteModelById(model.Id); err != nil {
-		httpresponse.Error(w, httpresponse.ErrInternalError, err.Error())
+		writeAPIError(w, err)
 		return
 	}
 	httpresponse.Empty(w, http.StatusOK)
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"time"
+
+	// Packages
+	"github.com/mutablelogic/go-whisper/pkg/auth"
+	"github.com/mutablelogic/go-whisper/pkg/fetch"
+	"github.com/mutablelogic/go-whisper/pkg/limit"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type opts struct {
+	keys          *auth.Keys
+	limiter       *limit.Limiter
+	maxUploadSize int64
+	maxDuration   time.Duration
+	fetcher       *fetch.Fetcher
+}
+
+// Opt is an option for registering the endpoints
+type Opt func(*opts)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Require an API key as a bearer token for all endpoints except the health
+// check. Downloading and deleting models requires a key with the admin scope
+func OptKeys(keys *auth.Keys) Opt {
+	return func(o *opts) {
+		o.keys = keys
+	}
+}
+
+// Apply request rate, concurrency and daily audio limits to transcription,
+// translation and language detection, for each API key or client address
+func OptLimiter(limiter *limit.Limiter) Opt {
+	return func(o *opts) {
+		o.limiter = limiter
+	}
+}
+
+// Limit the size of the request body for transcription, translation and
+// language detection. A 413 error is returned for a larger body
+func OptMaxUploadSize(v int64) Opt {
+	return func(o *opts) {
+		o.maxUploadSize = v
+	}
+}
+
+// Limit the duration of audio for transcription and translation
+func OptMaxDuration(v time.Duration) Opt {
+	return func(o *opts) {
+		o.maxDuration = v
+	}
+}
+
+// Allow audio to be read from a URL with the file_url parameter, instead of
+// uploading a file. The fetcher limits the hosts, size and timeout
+func OptFetcher(fetcher *fetch.Fetcher) Opt {
+	return func(o *opts) {
+		o.fetcher = fetcher
+	}
+}
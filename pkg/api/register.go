@@ -1,46 +1,110 @@
 package api
 
 import (
+	"io"
 	"net/http"
 	"os"
 
 	// Packages
 	"github.com/mutablelogic/go-server/pkg/httpresponse"
-	"github.com/mutablelogic/go-server/pkg/logger"
 	"github.com/mutablelogic/go-server/pkg/types"
 	"github.com/mutablelogic/go-whisper"
+	"github.com/mutablelogic/go-whisper/pkg/auth"
+	"github.com/mutablelogic/go-whisper/pkg/client/gowhisper"
 	"github.com/mutablelogic/go-whisper/pkg/client/openai"
+	"github.com/mutablelogic/go-whisper/pkg/limit"
+	"github.com/mutablelogic/go-whisper/pkg/logging"
+	"github.com/mutablelogic/go-whisper/pkg/metrics"
+	"github.com/mutablelogic/go-whisper/pkg/tracing"
 )
 
 /////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
-func RegisterEndpoints(base string, whisper *whisper.Whisper, mux *http.ServeMux, debug bool) *http.ServeMux {
+func RegisterEndpoints(base string, whisper *whisper.Whisper, mux *http.ServeMux, debug bool, opt ...Opt) *http.ServeMux {
+	var o opts
+	for _, fn := range opt {
+		fn(&o)
+	}
+
 	// Create a new router
 	if mux == nil {
 		mux = http.NewServeMux()
 	}
 
-	// Create a logger
-	logger := logger.New(os.Stderr, logger.Term, debug)
+	// Use the service logger, or create one. Each request is assigned a
+	// request id, which is returned in the X-Request-Id header and added
+	// to log lines. When tracing is enabled, a span is recorded for each
+	// request
+	log := whisper.Logger()
+	if log == nil {
+		log, _ = logging.New(os.Stderr, logging.FormatTerm, debug)
+	}
+	logger, tracer := logging.Middleware(log), tracing.Middleware(whisper.Tracer())
+	handleFunc := func(scope func(*http.Request) auth.Scope, fn http.HandlerFunc) http.HandlerFunc {
+		return logger(tracer(auth.Middleware(o.keys, scope, writeAPIError)(fn)))
+	}
+
+	// Scopes required for each endpoint, when API keys are set. Models can
+	// be listed with the transcribe scope, and downloaded or deleted with
+	// the admin scope
+	public := func(*http.Request) auth.Scope {
+		return ""
+	}
+	transcribe := func(*http.Request) auth.Scope {
+		return auth.ScopeTranscribe
+	}
+	limited := limit.Middleware(o.limiter, writeAPIError)
+
+	// Limit the size of uploaded audio
+	sized := func(fn http.HandlerFunc) http.HandlerFunc {
+		if o.maxUploadSize <= 0 {
+			return fn
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, o.maxUploadSize)
+			fn(w, r)
+		}
+	}
+	models := func(r *http.Request) auth.Scope {
+		if r.Method == http.MethodGet {
+			return auth.ScopeTranscribe
+		}
+		return auth.ScopeAdmin
+	}
 
 	// Not Found: GET /
 	//   returns a not found response
-	mux.HandleFunc("/", logger.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", handleFunc(public, func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
-		httpresponse.Error(w, httpresponse.ErrNotFound)
+		writeAPIError(w, httpresponse.ErrNotFound.With(r.URL.Path))
 	}))
 
 	// Health: GET /v1/health
 	//   returns an empty OK response
-	mux.HandleFunc(types.JoinPath(base, "health"), logger.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(types.JoinPath(base, "health"), handleFunc(public, func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 
 		switch r.Method {
 		case http.MethodGet:
 			httpresponse.Empty(w, http.StatusOK)
 		default:
-			httpresponse.Error(w, httpresponse.Err(http.StatusMethodNotAllowed), r.Method)
+			writeAPIError(w, httpresponse.Err(http.StatusMethodNotAllowed).With(r.Method))
+		}
+	}))
+
+	// Metrics: GET /v1/metrics
+	//   returns request counts, timings and pool occupancy in the Prometheus text format
+	mux.HandleFunc(types.JoinPath(base, "metrics"), handleFunc(transcribe, func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		switch r.Method {
+		case http.MethodGet:
+			httpresponse.Write(w, http.StatusOK, metrics.ContentType, func(w io.Writer) (int, error) {
+				return 0, whisper.WriteMetrics(w)
+			})
+		default:
+			writeAPIError(w, httpresponse.Err(http.StatusMethodNotAllowed).With(r.Method))
 		}
 	}))
 
@@ -49,7 +113,7 @@ func RegisterEndpoints(base string, whisper *whisper.Whisper, mux *http.ServeMux
 	// Download Model: POST /v1/models?stream={bool}
 	//   downloads a model from the server
 	//   if stream is true then progress is streamed back to the client
-	mux.HandleFunc(types.JoinPath(base, "models"), logger.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(types.JoinPath(base, "models"), handleFunc(models, func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 
 		switch r.Method {
@@ -58,7 +122,7 @@ func RegisterEndpoints(base string, whisper *whisper.Whisper, mux *http.ServeMux
 		case http.MethodPost:
 			DownloadModel(r.Context(), w, r, whisper)
 		default:
-			httpresponse.Error(w, httpresponse.Err(http.StatusMethodNotAllowed), r.Method)
+			writeAPIError(w, httpresponse.Err(http.StatusMethodNotAllowed).With(r.Method))
 		}
 	}))
 
@@ -66,7 +130,7 @@ func RegisterEndpoints(base string, whisper *whisper.Whisper, mux *http.ServeMux
 	//   returns an existing model
 	// Delete: DELETE /v1/models/{id}
 	//   deletes an existing model
-	mux.HandleFunc(types.JoinPath(base, "models/{id}"), logger.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(types.JoinPath(base, "models/{id}"), handleFunc(models, func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 
 		id := r.PathValue("id")
@@ -76,36 +140,62 @@ func RegisterEndpoints(base string, whisper *whisper.Whisper, mux *http.ServeMux
 		case http.MethodDelete:
 			DeleteModelById(r.Context(), w, whisper, id)
 		default:
-			httpresponse.Error(w, httpresponse.Err(http.StatusMethodNotAllowed), r.Method)
+			writeAPIError(w, httpresponse.Err(http.StatusMethodNotAllowed).With(r.Method))
+		}
+	}))
+
+	// Usage: GET /v1/usage?all={bool}
+	//   returns the daily usage for the API key or client address, or for all
+	mux.HandleFunc(types.JoinPath(base, "usage"), handleFunc(transcribe, func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		switch r.Method {
+		case http.MethodGet:
+			GetUsage(r.Context(), w, r, o.limiter, o.keys)
+		default:
+			writeAPIError(w, httpresponse.Err(http.StatusMethodNotAllowed).With(r.Method))
 		}
 	}))
 
 	// Translate: POST /v1/audio/translations
 	//   Translates audio into english
-	mux.HandleFunc(types.JoinPath(base, openai.TranslatePath), logger.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(types.JoinPath(base, openai.TranslatePath), handleFunc(transcribe, limited(sized(func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 
 		switch r.Method {
 		case http.MethodPost:
-			TranslateFile(r.Context(), whisper, w, r)
+			TranslateFile(r.Context(), whisper, w, r, o.fetcher, o.maxDuration)
 		default:
-			httpresponse.Error(w, httpresponse.Err(http.StatusMethodNotAllowed), r.Method)
+			writeAPIError(w, httpresponse.Err(http.StatusMethodNotAllowed).With(r.Method))
 		}
-	}))
+	}))))
 
 	// Transcribe: POST /v1/audio/transcriptions
 	//   Transcribes audio into the input language - language parameter should be set to the source
 	//   language of the audio
-	mux.HandleFunc(types.JoinPath(base, openai.TranscribePath), logger.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(types.JoinPath(base, openai.TranscribePath), handleFunc(transcribe, limited(sized(func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 
 		switch r.Method {
 		case http.MethodPost:
-			TranscribeFile(r.Context(), whisper, w, r)
+			TranscribeFile(r.Context(), whisper, w, r, o.fetcher, o.maxDuration)
 		default:
-			httpresponse.Error(w, httpresponse.Err(http.StatusMethodNotAllowed), r.Method)
+			writeAPIError(w, httpresponse.Err(http.StatusMethodNotAllowed).With(r.Method))
 		}
-	}))
+	}))))
+
+	// Detect Language: POST /v1/audio/language
+	//   Returns the most probable languages spoken at the start of the audio
+	mux.HandleFunc(types.JoinPath(base, gowhisper.LanguagePath), handleFunc(transcribe, limited(sized(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		switch r.Method {
+		case http.MethodPost:
+			DetectLanguage(r.Context(), whisper, w, r)
+		default:
+			writeAPIError(w, httpresponse.Err(http.StatusMethodNotAllowed).With(r.Method))
+		}
+	}))))
 
 	// Transcribe: POST /v1/audio/transcriptions/{model-id}
 	//   Transcribes streamed media into the input language
@@ -118,7 +208,7 @@ func RegisterEndpoints(base string, whisper *whisper.Whisper, mux *http.ServeMux
 			case http.MethodPost:
 				TranscribeStream(r.Context(), whisper, w, r, model)
 			default:
-				httpresponse.Error(w, http.StatusMethodNotAllowed)
+				writeAPIError(w, httpresponse.Err(http.StatusMethodNotAllowed).With(r.Method))
 			}
 		})*/
 
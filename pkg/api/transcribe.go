@@ -1,9 +1,9 @@
 package api
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"slices"
@@ -11,42 +11,104 @@ import (
 	"time"
 
 	// Packages
+	"github.com/mutablelogic/go-media/pkg/ffmpeg"
 	"github.com/mutablelogic/go-media/pkg/segmenter"
 	"github.com/mutablelogic/go-server/pkg/httprequest"
 	"github.com/mutablelogic/go-server/pkg/httpresponse"
 	"github.com/mutablelogic/go-server/pkg/types"
 	"github.com/mutablelogic/go-whisper"
+	"github.com/mutablelogic/go-whisper/pkg/audio"
+	"github.com/mutablelogic/go-whisper/pkg/client"
 	"github.com/mutablelogic/go-whisper/pkg/client/gowhisper"
 	"github.com/mutablelogic/go-whisper/pkg/client/openai"
+	"github.com/mutablelogic/go-whisper/pkg/fetch"
+	"github.com/mutablelogic/go-whisper/pkg/limit"
+	"github.com/mutablelogic/go-whisper/pkg/logging"
 	"github.com/mutablelogic/go-whisper/pkg/schema"
 	"github.com/mutablelogic/go-whisper/pkg/task"
+	"github.com/mutablelogic/go-whisper/pkg/tracing"
+	"github.com/mutablelogic/go-whisper/pkg/translate"
+	"github.com/mutablelogic/go-whisper/pkg/vocabulary"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// eventStream writes transcription events to a text stream, adding the
+// request id to each event
+type eventStream struct {
+	*httpresponse.TextStream
+	id string
+}
+
+// input segments the audio of a request, within the range of the audio to
+// transcribe. The stream is decoded separately when it is not the best audio
+// stream or is decoded from the start of the range, and is closed with the
+// segmenter
+type input struct {
+	*segmenter.Segmenter
+	duration time.Duration
+	rng      audio.Range
+	filters  *audio.Preprocess
+	stream   *audio.Stream
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
-func TranscribeFile(ctx context.Context, service *whisper.Whisper, w http.ResponseWriter, r *http.Request) error {
+// Transcribe an audio file, or audio read from a URL with the fetcher. The
+// audio is rejected before a model is loaded if it cannot be decoded, or is
+// longer than maxDuration when it is not zero
+func TranscribeFile(ctx context.Context, service *whisper.Whisper, w http.ResponseWriter, r *http.Request, fetcher *fetch.Fetcher, maxDuration time.Duration) error {
 	var req gowhisper.TranscriptionRequest
-	if err := httprequest.Read(r, &req); err != nil {
-		return httpresponse.Error(w, httpresponse.ErrBadRequest, err.Error())
+	if err := readRequest(r, &req); err != nil {
+		return writeAPIError(w, err)
 	}
-	return transcribe_file(ctx, service, w, req.File.Body, req.Model, types.PtrString(req.Format), types.PtrString(req.Language), types.PtrString(req.Prompt), req.Temperature, false, types.PtrBool(req.Diarize), types.PtrBool(req.Stream))
+	return transcribe_file(ctx, service, w, req, false, fetcher, maxDuration)
 }
 
-func TranslateFile(ctx context.Context, service *whisper.Whisper, w http.ResponseWriter, r *http.Request) error {
+// Translate an audio file to English, or to the target language with a text
+// translation backend
+func TranslateFile(ctx context.Context, service *whisper.Whisper, w http.ResponseWriter, r *http.Request, fetcher *fetch.Fetcher, maxDuration time.Duration) error {
 	var req gowhisper.TranslationRequest
-	if err := httprequest.Read(r, &req); err != nil {
-		return httpresponse.Error(w, httpresponse.ErrBadRequest, err.Error())
+	if err := readRequest(r, &req); err != nil {
+		return writeAPIError(w, err)
 	}
-	return transcribe_file(ctx, service, w, req.File.Body, req.Model, types.PtrString(req.Format), types.PtrString(req.Language), types.PtrString(req.Prompt), req.Temperature, true, types.PtrBool(req.Diarize), types.PtrBool(req.Stream))
+
+	// Translation has the same parameters as transcription
+	var transcribe gowhisper.TranscriptionRequest
+	transcribe.TranslationRequest = req.TranslationRequest
+	transcribe.Language = req.Language
+	transcribe.Stream = req.Stream
+	transcribe.Diarize = req.Diarize
+	transcribe.AllowedLanguages = req.AllowedLanguages
+	transcribe.LanguagePerSegment = req.LanguagePerSegment
+	transcribe.TargetLanguage = req.TargetLanguage
+	transcribe.Vocabulary = req.Vocabulary
+	transcribe.FileURL = req.FileURL
+	transcribe.StreamIndex = req.StreamIndex
+	transcribe.StreamLanguage = req.StreamLanguage
+	transcribe.Start = req.Start
+	transcribe.End = req.End
+	transcribe.Preprocess = req.Preprocess
+	return transcribe_file(ctx, service, w, transcribe, true, fetcher, maxDuration)
 }
 
-func transcribe_file(ctx context.Context, service *whisper.Whisper, w http.ResponseWriter, r io.Reader, model, format, language, prompt string, temperature *float64, translate, diarize, realtime bool) error {
+func transcribe_file(ctx context.Context, service *whisper.Whisper, w http.ResponseWriter, req gowhisper.TranscriptionRequest, translate bool, fetcher *fetch.Fetcher, maxDuration time.Duration) error {
+	start := time.Now()
+	model := req.Model
+	format := types.PtrString(req.Format)
+	language := types.PtrString(req.Language)
+	prompt := types.PtrString(req.Prompt)
+
 	// Create a text stream
-	var stream *httpresponse.TextStream
-	if realtime {
-		if stream = httpresponse.NewTextStream(w); stream == nil {
-			return httpresponse.Error(w, httpresponse.ErrInternalError.With("Cannot create text stream"))
+	var stream *eventStream
+	if types.PtrBool(req.Stream) {
+		if stream = newEventStream(ctx, w); stream == nil {
+			return writeAPIError(w, httpresponse.ErrInternalError.With("Cannot create text stream"))
 		}
 		defer stream.Close()
 	}
@@ -54,39 +116,114 @@ func transcribe_file(ctx context.Context, service *whisper.Whisper, w http.Respo
 	// Get the model
 	model_ := service.GetModelById(model)
 	if model_ == nil {
-		err := httpresponse.ErrNotFound.Withf("Model not found: %q", model)
-		if stream != nil {
-			stream.Write(schema.TranscribeStreamErrorType, schema.Event{
-				Type: schema.TranscribeStreamErrorType,
-				Text: err.Error(),
-			})
-			return nil
-		} else {
-			return httpresponse.Error(w, err)
-		}
+		return writeError(w, stream, newRequestError(http.StatusNotFound, "model", codeModelNotFound, "Model not found: %q", model))
 	}
 
 	// Check the format
 	if format = strings.TrimSpace(format); format == "" {
 		format = openai.Formats[0] // Default to first format
 	} else if !slices.Contains(openai.Formats, format) {
-		err := httpresponse.ErrBadRequest.Withf("Unsupported format: %q", format)
-		if stream != nil {
-			stream.Write(schema.TranscribeStreamErrorType, schema.Event{
-				Type: schema.TranscribeStreamErrorType,
-				Text: err.Error(),
+		return writeError(w, stream, newRequestError(http.StatusBadRequest, "response_format", codeInvalidParameter, "Unsupported format: %q", format))
+	}
+
+	// Check the allowed languages
+	allowed, err := allowedLanguages(req.AllowedLanguages)
+	if err != nil {
+		return writeError(w, stream, err)
+	}
+
+	// The task for metrics
+	kind := "transcribe"
+	if translate {
+		kind = "translate"
+	}
+
+	// Check the target language. Whisper translates to English, other
+	// languages require a text translation backend after transcription
+	target, err := targetLanguage(service, req.TargetLanguage, translate)
+	if err != nil {
+		return writeError(w, stream, err)
+	} else if target != "" {
+		translate = false
+	}
+
+	// Check the vocabulary
+	vocabulary, err := vocabulary.Parse(types.PtrString(req.Vocabulary))
+	if err != nil {
+		return writeError(w, stream, newRequestError(http.StatusBadRequest, "vocabulary", codeInvalidParameter, "%v", err))
+	}
+
+	// Check the range of audio to transcribe
+	rng, err := audioRange(req.Start, req.End)
+	if err != nil {
+		return writeError(w, stream, err)
+	}
+
+	// Check the filters applied to the audio
+	filters, err := audio.NewPreprocess(types.PtrString(req.Preprocess), whisper.SampleRate)
+	if err != nil {
+		return writeError(w, stream, newRequestError(http.StatusBadRequest, "preprocess", codeInvalidParameter, "%v", err))
+	}
+	defer filters.Close()
+
+	// Read the audio from the file, or stream it from the URL
+	body, err := openFile(ctx, req.File.Body, req.FileURL, fetcher)
+	if err != nil {
+		return writeError(w, stream, err)
+	} else if closer, ok := body.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// Probe the audio before a model is loaded, so that unsupported or
+	// corrupt files are rejected without waiting for a context
+	in, err := probe(ctx, body, req.StreamIndex, req.StreamLanguage, rng, maxDuration, filters.SampleRate())
+	if err != nil {
+		return writeError(w, stream, err)
+	}
+	defer in.Close()
+	in.filters = filters
+
+	// Write a segment in a language to the stream, with a language event
+	// when the language changes
+	var translated strings.Builder
+	deltas := newDeltaWriter(format)
+	emit := func(lang string, seg *schema.Segment) error {
+		if language != lang {
+			language = lang
+			stream.Event(schema.Event{
+				Type: schema.TranscribeStreamLanguageType,
+				Text: language,
+			})
+		}
+		if target != "" {
+			translated.WriteString(seg.Text)
+		}
+
+		// Format the segment for the stream
+		if delta, err := deltas.Delta(seg); err != nil {
+			return err
+		} else if delta != "" {
+			stream.Event(schema.Event{
+				Type:  schema.TranscribeStreamDeltaType,
+				Delta: delta,
 			})
-			return nil
-		} else {
-			return httpresponse.Error(w, err)
 		}
+		return nil
 	}
 
+	// The task is cancelled on the first error writing to the stream, and
+	// streamed segments are translated in the background
+	taskctx_, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	translator := newStreamTranslator(taskctx_, service, stream, target, cancel, emit)
+
 	// Start a translation task
 	var result *schema.Transcription
-	if err := service.WithModel(model_, func(taskctx *task.Context) error {
+	var timings *schema.Timings
+	err = service.WithModelContext(taskctx_, model_, func(taskctx *task.Context) error {
 		taskctx.SetTranslate(translate)
-		taskctx.SetDiarize(diarize)
+		taskctx.SetDiarize(types.PtrBool(req.Diarize))
+		taskctx.SetLanguagePerSegment(types.PtrBool(req.LanguagePerSegment))
 
 		// Set language
 		if language != "" {
@@ -95,9 +232,19 @@ func transcribe_file(ctx context.Context, service *whisper.Whisper, w http.Respo
 			}
 		}
 
+		// Restrict auto-detected language
+		if err := taskctx.SetAllowedLanguages(allowed...); err != nil {
+			return err
+		}
+
+		// Set vocabulary
+		if err := taskctx.SetVocabulary(vocabulary); err != nil {
+			return err
+		}
+
 		// Set temperature
-		if temperature != nil {
-			if err := taskctx.SetTemperature(types.PtrFloat64(temperature)); err != nil {
+		if req.Temperature != nil {
+			if err := taskctx.SetTemperature(types.PtrFloat64(req.Temperature)); err != nil {
 				return err
 			}
 		}
@@ -112,110 +259,407 @@ func transcribe_file(ctx context.Context, service *whisper.Whisper, w http.Respo
 		// Set response
 		result = taskctx.Result()
 
+		// Report progress to the stream, when the percentage changes
+		var progress task.ProgressFunc
+		if stream != nil {
+			percent := -1
+			progress = func(v float64) {
+				if p := int(v * 100); p != percent {
+					percent = p
+					stream.Event(schema.Event{
+						Type:     schema.TranscribeStreamProgressType,
+						Progress: types.Float64Ptr(v),
+					})
+				}
+			}
+		}
+
 		// Decode, resample and segment the audio file
-		return segment(ctx, taskctx, r, func(seg *schema.Segment) {
+		decode, err := segment(taskctx_, taskctx, in, maxDuration, progress, func(seg *schema.Segment) {
 			if stream == nil {
 				return
 			}
 
-			// If the language has changed, write a language event
-			if language != taskctx.Language() {
-				language = taskctx.Language()
-				stream.Write(schema.TranscribeStreamLanguageType, schema.Event{
-					Type: schema.TranscribeStreamLanguageType,
-					Text: language,
-				})
+			// The segment language is set when language is detected per
+			// segment
+			lang := taskctx.Language()
+			if seg.Language != "" {
+				lang = seg.Language
 			}
 
-			// Format the text into the correct format
-			var text bytes.Buffer
-			switch format {
-			case openai.FormatText:
-				text.WriteString(seg.Text)
-			case openai.FormatSrt:
-				seg.WriteSRT(&text, 0)
-			case openai.FormatVtt:
-				seg.WriteVTT(&text, 0)
-			case openai.FormatJson, openai.FormatVerboseJson:
-				json.NewEncoder(&text).Encode(seg)
+			// Translate the segment to the target language, or write it
+			if translator != nil {
+				translator.Add(lang, seg)
+			} else if err := emit(lang, seg); err != nil {
+				cancel(err)
 			}
-
-			// Write the segment to the stream
-			stream.Write(schema.TranscribeStreamDeltaType, schema.Event{
-				Type:  schema.TranscribeStreamDeltaType,
-				Delta: text.String(),
-			})
 		})
-	}); err != nil {
-		err := httpresponse.ErrInternalError.With(err.Error())
-		if stream != nil {
-			stream.Write(schema.TranscribeStreamErrorType, schema.Event{
-				Type: schema.TranscribeStreamErrorType,
-				Text: err.Error(),
-			})
-			return nil
-		} else {
-			return httpresponse.Error(w, err)
+		if err != nil {
+			return err
+		}
+
+		// Set the timings
+		timings = taskctx.Timings()
+		timings.AudioDecode = schema.Timestamp(decode)
+		return nil
+	})
+
+	// Wait for the streamed segments to be translated. The error which
+	// cancelled the task is returned rather than the cancellation
+	if translator != nil {
+		if terr := translator.Close(); err == nil {
+			err = terr
 		}
 	}
+	if cause := context.Cause(taskctx_); cause != nil && !errors.Is(cause, context.Canceled) {
+		err = cause
+	}
+	if err != nil {
+		service.Metrics().Error(model, kind)
+		return writeError(w, stream, err)
+	}
 
 	// Response to client
 	if stream == nil {
+		if target != "" {
+			if err := service.TranslateTranscription(ctx, target, result); err != nil {
+				service.Metrics().Error(model, kind)
+				return writeAPIError(w, httpresponse.ErrGatewayError.With(err.Error()))
+			}
+			result.Task = "translate"
+		}
+
+		// Set the timings in the response headers, and in the response
+		// for verbose_json
+		observe(ctx, service, model, kind, start, result, timings)
+		w.Header().Set("Server-Timing", serverTiming(timings))
+		if format == openai.FormatVerboseJson {
+			result.Timings = timings
+		}
 		return response(w, format, result)
 	} else {
-		stream.Write(schema.TranscribeStreamDoneType, schema.Event{
+		observe(ctx, service, model, kind, start, result, timings)
+		text := result.Text
+		if target != "" {
+			text = translated.String()
+		}
+		stream.Event(schema.Event{
 			Type: schema.TranscribeStreamDoneType,
-			Text: result.Text,
+			Text: text,
 		})
 		return nil
 	}
 }
 
-func segment(ctx context.Context, taskctx *task.Context, r io.Reader, fn func(seg *schema.Segment)) error {
-	// Create a segmenter
-	segmenter, err := segmenter.NewReader(r, whisper.SampleRate)
-	if err != nil {
-		return err
+// Return a translator for streamed segments, or nil when there is no stream
+// or target language. Errors from the translation backend are returned as
+// gateway errors
+func newStreamTranslator(ctx context.Context, service *whisper.Whisper, stream *eventStream, target string, cancel context.CancelCauseFunc, emit translate.EmitFunc) *translate.Stream {
+	if stream == nil || target == "" {
+		return nil
+	}
+	return translate.NewStream(ctx, func(ctx context.Context, source, target string, segments ...*schema.Segment) error {
+		if err := service.TranslateSegments(ctx, source, target, segments...); err != nil {
+			return httpresponse.ErrGatewayError.With(err.Error())
+		}
+		return nil
+	}, target, cancel, emit)
+}
+
+// Create an event stream, or return nil if the stream cannot be created
+func newEventStream(ctx context.Context, w http.ResponseWriter) *eventStream {
+	if stream := httpresponse.NewTextStream(w); stream == nil {
+		return nil
+	} else {
+		return &eventStream{stream, logging.RequestId(ctx)}
 	}
+}
 
-	// Read segments and perform transcription or  translation
-	if err := segmenter.DecodeFloat32(ctx, func(ts time.Duration, buf []float32) error {
-		return taskctx.Transcribe(ctx, ts, buf, fn)
-	}); err != nil {
-		return err
+// Write an event to the stream
+func (s *eventStream) Event(event schema.Event) {
+	event.RequestId = s.id
+	s.Write(event.Type, event)
+}
+
+// Write an error to the stream, or as a response if not streaming, in the
+// form returned by the OpenAI API
+func writeError(w http.ResponseWriter, stream *eventStream, err error) error {
+	if stream != nil {
+		_, response := errorResponse(err)
+		stream.Event(schema.Event{
+			Type:  schema.TranscribeStreamErrorType,
+			Text:  err.Error(),
+			Error: &response.Err,
+		})
+		return nil
+	} else {
+		return writeAPIError(w, err)
 	}
+}
 
-	// Return sucess
+// Read a request, returning a bad request error unless the body is larger
+// than the maximum upload size
+func readRequest(r *http.Request, v any) error {
+	var maxerr *http.MaxBytesError
+	if err := httprequest.Read(r, v); errors.As(err, &maxerr) {
+		return err
+	} else if err != nil {
+		return httpresponse.ErrBadRequest.With(err.Error())
+	}
 	return nil
 }
 
-func response(w http.ResponseWriter, format string, response *schema.Transcription) error {
-	switch strings.ToLower(format) {
-	case openai.FormatJson, openai.FormatVerboseJson:
-		return httpresponse.JSON(w, http.StatusOK, 2, response)
-	case openai.FormatText, "":
-		return httpresponse.Write(w, http.StatusOK, types.ContentTypeTextPlain, func(w io.Writer) (int, error) {
-			return w.Write([]byte(response.Text))
-		})
-	case openai.FormatSrt:
-		return httpresponse.Write(w, http.StatusOK, "application/x-subrip", func(w io.Writer) (int, error) {
-			for _, seg := range response.Segments {
-				task.WriteSegmentSrt(w, seg)
-			}
-			return 0, nil
-		})
-	case openai.FormatVtt:
-		return httpresponse.Write(w, http.StatusOK, "text/vtt", func(w io.Writer) (int, error) {
-			if _, err := w.Write([]byte("WEBVTT\n\n")); err != nil {
-				return 0, err
+// Return the uploaded file, or the body of the file URL when it is set
+func openFile(ctx context.Context, file io.Reader, fileurl *string, fetcher *fetch.Fetcher) (io.Reader, error) {
+	if fileurl == nil {
+		return file, nil
+	} else if file != nil {
+		return nil, newRequestError(http.StatusBadRequest, "file_url", codeInvalidParameter, "Only one of file and file_url can be set")
+	} else if fetcher == nil {
+		return nil, newRequestError(http.StatusBadRequest, "file_url", codeInvalidFileURL, "Reading audio from a URL is not enabled")
+	}
+
+	// Open the URL, the body is closed by the caller
+	ctx, span := tracing.Start(ctx, "audio.fetch")
+	r, err := fetcher.Open(ctx, types.PtrString(fileurl))
+	span.End(err)
+	var code httpresponse.Err
+	if errors.As(err, &code) {
+		return nil, newRequestError(int(code), "file_url", codeInvalidFileURL, "%v", err)
+	}
+	return r, err
+}
+
+// Create a segmenter for the audio at a sample rate, which probes the media.
+// The stream index, or the stream language of an uploaded file, selects an
+// audio stream other than the best audio stream. The media is seeked to the
+// start of the range.
+// Returns an error if there is no audio, it cannot be decoded or the range of
+// audio is longer than maxDuration
+func probe(ctx context.Context, r io.Reader, index *uint64, language *string, rng audio.Range, maxDuration time.Duration, sampleRate int) (_ *input, err error) {
+	_, span := tracing.Start(ctx, "audio.probe")
+	defer func() {
+		span.End(err)
+	}()
+
+	if r == nil {
+		return nil, newRequestError(http.StatusBadRequest, "file", codeMissingParameter, "Missing file")
+	}
+
+	// Select the stream by index or language
+	var opts []audio.Opt
+	param := "stream_index"
+	if language := strings.TrimSpace(types.PtrString(language)); language != "" {
+		param = "stream_language"
+		if index != nil {
+			return nil, newRequestError(http.StatusBadRequest, param, codeInvalidParameter, "stream_index and stream_language cannot both be set")
+		}
+		rs, ok := r.(io.ReadSeeker)
+		if !ok {
+			return nil, newRequestError(http.StatusBadRequest, param, codeInvalidParameter, "stream_language requires an uploaded file")
+		}
+		stream, err := audio.StreamLanguageReader(rs, language)
+		if errors.Is(err, ErrNotFound) {
+			return nil, newRequestError(http.StatusBadRequest, param, codeInvalidParameter, "%v", err)
+		} else if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, "file", codeInvalidMedia, "Unsupported or corrupt media: %v", err)
+		}
+		opts = append(opts, audio.OptStream(stream))
+	} else if index != nil {
+		opts = append(opts, audio.OptStream(int(*index)))
+	}
+	if rng.Start > 0 {
+		opts = append(opts, audio.OptStart(rng.Start))
+	}
+
+	// Decode the selected stream from the start of the range, or segment
+	// the best audio stream from the beginning
+	in := &input{rng: rng}
+	if len(opts) > 0 {
+		reader, err := ffmpeg.NewReader(r)
+		if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, "file", codeInvalidMedia, "Unsupported or corrupt media: %v", err)
+		}
+		in.duration = reader.Duration()
+		if stream, err := audio.Decode(ctx, reader, append(opts, audio.OptSampleRate(sampleRate))...); err != nil {
+			return nil, newRequestError(http.StatusBadRequest, param, codeInvalidParameter, "%v", err)
+		} else {
+			in.stream = stream
+			r = stream
+		}
+	}
+	if segmenter, err := segmenter.NewReader(r, sampleRate); err != nil {
+		in.Close()
+		return nil, newRequestError(http.StatusBadRequest, "file", codeInvalidMedia, "Unsupported or corrupt media: %v", err)
+	} else {
+		in.Segmenter = segmenter
+	}
+	if in.stream == nil {
+		in.duration = in.Segmenter.Duration()
+	}
+
+	// Check the duration of the range of audio
+	span.SetAttrs(tracing.Duration("duration", in.duration))
+	if maxDuration > 0 && rng.Length(in.duration) > maxDuration {
+		in.Close()
+		return nil, audioTooLong(maxDuration)
+	}
+	return in, nil
+}
+
+// Return the timestamp of the first sample of the decoded stream, or zero
+// when the audio is segmented from the beginning
+func (in *input) start() time.Duration {
+	if in.stream != nil {
+		return in.stream.Start()
+	}
+	return 0
+}
+
+// Close the segmenter, and the decoded stream
+func (in *input) Close() error {
+	var result error
+	if in.Segmenter != nil {
+		result = errors.Join(result, in.Segmenter.Close())
+	}
+	if in.stream != nil {
+		result = errors.Join(result, in.stream.Close())
+	}
+	return result
+}
+
+// Return the range of audio to transcribe, from the start and end in seconds
+func audioRange(start, end *float64) (audio.Range, error) {
+	secs := func(v *float64) time.Duration {
+		return time.Duration(types.PtrFloat64(v) * float64(time.Second))
+	}
+	if start != nil && *start < 0 {
+		return audio.Range{}, newRequestError(http.StatusBadRequest, "start", codeInvalidParameter, "start must not be negative")
+	} else if end != nil && *end <= types.PtrFloat64(start) {
+		return audio.Range{}, newRequestError(http.StatusBadRequest, "end", codeInvalidParameter, "end must be after start")
+	}
+	return audio.NewRange(secs(start), secs(end))
+}
+
+// Return an error for audio longer than the maximum duration
+func audioTooLong(maxDuration time.Duration) error {
+	return newRequestError(http.StatusBadRequest, "file", codeAudioTooLong, "Audio is longer than %v", maxDuration)
+}
+
+// Return the name of the target language for text translation, or empty if
+// the audio should not be translated, or whisper translates it to English
+func targetLanguage(service *whisper.Whisper, v *string, translate bool) (string, error) {
+	target := strings.TrimSpace(types.PtrString(v))
+	if target == "" {
+		return "", nil
+	}
+	code, _ := client.LanguageCode(target)
+	if code == "" {
+		return "", httpresponse.ErrBadRequest.Withf("Unsupported target language: %q", target)
+	} else if code == "en" && translate {
+		return "", nil
+	} else if !service.CanTranslateText() {
+		return "", httpresponse.ErrNotImplemented.Withf("Translation to %q requires a text translation backend", target)
+	}
+	name, _ := openai.LanguageCode(code)
+	return name, nil
+}
+
+// Decode and segment the audio, apply the filters, and transcribe each
+// segment within the range, which whisper skips to. Timestamps are relative
+// to the start of the audio. Decoding stops
+// when the range of audio is longer than maxDuration, when it is not zero, for
+// media without a duration. The audio which is transcribed is added to the
+// usage for the request, whether or not the transcription succeeds. Returns
+// the time spent decoding the audio, excluding the transcription
+func segment(ctx context.Context, taskctx *task.Context, in *input, maxDuration time.Duration, progress task.ProgressFunc, fn func(seg *schema.Segment)) (_ time.Duration, err error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "audio.segment", tracing.String("model", taskctx.Model()))
+	defer func() {
+		span.End(err)
+	}()
+	span.SetAttrs(tracing.Duration("duration", in.duration))
+	if !in.rng.IsZero() {
+		span.SetAttrs(tracing.Duration("start", in.rng.Start), tracing.Duration("end", in.rng.End))
+	}
+	if filters := in.filters.String(); filters != "" {
+		span.SetAttrs(tracing.String("preprocess", filters))
+	}
+
+	// Report progress across all segments, to the end of the range
+	if err := taskctx.SetRange(in.rng.Start, in.rng.End); err != nil {
+		return 0, err
+	}
+	if progress != nil {
+		taskctx.SetProgress(in.rng.Length(in.duration), progress)
+	}
+
+	// Read segments and perform transcription or translation, stopping at
+	// the end of the range
+	var transcribe, decoded time.Duration
+	defer func() {
+		limit.AddAudio(ctx, decoded)
+	}()
+	write, flush := audio.Filtered(in.filters, func(ts time.Duration, buf []float32) error {
+		clipped, samples, done := audio.Clip(in.rng, ts, buf, whisper.SampleRate)
+		if len(samples) > 0 {
+			length := time.Duration(len(samples)) * time.Second / whisper.SampleRate
+			if maxDuration > 0 && clipped+length-in.rng.Start > maxDuration {
+				return audioTooLong(maxDuration)
 			}
-			for _, seg := range response.Segments {
-				task.WriteSegmentVtt(w, seg)
+			decoded += length
+			start := time.Now()
+			if err := taskctx.Transcribe(ctx, ts, buf, fn); err != nil {
+				return err
 			}
-			return 0, nil
-		})
+			transcribe += time.Since(start)
+		}
+		return audio.EndOfRange(done)
+	})
+	err = in.DecodeFloat32(ctx, func(ts time.Duration, buf []float32) error {
+		return write(ts+in.start(), buf)
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil && !errors.Is(err, audio.ErrEndOfRange) {
+		return 0, err
 	}
 
-	// Error - invalid format
-	return httpresponse.ErrBadRequest.Withf("Invalid response format: %q", format)
+	// Return sucess
+	decode := time.Since(start) - transcribe
+	span.SetAttrs(tracing.Duration("audio_decode", decode), tracing.String("language", taskctx.Result().Language))
+	return decode, nil
+}
+
+// Set the total time and real time factor, and record the request metrics
+func observe(ctx context.Context, service *whisper.Whisper, model, kind string, start time.Time, result *schema.Transcription, timings *schema.Timings) {
+	timings.Total = schema.Timestamp(time.Since(start))
+	if result.Duration > 0 {
+		timings.RealTimeFactor = float64(timings.Total) / float64(result.Duration)
+	}
+	service.Metrics().Observe(model, kind, time.Duration(result.Duration), timings)
+}
+
+// Return the timings as a Server-Timing header value, with durations in
+// milliseconds
+func serverTiming(timings *schema.Timings) string {
+	metrics := []string{}
+	for _, metric := range []struct {
+		name  string
+		value schema.Timestamp
+	}{
+		{"audio", timings.AudioDecode},
+		{"wait", timings.Wait},
+		{"load", timings.Load},
+		{"detect", timings.Detect},
+		{"prepare", timings.Prepare},
+		{"first_token", timings.FirstToken},
+		{"tokens", timings.Tokens},
+		{"total", timings.Total},
+	} {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.1f", metric.name, float64(time.Duration(metric.value))/float64(time.Millisecond)))
+	}
+	metrics = append(metrics, fmt.Sprintf("rtf;desc=\"%.3f\"", timings.RealTimeFactor))
+	return strings.Join(metrics, ", ")
 }
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/httprequest"
+	"github.com/mutablelogic/go-server/pkg/httpresponse"
+	"github.com/mutablelogic/go-whisper/pkg/auth"
+	"github.com/mutablelogic/go-whisper/pkg/limit"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type queryUsage struct {
+	All bool `json:"all"`
+}
+
+type respUsage struct {
+	Object string        `json:"object"`
+	Limits limit.Limits  `json:"limits"`
+	Usage  []limit.Usage `json:"usage"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the daily usage for the API key or client address of the request.
+// When all is true, return the usage for all keys and addresses, which
+// requires the admin scope when API keys are set
+func GetUsage(ctx context.Context, w http.ResponseWriter, r *http.Request, limiter *limit.Limiter, keys *auth.Keys) error {
+	var query queryUsage
+	if err := httprequest.Query(r.URL.Query(), &query); err != nil {
+		return writeAPIError(w, httpresponse.ErrBadRequest.With(err.Error()))
+	}
+	if limiter == nil {
+		return writeAPIError(w, httpresponse.ErrNotFound.With("Usage is not recorded, the server has no limits"))
+	}
+
+	// Return usage for the request, or for all
+	id := limit.Id(r)
+	if query.All {
+		if keys.Len() > 0 && !auth.KeyFromContext(ctx).Has(auth.ScopeAdmin) {
+			return writeAPIError(w, httpresponse.ErrForbidden.With("Usage for all API keys requires the admin scope"))
+		}
+		id = ""
+	}
+
+	// Set the names of API keys, which are not unique
+	usage := limiter.Usage(id)
+	for i := range usage {
+		if key := keys.ById(usage[i].Id); key != nil {
+			usage[i].Name = key.Name
+		}
+	}
+	return httpresponse.JSON(w, http.StatusOK, 2, respUsage{
+		Object: "usage",
+		Limits: limiter.Limits,
+		Usage:  usage,
+	})
+}
@@ -0,0 +1,225 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	// Packages
+	media "github.com/mutablelogic/go-media"
+	ffmpeg "github.com/mutablelogic/go-media/pkg/ffmpeg"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type opts struct {
+	stream     int
+	start      time.Duration
+	realtime   bool
+	sampleRate int
+}
+
+type Opt func(*opts) error
+
+// Stream is a WAV stream of decoded audio, which is read as the audio is
+// decoded
+type Stream struct {
+	*io.PipeReader
+	start atomic.Int64
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Sample rate of decoded audio
+	SampleRate = 16000
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Decode an audio stream to a WAV stream of 16kHz mono 16-bit samples,
+// or another sample rate set with OptSampleRate, which is read as the audio
+// is decoded. The best audio stream is decoded
+// unless a stream is set with OptStream, from the start of the audio unless a
+// start is set with OptStart. The media reader is closed when the WAV stream
+// is closed, or on error
+func Decode(ctx context.Context, reader *ffmpeg.Reader, opt ...Opt) (*Stream, error) {
+	o := opts{stream: -1, sampleRate: SampleRate}
+	for _, fn := range opt {
+		if err := fn(&o); err != nil {
+			reader.Close()
+			return nil, err
+		}
+	}
+
+	// Check the stream
+	if o.stream < 0 {
+		o.stream = reader.BestStream(media.AUDIO)
+	}
+	if streams := Streams(reader); len(streams) == 0 {
+		reader.Close()
+		return nil, ErrBadParameter.With("no audio streams")
+	} else if !slices.Contains(streams, o.stream) {
+		reader.Close()
+		return nil, ErrBadParameter.Withf("stream %d is not an audio stream, audio streams are %v", o.stream, streams)
+	}
+
+	// Seek the demuxer to the start. Media which cannot be seeked, such as
+	// a pipe, is decoded from the beginning and the audio before the start
+	// is skipped
+	if o.start > 0 {
+		reader.Seek(o.stream, o.start.Seconds())
+	}
+
+	// Decode the stream to samples, which are written to a pipe until the
+	// context is cancelled or the pipe is closed
+	r, w := io.Pipe()
+	stream := &Stream{PipeReader: r}
+	go func() {
+		defer reader.Close()
+		w.CloseWithError(o.decode(ctx, reader, w, &stream.start))
+	}()
+
+	// Return the pipe, which stops decoding when closed
+	return stream, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Decode an audio stream by index, rather than the best audio stream
+func OptStream(index int) Opt {
+	return func(o *opts) error {
+		if index < 0 {
+			return ErrBadParameter.Withf("invalid stream %d", index)
+		}
+		o.stream = index
+		return nil
+	}
+}
+
+// Decode audio from a start time. The demuxer seeks to the start, which
+// usually lands a little before the start, and the timestamp of the first
+// decoded sample is returned by the Start method of the stream
+func OptStart(start time.Duration) Opt {
+	return func(o *opts) error {
+		if start < 0 {
+			return ErrBadParameter.Withf("invalid start %v", start)
+		}
+		o.start = start
+		return nil
+	}
+}
+
+// Decode audio no faster than it would be heard, for reading files as if
+// they were a live stream
+func OptRealtime() Opt {
+	return func(o *opts) error {
+		o.realtime = true
+		return nil
+	}
+}
+
+// Decode audio at a sample rate, rather than SampleRate
+func OptSampleRate(sampleRate int) Opt {
+	return func(o *opts) error {
+		if sampleRate <= 0 {
+			return ErrBadParameter.Withf("invalid sample rate %d", sampleRate)
+		}
+		o.sampleRate = sampleRate
+		return nil
+	}
+}
+
+// Return the indexes of the audio streams
+func Streams(reader *ffmpeg.Reader) []int {
+	var result []int
+	for _, stream := range reader.Streams(media.AUDIO) {
+		result = append(result, stream.Index())
+	}
+	return result
+}
+
+// Return the timestamp of the first sample of the stream, which is at or a
+// little before the start set with OptStart. It is zero when decoding from
+// the beginning, and is set once samples have been read from the stream
+func (s *Stream) Start() time.Duration {
+	return time.Duration(s.start.Load())
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Decode the stream, and write the samples to a WAV stream with the ffmpeg
+// wav muxer. The output cannot be seeked, so the muxer writes a header for a
+// stream of unknown length. When decoding from a start, frames which end
+// before the start are skipped and the timestamp of the first frame is
+// stored in start
+func (o opts) decode(ctx context.Context, reader *ffmpeg.Reader, w io.Writer, start *atomic.Int64) error {
+	writer, err := ffmpeg.NewWriter(w, ffmpeg.OptOutputFormat("wav"), ffmpeg.OptStream(1, ffmpeg.AudioPar("s16", "mono", o.sampleRate)))
+	if err != nil {
+		return err
+	}
+	encoder := writer.Stream(1)
+
+	// Encode the samples, with timestamps from the start of the stream
+	var pts int64
+	started := time.Now()
+	if err := reader.Decode(ctx, func(stream int, par *ffmpeg.Par) (*ffmpeg.Par, error) {
+		if stream == o.stream {
+			return ffmpeg.NewAudioPar("s16", "mono", o.sampleRate)
+		}
+		return nil, nil
+	}, func(stream int, frame *ffmpeg.Frame) error {
+		if frame == nil || frame.NumSamples() == 0 {
+			return nil
+		}
+
+		// Skip frames which end before the start, and store the timestamp
+		// of the first frame which is encoded
+		if o.start > 0 && pts == 0 {
+			ts := o.start
+			if secs := frame.Ts(); secs != ffmpeg.TS_UNDEFINED {
+				ts = time.Duration(secs * float64(time.Second))
+			}
+			if ts+duration(frame.NumSamples(), o.sampleRate) <= o.start {
+				return nil
+			}
+			start.Store(int64(ts))
+		}
+		frame.SetPts(pts)
+		pts += int64(frame.NumSamples())
+		if err := encoder.Encode(frame, writer.Write); err != nil {
+			return err
+		}
+
+		// Wait until the samples would have been heard
+		if o.realtime {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Until(started.Add(duration(int(pts), o.sampleRate)))):
+			}
+		}
+		return nil
+	}); err != nil {
+		return errors.Join(err, writer.Close())
+	}
+
+	// Flush the encoder, and write the trailer
+	return errors.Join(encoder.Encode(nil, writer.Write), writer.Close())
+}
+
+// Return the duration of a number of samples
+func duration(n, sampleRate int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(sampleRate)
+}
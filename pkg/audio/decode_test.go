@@ -0,0 +1,99 @@
+package audio_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	// Packages
+	ffmpeg "github.com/mutablelogic/go-media/pkg/ffmpeg"
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
+	assert "github.com/stretchr/testify/assert"
+)
+
+const SAMPLE_EN = "../../samples/jfk.wav"
+
+// Decode a file, and return the WAV stream and the time taken to read a
+// duration of audio from it
+func readDecoded(t *testing.T, d time.Duration, opt ...audio.Opt) ([]byte, time.Duration) {
+	t.Helper()
+	reader, err := ffmpeg.Open(SAMPLE_EN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := audio.Decode(context.Background(), reader, opt...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Read the header, of about 44 bytes, and 16-bit samples for the duration
+	start := time.Now()
+	data := make([]byte, 44+int(d.Seconds()*audio.SampleRate)*2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		t.Fatal(err)
+	}
+	return data, time.Since(start)
+}
+
+func Test_decode_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// The audio is decoded to a WAV stream
+	data, elapsed := readDecoded(t, 2*time.Second)
+	assert.Equal("RIFF", string(data[0:4]))
+	assert.Equal("WAVE", string(data[8:12]))
+	assert.Less(elapsed, time.Second)
+}
+
+func Test_decode_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// In real time, the audio is read no faster than it would be heard
+	_, elapsed := readDecoded(t, 2*time.Second, audio.OptRealtime())
+	assert.Greater(elapsed, 1500*time.Millisecond)
+	assert.Less(elapsed, 3*time.Second)
+}
+
+func Test_decode_003(t *testing.T) {
+	assert := assert.New(t)
+	reader, err := ffmpeg.Open(SAMPLE_EN)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.Equal([]int{0}, audio.Streams(reader))
+
+	// Streams which are not audio streams are an error
+	_, err = audio.Decode(context.Background(), reader, audio.OptStream(1))
+	assert.Error(err)
+}
+
+func Test_decode_004(t *testing.T) {
+	assert := assert.New(t)
+	reader, err := ffmpeg.Open(SAMPLE_EN)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	// The audio is decoded from a start, and the first sample is at or a
+	// little before the start
+	r, err := audio.Decode(context.Background(), reader, audio.OptStart(5*time.Second))
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	defer r.Close()
+	data := make([]byte, 44+audio.SampleRate*2)
+	if _, err := io.ReadFull(r, data); assert.NoError(err) {
+		assert.LessOrEqual(r.Start(), 5*time.Second)
+		assert.Greater(r.Start(), 4*time.Second)
+	}
+
+	// Negative starts are an error
+	reader, err = ffmpeg.Open(SAMPLE_EN)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	_, err = audio.Decode(context.Background(), reader, audio.OptStart(-time.Second))
+	assert.Error(err)
+}
@@ -0,0 +1,192 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+
+	// Packages
+	ff "github.com/mutablelogic/go-media/sys/ffmpeg71"
+	ffsys "github.com/mutablelogic/go-whisper/sys/ffmpeg"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// graph passes mono float32 samples through an ffmpeg filter graph, from an
+// abuffer filter to an abuffersink filter. Filters hold samples between
+// writes, which are returned as they are filtered
+type graph struct {
+	ctx       *ff.AVFilterGraph
+	src, sink *ff.AVFilterContext
+	in, out   *ff.AVFrame
+	rate      int
+	pts       int64
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a filter graph from comma-separated ffmpeg filters, for mono float32
+// samples at an input sample rate. The graph resamples the filtered audio to
+// mono float32 samples at an output sample rate, with the swresample options,
+// which are also used for any other change of sample rate in the graph
+func newGraph(filters string, inRate, outRate int, resample string) (*graph, error) {
+	g := &graph{ctx: ff.AVFilterGraph_alloc(), rate: inRate}
+	if g.ctx == nil {
+		return nil, ErrInternalAppError.With("avfilter_graph_alloc")
+	}
+	ffsys.AVFilterGraph_set_resample_opts(g.ctx, resample)
+
+	// Create the source and sink of the graph
+	if src, err := g.filter("abuffer", "in", fmt.Sprintf("sample_rate=%d:sample_fmt=flt:channel_layout=mono:time_base=1/%d", inRate, inRate)); err != nil {
+		g.Close()
+		return nil, err
+	} else {
+		g.src = src
+	}
+	if sink, err := g.filter("abuffersink", "out", ""); err != nil {
+		g.Close()
+		return nil, err
+	} else {
+		g.sink = sink
+	}
+
+	// Parse the filters, which are followed by the conversion to the output
+	// format, and link them to the source and sink
+	chain := []string{}
+	if filters != "" {
+		chain = append(chain, filters)
+	}
+	if resample != "" {
+		chain = append(chain, fmt.Sprintf("aresample=%d:%s", outRate, resample))
+	} else {
+		chain = append(chain, fmt.Sprintf("aresample=%d", outRate))
+	}
+	chain = append(chain, fmt.Sprintf("aformat=sample_fmts=flt:channel_layouts=mono:sample_rates=%d", outRate))
+	if err := g.link(strings.Join(chain, ",")); err != nil {
+		g.Close()
+		return nil, err
+	}
+
+	// Configure the graph, which checks the filter options
+	if err := ff.AVFilterGraph_config(g.ctx); err != nil {
+		g.Close()
+		return nil, ErrBadParameter.With(err)
+	}
+
+	// Allocate the frames
+	g.in, g.out = ff.AVUtil_frame_alloc(), ff.AVUtil_frame_alloc()
+	if g.in == nil || g.out == nil {
+		g.Close()
+		return nil, ErrInternalAppError.With("av_frame_alloc")
+	}
+
+	// Return success
+	return g, nil
+}
+
+// Release the graph and frames
+func (g *graph) Close() error {
+	if g.in != nil {
+		ff.AVUtil_frame_free(g.in)
+	}
+	if g.out != nil {
+		ff.AVUtil_frame_free(g.out)
+	}
+	if g.ctx != nil {
+		ff.AVFilterGraph_free(g.ctx)
+	}
+	g.ctx, g.src, g.sink, g.in, g.out = nil, nil, nil, nil, nil
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Add samples to the graph, and return the samples which have been filtered
+func (g *graph) Write(samples []float32) ([]float32, error) {
+	if len(samples) == 0 {
+		return nil, nil
+	}
+
+	// Copy the samples to a frame, which is added to the source
+	ff.AVUtil_frame_unref(g.in)
+	g.in.SetNumSamples(len(samples))
+	g.in.SetSampleFormat(ff.AV_SAMPLE_FMT_FLT)
+	g.in.SetSampleRate(g.rate)
+	g.in.SetPts(g.pts)
+	if err := g.in.SetChannelLayout(ff.AV_CHANNEL_LAYOUT_MONO); err != nil {
+		return nil, err
+	}
+	if err := ff.AVUtil_frame_get_buffer(g.in, false); err != nil {
+		return nil, err
+	}
+	copy(g.in.Float32(0), samples)
+	g.pts += int64(len(samples))
+	if err := ffsys.AVBufferSrc_add_frame_flags(g.src, g.in, 0); err != nil {
+		return nil, err
+	}
+
+	// Return the filtered samples
+	return g.read()
+}
+
+// Mark the end of the samples, and return the samples held by the filters
+func (g *graph) Flush() ([]float32, error) {
+	if err := ffsys.AVBufferSrc_add_frame_flags(g.src, nil, 0); err != nil {
+		return nil, err
+	}
+	return g.read()
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Create a filter in the graph, by filter name
+func (g *graph) filter(name, instance, args string) (*ff.AVFilterContext, error) {
+	filter := ff.AVFilter_get_by_name(name)
+	if filter == nil {
+		return nil, ErrNotImplemented.Withf("ffmpeg filter %q is not available", name)
+	}
+	return ff.AVFilterGraph_create_filter(g.ctx, filter, instance, args)
+}
+
+// Parse a chain of filters, and link the input of the chain to the source
+// and the output of the chain to the sink
+func (g *graph) link(chain string) error {
+	ins, outs, err := ff.AVFilterGraph_parse(g.ctx, chain)
+	if err != nil {
+		return ErrBadParameter.With(err)
+	}
+	defer ff.AVFilterInOut_list_free(ins)
+	defer ff.AVFilterInOut_list_free(outs)
+	if len(ins) != 1 || len(outs) != 1 {
+		return ErrBadParameter.Withf("filters %q do not have one input and one output", chain)
+	}
+	if err := ffsys.AVFilter_link(g.src, 0, ins[0].Filter(), ins[0].Pad()); err != nil {
+		return err
+	}
+	return ffsys.AVFilter_link(outs[0].Filter(), outs[0].Pad(), g.sink, 0)
+}
+
+// Return the samples from the sink, until more samples are needed or the
+// end of the samples
+func (g *graph) read() ([]float32, error) {
+	var result []float32
+	for {
+		err := ffsys.AVBufferSink_get_frame(g.sink, g.out)
+		if errors.Is(err, syscall.EAGAIN) || errors.Is(err, io.EOF) {
+			return result, nil
+		} else if err != nil {
+			return nil, err
+		}
+		result = append(result, g.out.Float32(0)[:g.out.NumSamples()]...)
+		ff.AVUtil_frame_unref(g.out)
+	}
+}
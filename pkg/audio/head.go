@@ -0,0 +1,41 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	// Packages
+	segmenter "github.com/mutablelogic/go-media/pkg/segmenter"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return up to duration of 16kHz mono samples from the start of the audio,
+// for language detection. Only the start of the audio is decoded
+func Head(ctx context.Context, r io.Reader, duration time.Duration) ([]float32, error) {
+	segmenter, err := segmenter.NewReader(r, SampleRate, segmenter.WithSegmentSize(duration))
+	if err != nil {
+		return nil, err
+	}
+	defer segmenter.Close()
+
+	// Read the first segment only
+	var samples []float32
+	if err := segmenter.DecodeFloat32(ctx, func(ts time.Duration, buf []float32) error {
+		samples = append(samples, buf...)
+		return io.EOF
+	}); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	// Truncate to the duration
+	if n := count(duration, SampleRate); len(samples) > n {
+		samples = samples[:n]
+	}
+
+	// Return success
+	return samples, nil
+}
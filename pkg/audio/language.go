@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	// Packages
+	ff "github.com/mutablelogic/go-media/sys/ffmpeg71"
+	ffsys "github.com/mutablelogic/go-whisper/sys/ffmpeg"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// readerCallback reads media for the demuxer from a reader which can seek
+type readerCallback struct {
+	io.ReadSeeker
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Size of the buffer for reading media
+	bufSize = 4096
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the index of the first audio stream of a file or URL with a
+// language in the stream metadata, which is usually an ISO 639-2 code such
+// as "eng". The language is matched without case
+func StreamLanguage(url, language string) (int, error) {
+	input, err := ff.AVFormat_open_url(url, nil, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer ff.AVFormat_close_input(input)
+	return streamLanguage(input, language)
+}
+
+// Return the index of the first audio stream of media with a language in the
+// stream metadata. The media is read from the reader, which is then seeked
+// back to the start so that the media can be decoded
+func StreamLanguageReader(r io.ReadSeeker, language string) (int, error) {
+	avio := ff.AVFormat_avio_alloc_context(bufSize, false, readerCallback{r})
+	if avio == nil {
+		return -1, errors.New("failed to allocate avio context")
+	}
+	defer ff.AVFormat_avio_context_free(avio)
+	input, err := ff.AVFormat_open_reader(avio, nil, nil)
+	if err != nil {
+		return -1, err
+	}
+	index, err := streamLanguage(input, language)
+	ff.AVFormat_close_input(input)
+
+	// Seek back to the start of the media
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return -1, err
+	}
+	return index, err
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the index of the first audio stream with a language
+func streamLanguage(input *ff.AVFormatContext, language string) (int, error) {
+	if err := ff.AVFormat_find_stream_info(input, nil); err != nil {
+		return -1, err
+	}
+
+	// Find the stream
+	var languages []string
+	for _, stream := range input.Streams() {
+		if stream.CodecPar().CodecType() != ff.AVMEDIA_TYPE_AUDIO {
+			continue
+		}
+		value := ffsys.AVStream_language(stream)
+		if strings.EqualFold(value, language) {
+			return stream.Index(), nil
+		} else if value != "" {
+			languages = append(languages, value)
+		}
+	}
+
+	// Return not found
+	if len(languages) == 0 {
+		return -1, ErrNotFound.Withf("no audio stream with language %q, the audio streams have no language", language)
+	}
+	return -1, ErrNotFound.Withf("no audio stream with language %q, languages are %v", language, languages)
+}
+
+func (r readerCallback) Reader(buf []byte) int {
+	n, err := r.Read(buf)
+	if n == 0 && err != nil {
+		return ff.AVERROR_EOF
+	}
+	return n
+}
+
+func (r readerCallback) Seeker(offset int64, whence int) int64 {
+	whence &^= ff.AVSEEK_FORCE
+	switch whence {
+	case io.SeekStart, io.SeekCurrent, io.SeekEnd:
+		if n, err := r.Seek(offset, whence); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+func (r readerCallback) Writer([]byte) int {
+	return ff.AVERROR_EOF
+}
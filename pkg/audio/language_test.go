@@ -0,0 +1,31 @@
+package audio_test
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	// Packages
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_language_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// The sample has no stream language
+	_, err := audio.StreamLanguage(SAMPLE_EN, "eng")
+	assert.ErrorContains(err, "the audio streams have no language")
+
+	// The media is read from a reader, which is seeked back to the start
+	f, err := os.Open(SAMPLE_EN)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	defer f.Close()
+	_, err = audio.StreamLanguageReader(f, "eng")
+	assert.ErrorContains(err, "the audio streams have no language")
+	pos, err := f.Seek(0, io.SeekCurrent)
+	assert.NoError(err)
+	assert.Zero(pos)
+}
@@ -0,0 +1,435 @@
+package audio
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Filter processes mono samples in place. Filters keep state between calls,
+// so that consecutive segments of audio are filtered as one stream
+type Filter interface {
+	Process(samples []float32)
+}
+
+// Preprocess is a chain of filters, which are applied in order to audio
+// before it is transcribed. The ffmpeg filters are applied first, in an
+// ffmpeg filter graph which resamples the audio to the output sample rate,
+// and then the filters implemented in Go
+type Preprocess struct {
+	filters    []Filter
+	names      []string
+	graph      *graph
+	sampleRate int
+	resample   bool
+}
+
+// highpass is a second-order Butterworth high-pass filter
+type highpass struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// gain brings the RMS level of each call towards a target level. The gain
+// changes gradually across the samples, and the output is limited to full
+// scale. It is not the EBU R128 loudness normalization of the ffmpeg
+// loudnorm filter
+type gain struct {
+	target float64
+	gain   float64
+}
+
+// gate attenuates audio below a threshold level, in short frames, so that
+// audio between speech is quieter. It does not remove noise from speech
+type gate struct {
+	threshold float64
+	frame     int
+	gain      float64
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultHighpass  = 100   // Cutoff frequency in Hz
+	defaultGain      = -20.0 // Target RMS level in dBFS
+	defaultGate      = -45.0 // Threshold level in dBFS
+	maxGain          = 10.0  // Maximum gain, 20dB
+	silenceLevel     = -60.0 // Level in dBFS below which the gain is not changed
+	gateAttenuation  = 0.1   // Gain below the threshold, -20dB
+	gateFrame        = 10    // Frame length in milliseconds
+	gateAttackFactor = 0.5   // Change in gain between frames of the gate
+
+	// Sample rate of audio which is resampled by the ffmpeg filter graph,
+	// when the resampler options are set
+	ResampleRate = 48000
+)
+
+var (
+	// ffmpeg filters, which are passed their options. The options cannot
+	// contain the characters which separate filters in a filter graph
+	ffmpegFilters  = []string{"afftdn", "arnndn", "loudnorm"}
+	ffmpegReserved = ",;[]'\\\""
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return a chain of filters from a comma-separated list of filter names, each
+// with an optional value as name=value, for audio at a sample rate. The
+// highpass, gain and gate filters are implemented in Go, and have a number as
+// the value. The afftdn, arnndn and loudnorm filters are ffmpeg filters, and
+// have the ffmpeg filter options as the value, such as afftdn=nr=20:nf=-40
+// or arnndn=m=model.rnnn. The resample filter sets the swresample options of
+// the ffmpeg aresample filter, such as resample=resampler=soxr or
+// resample=filter_size=64:cutoff=0.97, and audio is then decoded at
+// ResampleRate and resampled by the filter graph. The filters are closed
+// with the Close method
+func NewPreprocess(v string, sampleRate int) (*Preprocess, error) {
+	result := &Preprocess{sampleRate: sampleRate}
+	var ffmpeg []string
+	var resample string
+	for _, filter := range strings.Split(v, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(filter), "=")
+		if name = strings.ToLower(name); name == "" {
+			continue
+		}
+
+		// ffmpeg filters, and the resampler options
+		if name == "resample" || slices.Contains(ffmpegFilters, name) {
+			if strings.ContainsAny(value, ffmpegReserved) {
+				return nil, ErrBadParameter.Withf("invalid value for %q: %q", name, value)
+			}
+			switch {
+			case name == "resample" && value == "":
+				return nil, ErrBadParameter.With("resample requires swresample options, such as resample=resampler=soxr")
+			case name == "resample":
+				resample = value
+			case name == "arnndn" && value == "":
+				return nil, ErrBadParameter.With("arnndn requires a model file, such as arnndn=m=model.rnnn")
+			case value == "":
+				ffmpeg = append(ffmpeg, name)
+			default:
+				ffmpeg = append(ffmpeg, name+"="+value)
+			}
+			result.names = append(result.names, name)
+			continue
+		}
+
+		// Filters implemented in Go
+		var arg *float64
+		if value != "" {
+			if v, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, ErrBadParameter.Withf("invalid value for %q: %q", name, value)
+			} else {
+				arg = &v
+			}
+		}
+		switch name {
+		case "highpass":
+			if f, err := newHighpass(valueOr(arg, defaultHighpass), sampleRate); err != nil {
+				return nil, err
+			} else {
+				result.filters = append(result.filters, f)
+			}
+		case "gain":
+			if f, err := newGain(valueOr(arg, defaultGain)); err != nil {
+				return nil, err
+			} else {
+				result.filters = append(result.filters, f)
+			}
+		case "gate":
+			if f, err := newGate(valueOr(arg, defaultGate), sampleRate); err != nil {
+				return nil, err
+			} else {
+				result.filters = append(result.filters, f)
+			}
+		default:
+			return nil, ErrBadParameter.Withf("unsupported filter %q", name)
+		}
+		result.names = append(result.names, name)
+	}
+
+	// Create the ffmpeg filter graph, which checks the filter options
+	if len(ffmpeg) > 0 || resample != "" {
+		rate := sampleRate
+		if resample != "" {
+			rate, result.resample = ResampleRate, true
+		}
+		if graph, err := newGraph(strings.Join(ffmpeg, ","), rate, sampleRate, resample); err != nil {
+			return nil, err
+		} else {
+			result.graph = graph
+		}
+	}
+
+	// Return success
+	return result, nil
+}
+
+// Release the ffmpeg filter graph
+func (p *Preprocess) Close() error {
+	var result error
+	if p != nil && p.graph != nil {
+		result = p.graph.Close()
+		p.graph = nil
+	}
+	return result
+}
+
+func newHighpass(cutoff float64, sampleRate int) (*highpass, error) {
+	if cutoff <= 0 || cutoff >= float64(sampleRate)/2 {
+		return nil, ErrBadParameter.Withf("highpass cutoff %vHz is not between 0 and %vHz", cutoff, sampleRate/2)
+	}
+
+	// Coefficients from the Audio EQ Cookbook, with a Q of 1/sqrt(2)
+	w0 := 2 * math.Pi * cutoff / float64(sampleRate)
+	alpha := math.Sin(w0) / math.Sqrt2
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+	return &highpass{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}, nil
+}
+
+func newGain(target float64) (*gain, error) {
+	if target >= 0 || target < silenceLevel {
+		return nil, ErrBadParameter.Withf("gain target %vdBFS is not between %vdBFS and 0dBFS", target, silenceLevel)
+	}
+	return &gain{target: level(target), gain: 1}, nil
+}
+
+func newGate(threshold float64, sampleRate int) (*gate, error) {
+	if threshold >= 0 {
+		return nil, ErrBadParameter.Withf("gate threshold %vdBFS is not below 0dBFS", threshold)
+	}
+	return &gate{threshold: level(threshold), frame: sampleRate * gateFrame / 1000, gain: 1}, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (p *Preprocess) String() string {
+	if p == nil {
+		return ""
+	}
+	return strings.Join(p.names, ",")
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the sample rate of audio which is filtered, which is ResampleRate
+// when the ffmpeg filter graph resamples the audio. Filtered audio is at the
+// sample rate of the filters
+func (p *Preprocess) SampleRate() int {
+	switch {
+	case p == nil:
+		return SampleRate
+	case p.resample:
+		return ResampleRate
+	default:
+		return p.sampleRate
+	}
+}
+
+// Return a function which filters each segment of audio with a timestamp,
+// and calls fn with the filtered audio, and a function which calls fn with
+// the audio held by the ffmpeg filters at the end of the audio. Audio is
+// filtered at SampleRate, and passed to fn at the sample rate of the
+// filters. The ffmpeg filters hold audio between segments, so fn can be
+// called with more or fewer samples than the segment, and timestamps are
+// from the first segment and the number of filtered samples
+func Filtered[T Sample](p *Preprocess, fn func(time.Duration, []T) error) (func(time.Duration, []T) error, func() error) {
+	// Filter segments in place without an ffmpeg filter graph
+	if p == nil || p.graph == nil {
+		return func(ts time.Duration, samples []T) error {
+			p.process(samples)
+			return fn(ts, samples)
+		}, func() error { return nil }
+	}
+
+	// Pass the filtered samples to fn, with timestamps from the first segment
+	var start time.Duration
+	var started bool
+	var n int
+	emit := func(samples []float32) error {
+		if len(samples) == 0 {
+			return nil
+		}
+		for _, f := range p.filters {
+			f.Process(samples)
+		}
+		ts := start + duration(n, p.sampleRate)
+		n += len(samples)
+		return fn(ts, fromFloat32[T](samples))
+	}
+	write := func(ts time.Duration, samples []T) error {
+		if !started {
+			start, started = ts, true
+		}
+		if filtered, err := p.graph.Write(toFloat32(samples)); err != nil {
+			return err
+		} else {
+			return emit(filtered)
+		}
+	}
+
+	// Flush the samples held by the ffmpeg filters
+	flush := func() error {
+		if filtered, err := p.graph.Flush(); err != nil {
+			return err
+		} else {
+			return emit(filtered)
+		}
+	}
+
+	// Return the functions
+	return write, flush
+}
+
+func (f *highpass) Process(samples []float32) {
+	for i, sample := range samples {
+		x := float64(sample)
+		y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+		f.x1, f.x2 = x, f.x1
+		f.y1, f.y2 = y, f.y1
+		samples[i] = float32(y)
+	}
+}
+
+func (f *gain) Process(samples []float32) {
+	if len(samples) == 0 {
+		return
+	}
+
+	// Keep the gain for silence, so that noise is not amplified
+	target := f.gain
+	if rms := rms(samples); rms > level(silenceLevel) {
+		target = min(f.target/rms, maxGain)
+	}
+
+	// Change the gain across the samples
+	step := (target - f.gain) / float64(len(samples))
+	for i, sample := range samples {
+		f.gain += step
+		samples[i] = clamp(float32(float64(sample) * f.gain))
+	}
+	f.gain = target
+}
+
+func (f *gate) Process(samples []float32) {
+	for start := 0; start < len(samples); start += f.frame {
+		frame := samples[start:min(start+f.frame, len(samples))]
+
+		// Move the gain towards the gain for the level of the frame
+		target := 1.0
+		if rms(frame) < f.threshold {
+			target = gateAttenuation
+		}
+		next := f.gain + (target-f.gain)*gateAttackFactor
+		step := (next - f.gain) / float64(len(frame))
+		for i, sample := range frame {
+			f.gain += step
+			frame[i] = float32(float64(sample) * f.gain)
+		}
+		f.gain = next
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Apply the Go filters to samples in place, as float32 samples
+func (p *Preprocess) process(samples any) {
+	if p == nil || len(p.filters) == 0 {
+		return
+	}
+	switch samples := samples.(type) {
+	case []float32:
+		for _, f := range p.filters {
+			f.Process(samples)
+		}
+	case []int16:
+		buf := toFloat32(samples)
+		for _, f := range p.filters {
+			f.Process(buf)
+		}
+		for i, sample := range buf {
+			samples[i] = int16(clamp(sample) * math.MaxInt16)
+		}
+	}
+}
+
+// Return samples as float32 samples, which are the same samples when they
+// are float32 samples
+func toFloat32[T Sample](samples []T) []float32 {
+	switch samples := any(samples).(type) {
+	case []float32:
+		return samples
+	case []int16:
+		result := make([]float32, len(samples))
+		for i, sample := range samples {
+			result[i] = float32(sample) / math.MaxInt16
+		}
+		return result
+	}
+	return nil
+}
+
+// Return float32 samples as samples of another type
+func fromFloat32[T Sample](samples []float32) []T {
+	var result []T
+	switch result := any(&result).(type) {
+	case *[]float32:
+		*result = samples
+	case *[]int16:
+		*result = make([]int16, len(samples))
+		for i, sample := range samples {
+			(*result)[i] = int16(clamp(sample) * math.MaxInt16)
+		}
+	}
+	return result
+}
+
+// Return the value, or a default when it is nil
+func valueOr(v *float64, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// Return the level of a value in dBFS, as a linear value
+func level(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// Return the root mean square of samples
+func rms(samples []float32) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, sample := range samples {
+		sum += float64(sample) * float64(sample)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// Limit a sample to full scale
+func clamp(v float32) float32 {
+	return max(-1, min(1, v))
+}
@@ -0,0 +1,225 @@
+package audio_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	// Packages
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
+	assert "github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+const sampleRate = 16000
+
+// Return a second of a sine wave at a frequency and amplitude
+func sine(freq, amplitude float64) []float32 {
+	samples := make([]float32, sampleRate)
+	for i := range samples {
+		samples[i] = float32(amplitude * math.Sin(2*math.Pi*freq*float64(i)/sampleRate))
+	}
+	return samples
+}
+
+// Return the peak amplitude of samples, after the first tenth of a second
+func peak(samples []float32) float64 {
+	var result float64
+	for _, sample := range samples[sampleRate/10:] {
+		result = max(result, math.Abs(float64(sample)))
+	}
+	return result
+}
+
+// Return a second of a sine wave at a frequency and amplitude, at a sample
+// rate
+func sineAt(rate int, freq, amplitude float64) []float32 {
+	samples := make([]float32, rate)
+	for i := range samples {
+		samples[i] = float32(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return samples
+}
+
+// Filter samples in segments of a tenth of a second, and return the
+// filtered samples
+func filter(t *testing.T, p *audio.Preprocess, samples []float32) []float32 {
+	t.Helper()
+	var result []float32
+	fn, flush := audio.Filtered(p, func(ts time.Duration, samples []float32) error {
+		assert.Equal(t, time.Duration(len(result))*time.Second/sampleRate, ts)
+		result = append(result, samples...)
+		return nil
+	})
+	n := p.SampleRate() / 10
+	for i := 0; i < len(samples); i += n {
+		segment := samples[i:min(i+n, len(samples))]
+		if !assert.NoError(t, fn(time.Duration(i)*time.Second/time.Duration(p.SampleRate()), segment)) {
+			t.FailNow()
+		}
+	}
+	if !assert.NoError(t, flush()) {
+		t.FailNow()
+	}
+	return result
+}
+
+func Test_preprocess_001(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := audio.NewPreprocess("", sampleRate)
+	assert.NoError(err)
+	assert.Equal("", p.String())
+	assert.Equal(sampleRate, p.SampleRate())
+	assert.NoError(p.Close())
+
+	p, err = audio.NewPreprocess("highpass, gain=-18,gate", sampleRate)
+	assert.NoError(err)
+	assert.Equal("highpass,gain,gate", p.String())
+
+	// The ffmpeg filters are passed their options
+	p, err = audio.NewPreprocess("highpass,afftdn=nr=20:nf=-40,loudnorm", sampleRate)
+	if assert.NoError(err) {
+		assert.Equal("highpass,afftdn,loudnorm", p.String())
+		assert.Equal(sampleRate, p.SampleRate())
+		assert.NoError(p.Close())
+	}
+
+	// Audio is decoded at a higher sample rate when the resampler options
+	// are set
+	p, err = audio.NewPreprocess("resample=filter_size=64:cutoff=0.97", sampleRate)
+	if assert.NoError(err) {
+		assert.Equal(audio.ResampleRate, p.SampleRate())
+		assert.NoError(p.Close())
+	}
+
+	for _, value := range []string{
+		"denoise",
+		"highpass=abc",
+		"highpass=9000",
+		"gain=3",
+		"arnndn",
+		"resample",
+		"afftdn=nr=20;amovie=audio.wav",
+		"afftdn=unknown=1",
+		"resample=filter_size=abc",
+	} {
+		_, err = audio.NewPreprocess(value, sampleRate)
+		assert.ErrorIs(err, ErrBadParameter, value)
+	}
+}
+
+func Test_preprocess_002(t *testing.T) {
+	assert := assert.New(t)
+	p, err := audio.NewPreprocess("highpass=200", sampleRate)
+	assert.NoError(err)
+
+	// Low frequencies are removed, and speech frequencies are kept
+	hum := sine(50, 0.5)
+	hum = filter(t, p, hum)
+	assert.Less(peak(hum), 0.05)
+
+	p, _ = audio.NewPreprocess("highpass=200", sampleRate)
+	speech := sine(1000, 0.5)
+	speech = filter(t, p, speech)
+	assert.InDelta(0.5, peak(speech), 0.02)
+}
+
+func Test_preprocess_003(t *testing.T) {
+	assert := assert.New(t)
+	p, err := audio.NewPreprocess("gain=-20", sampleRate)
+	assert.NoError(err)
+
+	// Quiet audio is amplified towards the target level, and stays there
+	for i := 0; i < 3; i++ {
+		samples := sine(440, 0.02)
+		samples = filter(t, p, samples)
+		if i > 0 {
+			assert.InDelta(0.1*math.Sqrt2, peak(samples), 0.01)
+		}
+	}
+
+	// Silence is not amplified beyond the last gain
+	silence := make([]float32, sampleRate)
+	silence = filter(t, p, silence)
+	assert.Equal(0.0, peak(silence))
+}
+
+func Test_preprocess_004(t *testing.T) {
+	assert := assert.New(t)
+	p, err := audio.NewPreprocess("gate=-40", sampleRate)
+	assert.NoError(err)
+
+	// Audio below the threshold is attenuated, and speech is kept
+	quiet := sine(1000, 0.001)
+	quiet = filter(t, p, quiet)
+	assert.InDelta(0.0001, peak(quiet), 0.00001)
+
+	speech := sine(1000, 0.5)
+	speech = filter(t, p, speech)
+	assert.InDelta(0.5, peak(speech), 0.01)
+
+	// 16-bit samples are filtered as float32 samples
+	samples := []int16{100, -100, 100, -100}
+	p, _ = audio.NewPreprocess("gate=-20", sampleRate)
+	fn, _ := audio.Filtered(p, func(ts time.Duration, samples []int16) error {
+		return nil
+	})
+	assert.NoError(fn(0, samples))
+	assert.Less(samples[3], int16(0))
+	assert.Greater(samples[3], int16(-100))
+}
+
+func Test_preprocess_005(t *testing.T) {
+	assert := assert.New(t)
+
+	// Noise is reduced by the afftdn filter, and the audio keeps its length
+	r := rand.New(rand.NewSource(1))
+	noise := make([]float32, sampleRate)
+	for i := range noise {
+		noise[i] = float32(r.NormFloat64() * 0.05)
+	}
+	p, err := audio.NewPreprocess("afftdn=nr=30:nf=-30", sampleRate)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	defer p.Close()
+	filtered := filter(t, p, noise)
+	assert.InDelta(len(noise), len(filtered), sampleRate/100)
+	assert.Less(peak(filtered), peak(noise)/2)
+}
+
+func Test_preprocess_006(t *testing.T) {
+	assert := assert.New(t)
+
+	// Quiet speech is made louder by the loudnorm filter
+	p, err := audio.NewPreprocess("loudnorm=I=-16", sampleRate)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	defer p.Close()
+	quiet := sine(1000, 0.01)
+	filtered := filter(t, p, append(append(quiet, quiet...), quiet...))
+	assert.InDelta(3*sampleRate, len(filtered), sampleRate/100)
+	assert.Greater(peak(filtered), 0.05)
+}
+
+func Test_preprocess_007(t *testing.T) {
+	assert := assert.New(t)
+
+	// Audio at the resample rate is resampled with the resampler options,
+	// before the Go filters are applied
+	for _, resample := range []string{"filter_size=64:cutoff=0.97", "resampler=soxr"} {
+		p, err := audio.NewPreprocess("resample="+resample+",gate=-60", sampleRate)
+		if !assert.NoError(err, resample) {
+			continue
+		}
+		filtered := filter(t, p, sineAt(p.SampleRate(), 1000, 0.5))
+		assert.InDelta(sampleRate, len(filtered), sampleRate/100, resample)
+		assert.InDelta(0.5, peak(filtered), 0.02, resample)
+		assert.NoError(p.Close())
+	}
+}
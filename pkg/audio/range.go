@@ -0,0 +1,103 @@
+package audio
+
+import (
+	"errors"
+	"time"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Range is the part of the audio which is transcribed, from the start to the
+// end. An end of zero is the end of the audio
+type Range struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Sample is a 16-bit integer or float32 audio sample
+type Sample interface {
+	int16 | float32
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// Returned from decoding when the end of the range has been read
+	ErrEndOfRange = errors.New("end of range")
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return a range from a start and end, or an error if the range is empty
+func NewRange(start, end time.Duration) (Range, error) {
+	if start < 0 {
+		return Range{}, ErrBadParameter.Withf("start %v is negative", start)
+	} else if end != 0 && end <= start {
+		return Range{}, ErrBadParameter.Withf("end %v is not after start %v", end, start)
+	}
+	return Range{start, end}, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return true if the range is all the audio
+func (r Range) IsZero() bool {
+	return r.Start == 0 && r.End == 0
+}
+
+// Return the length of the range in audio with a duration, which is zero
+// when the duration is not known
+func (r Range) Length(duration time.Duration) time.Duration {
+	if r.End > 0 && (duration == 0 || r.End < duration) {
+		duration = r.End
+	}
+	if duration == 0 {
+		return 0
+	}
+	return max(duration-r.Start, 0)
+}
+
+// Clip samples at timestamp ts to the range, and return the timestamp and
+// samples within the range. The timestamp is relative to the start of the
+// audio. Returns true when the samples are at or after the end of the range,
+// and no more samples need to be read
+func Clip[T Sample](r Range, ts time.Duration, samples []T, sampleRate int) (time.Duration, []T, bool) {
+	end := ts + duration(len(samples), sampleRate)
+
+	// Remove samples before the start
+	if r.Start > ts {
+		n := min(count(r.Start-ts, sampleRate), len(samples))
+		samples, ts = samples[n:], ts+duration(n, sampleRate)
+	}
+
+	// Remove samples after the end
+	if r.End > 0 && end >= r.End {
+		n := max(count(r.End-ts, sampleRate), 0)
+		samples = samples[:min(n, len(samples))]
+		return ts, samples, true
+	}
+	return ts, samples, false
+}
+
+// Return ErrEndOfRange when done, to stop decoding at the end of a range
+func EndOfRange(done bool) error {
+	if done {
+		return ErrEndOfRange
+	}
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the number of samples in a duration
+func count(d time.Duration, sampleRate int) int {
+	return int(d * time.Duration(sampleRate) / time.Second)
+}
@@ -0,0 +1,95 @@
+package audio_test
+
+import (
+	"testing"
+	"time"
+
+	// Packages
+	audio "github.com/mutablelogic/go-whisper/pkg/audio"
+	assert "github.com/stretchr/testify/assert"
+)
+
+const rate = 100 // Samples per second
+
+func Test_range_001(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := audio.NewRange(0, 0)
+	assert.NoError(err)
+	assert.True(r.IsZero())
+
+	_, err = audio.NewRange(-time.Second, 0)
+	assert.Error(err)
+	_, err = audio.NewRange(10*time.Second, 5*time.Second)
+	assert.Error(err)
+
+	r, err = audio.NewRange(10*time.Second, 20*time.Second)
+	assert.NoError(err)
+	assert.False(r.IsZero())
+	assert.Equal(10*time.Second, r.Length(0))
+	assert.Equal(10*time.Second, r.Length(time.Minute))
+	assert.Equal(5*time.Second, r.Length(15*time.Second))
+	assert.Equal(time.Duration(0), r.Length(5*time.Second))
+
+	r, _ = audio.NewRange(10*time.Second, 0)
+	assert.Equal(time.Duration(0), r.Length(0))
+	assert.Equal(50*time.Second, r.Length(time.Minute))
+}
+
+func Test_range_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// The whole audio
+	samples := make([]float32, 10*rate)
+	ts, clipped, done := audio.Clip(audio.Range{}, 30*time.Second, samples, rate)
+	assert.Equal(30*time.Second, ts)
+	assert.Len(clipped, 10*rate)
+	assert.False(done)
+}
+
+func Test_range_003(t *testing.T) {
+	assert := assert.New(t)
+	r, _ := audio.NewRange(15*time.Second, 25*time.Second)
+
+	// Samples from 0s to 10s are before the start
+	ts, clipped, done := audio.Clip(r, 0, make([]int16, 10*rate), rate)
+	assert.Len(clipped, 0)
+	assert.False(done)
+	assert.Equal(10*time.Second, ts)
+
+	// Samples from 10s to 20s start at 15s
+	ts, clipped, done = audio.Clip(r, 10*time.Second, make([]int16, 10*rate), rate)
+	assert.Equal(15*time.Second, ts)
+	assert.Len(clipped, 5*rate)
+	assert.False(done)
+
+	// Samples from 20s to 30s end at 25s
+	ts, clipped, done = audio.Clip(r, 20*time.Second, make([]int16, 10*rate), rate)
+	assert.Equal(20*time.Second, ts)
+	assert.Len(clipped, 5*rate)
+	assert.True(done)
+
+	// Samples from 30s are after the end
+	_, clipped, done = audio.Clip(r, 30*time.Second, make([]int16, 10*rate), rate)
+	assert.Len(clipped, 0)
+	assert.True(done)
+}
+
+func Test_range_004(t *testing.T) {
+	assert := assert.New(t)
+	r, _ := audio.NewRange(12*time.Second, 14*time.Second)
+
+	// The range is within the samples
+	ts, clipped, done := audio.Clip(r, 10*time.Second, make([]float32, 10*rate), rate)
+	assert.Equal(12*time.Second, ts)
+	assert.Len(clipped, 2*rate)
+	assert.True(done)
+}
+
+func Test_range_005(t *testing.T) {
+	assert := assert.New(t)
+
+	// Decoding stops at the end of the range
+	assert.NoError(audio.EndOfRange(false))
+	assert.ErrorIs(audio.EndOfRange(true), audio.ErrEndOfRange)
+}
@@ -0,0 +1,116 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	// Packages
+	auth "github.com/mutablelogic/go-whisper/pkg/auth"
+	assert "github.com/stretchr/testify/assert"
+)
+
+const (
+	adminToken      = "admin-0123456789abcdef"
+	transcribeToken = "transcribe-0123456789abcdef"
+)
+
+func Test_auth_001(t *testing.T) {
+	assert := assert.New(t)
+
+	keys := auth.NewKeys()
+	assert.NoError(keys.Read(strings.NewReader(`
+# comment
+` + adminToken + ` admin ops
+` + transcribeToken + `
+`)))
+	assert.Equal(2, keys.Len())
+
+	admin := keys.Lookup(adminToken)
+	if assert.NotNil(admin) {
+		assert.Equal("ops", admin.Name)
+		assert.True(strings.HasPrefix(admin.Id, "key-"))
+		assert.Equal(admin, keys.ById(admin.Id))
+		assert.True(admin.Has(auth.ScopeAdmin))
+		assert.True(admin.Has(auth.ScopeTranscribe))
+	}
+	transcribe := keys.Lookup(transcribeToken)
+	if assert.NotNil(transcribe) {
+		assert.Equal(transcribe.Id, transcribe.Name)
+		assert.NotEqual(admin.Id, transcribe.Id)
+		assert.False(transcribe.Has(auth.ScopeAdmin))
+		assert.True(transcribe.Has(auth.ScopeTranscribe))
+	}
+	assert.Nil(keys.Lookup("unknown-0123456789abcdef"))
+	assert.Nil(keys.Lookup(""))
+
+	// Errors
+	assert.Error(keys.Parse("short"))
+	assert.Error(keys.Parse(adminToken))
+	assert.Error(keys.Parse("other-0123456789abcdef superuser"))
+	assert.NoError(keys.Parse("a-0123456789abcdef transcribe a; b-0123456789abcdef admin,transcribe b"))
+	assert.Equal(4, keys.Len())
+}
+
+func Test_auth_002(t *testing.T) {
+	assert := assert.New(t)
+
+	keys := auth.NewKeys()
+	assert.NoError(keys.Add(adminToken, "admin", auth.ScopeAdmin))
+	assert.NoError(keys.Add(transcribeToken, "transcribe"))
+
+	var name string
+	handler := auth.Middleware(keys, func(r *http.Request) auth.Scope {
+		switch r.Method {
+		case http.MethodGet:
+			return ""
+		case http.MethodDelete:
+			return auth.ScopeAdmin
+		default:
+			return auth.ScopeTranscribe
+		}
+	}, nil)(func(w http.ResponseWriter, r *http.Request) {
+		name = ""
+		if key := auth.KeyFromContext(r.Context()); key != nil {
+			name = key.Name
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, test := range []struct {
+		method, token string
+		status        int
+		name          string
+	}{
+		{http.MethodGet, "", http.StatusNoContent, ""},
+		{http.MethodPost, "", http.StatusUnauthorized, ""},
+		{http.MethodPost, "invalid-0123456789abcdef", http.StatusUnauthorized, ""},
+		{http.MethodPost, transcribeToken, http.StatusNoContent, "transcribe"},
+		{http.MethodDelete, transcribeToken, http.StatusForbidden, ""},
+		{http.MethodDelete, adminToken, http.StatusNoContent, "admin"},
+	} {
+		name = ""
+		r := httptest.NewRequest(test.method, "/", nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		assert.Equal(test.status, w.Code, test)
+		assert.Equal(test.name, name, test)
+		if test.status == http.StatusUnauthorized {
+			assert.NotEmpty(w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// Without keys, authentication is disabled
+	handler = auth.Middleware(auth.NewKeys(), func(r *http.Request) auth.Scope {
+		return auth.ScopeAdmin
+	}, nil)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodDelete, "/", nil))
+	assert.Equal(http.StatusNoContent, w.Code)
+}
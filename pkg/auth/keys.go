@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Keys is a set of API keys, each with a name and scopes. Keys are held
+// as SHA-256 hashes, so the tokens are not kept in memory
+type Keys struct {
+	keys map[[sha256.Size]byte]*Key
+}
+
+// Key is an API key. The id is derived from the token and is unique, and
+// is used for usage accounting. The name is used in logs, and need not be
+// unique
+type Key struct {
+	Id     string  `json:"id"`
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+}
+
+// Scope is a permission granted to a key
+type Scope string
+
+type keyContext struct{}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Transcribe, translate and detect language, and list models
+	ScopeTranscribe Scope = "transcribe"
+
+	// Download and delete models, which includes the transcribe scope
+	ScopeAdmin Scope = "admin"
+)
+
+const (
+	// Minimum length of a token
+	minTokenLength = 16
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return an empty set of keys
+func NewKeys() *Keys {
+	return &Keys{keys: make(map[[sha256.Size]byte]*Key)}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Read keys from a file, with one key on each line
+func (keys *Keys) ReadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := keys.Read(f); err != nil {
+		return ErrBadParameter.Withf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// Read keys, with one key on each line in the form
+//
+//	<token> [<scope>[,<scope>...]] [<name>]
+//
+// The scope defaults to transcribe, and the name defaults to a prefix of
+// the token hash. Empty lines and lines starting with # are ignored
+func (keys *Keys) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		if err := keys.parse(scanner.Text()); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Parse keys from a string, with keys separated by semicolons in the same
+// form as the lines of a file, for setting keys in an environment variable
+func (keys *Keys) Parse(v string) error {
+	for _, line := range strings.Split(v, ";") {
+		if err := keys.parse(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add a key with a token, name and scopes
+func (keys *Keys) Add(token, name string, scopes ...Scope) error {
+	if len(token) < minTokenLength {
+		return ErrBadParameter.Withf("token should be at least %d characters", minTokenLength)
+	}
+	hash := sha256.Sum256([]byte(token))
+	if _, exists := keys.keys[hash]; exists {
+		return ErrDuplicateEntry.With("duplicate token")
+	}
+	if len(scopes) == 0 {
+		scopes = []Scope{ScopeTranscribe}
+	}
+	for _, scope := range scopes {
+		if scope != ScopeTranscribe && scope != ScopeAdmin {
+			return ErrBadParameter.Withf("unsupported scope: %q", scope)
+		}
+	}
+	id := "key-" + hex.EncodeToString(hash[:8])
+	if name == "" {
+		name = id
+	}
+	keys.keys[hash] = &Key{Id: id, Name: name, Scopes: scopes}
+	return nil
+}
+
+// Return the number of keys
+func (keys *Keys) Len() int {
+	if keys == nil {
+		return 0
+	}
+	return len(keys.keys)
+}
+
+// Return the key for a token, or nil if the token is not valid
+func (keys *Keys) Lookup(token string) *Key {
+	if keys == nil || token == "" {
+		return nil
+	}
+	return keys.keys[sha256.Sum256([]byte(token))]
+}
+
+// Return the key with an id, or nil if there is no such key
+func (keys *Keys) ById(id string) *Key {
+	if keys == nil {
+		return nil
+	}
+	for _, key := range keys.keys {
+		if key.Id == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// Return true if the key has the scope. The admin scope includes all scopes
+func (key *Key) Has(scope Scope) bool {
+	if key == nil {
+		return false
+	}
+	return slices.Contains(key.Scopes, scope) || slices.Contains(key.Scopes, ScopeAdmin)
+}
+
+// Return a context with the key
+func WithKey(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, keyContext{}, key)
+}
+
+// Return the key from the context, or nil if the request was not
+// authenticated
+func KeyFromContext(ctx context.Context) *Key {
+	key, _ := ctx.Value(keyContext{}).(*Key)
+	return key
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (keys *Keys) parse(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	} else if len(fields) > 3 {
+		return ErrBadParameter.With("expected <token> [<scope>,...] [<name>]")
+	}
+
+	var scopes []Scope
+	if len(fields) > 1 {
+		for _, scope := range strings.Split(fields[1], ",") {
+			scopes = append(scopes, Scope(strings.ToLower(strings.TrimSpace(scope))))
+		}
+	}
+	var name string
+	if len(fields) > 2 {
+		name = fields[2]
+	}
+	return keys.Add(fields[0], name, scopes...)
+}
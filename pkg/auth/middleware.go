@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	// Packages
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return middleware which requires a bearer token with the scope returned
+// by fn for the request, or no token when fn returns an empty scope. The
+// key is added to the request context. Authentication is disabled when
+// there are no keys. Errors are written with errfn, or as a go-server error
+// when errfn is nil
+func Middleware(keys *Keys, fn func(r *http.Request) Scope, errfn func(http.ResponseWriter, error) error) func(http.HandlerFunc) http.HandlerFunc {
+	if errfn == nil {
+		errfn = func(w http.ResponseWriter, err error) error {
+			return httpresponse.Error(w, err)
+		}
+	}
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if keys.Len() == 0 {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			scope := fn(r)
+			if scope == "" {
+				next(w, r)
+				return
+			}
+
+			// Check the token and scope
+			key := keys.Lookup(Token(r))
+			if key == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="whisper"`)
+				errfn(w, httpresponse.ErrNotAuthorized.With("Invalid or missing API key"))
+				return
+			} else if !key.Has(scope) {
+				errfn(w, httpresponse.ErrForbidden.Withf("API key %q does not have the %q scope", key.Name, scope))
+				return
+			}
+
+			// Serve the request with the key
+			next(w, r.WithContext(WithKey(r.Context(), key)))
+		}
+	}
+}
+
+// Return the bearer token from the Authorization header of a request, or
+// empty if there is none
+func Token(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"slices"
+	"time"
 
 	// Packages
 	"github.com/mutablelogic/go-client"
@@ -13,6 +14,7 @@ import (
 	"github.com/mutablelogic/go-whisper/pkg/client/gowhisper"
 	"github.com/mutablelogic/go-whisper/pkg/client/openai"
 	"github.com/mutablelogic/go-whisper/pkg/schema"
+	"github.com/mutablelogic/go-whisper/pkg/tracing"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -49,8 +51,11 @@ func New(opts ...client.ClientOpt) (*Client, error) {
 		}
 	}
 
-	// gowhisper client
+	// gowhisper client, with an optional API key
 	if endpoint := gowhisper_endpoint(); endpoint != "" {
+		if key := gowhisper_key(); key != "" {
+			opts = append([]client.ClientOpt{client.OptReqToken(client.Token{Scheme: client.Bearer, Value: key})}, opts...)
+		}
 		if client, err := gowhisper.New(endpoint, opts...); err != nil {
 			return nil, err
 		} else {
@@ -77,6 +82,10 @@ func gowhisper_endpoint() string {
 	return os.Getenv("WHISPER_URL")
 }
 
+func gowhisper_key() string {
+	return os.Getenv("WHISPER_API_KEY")
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
@@ -140,8 +149,15 @@ func (c *Client) DeleteModel(ctx context.Context, model string) error {
 }
 
 // Transcribe performs a transcription request in the language of the speech
-func (c *Client) Transcribe(ctx context.Context, model string, r io.Reader, opt ...Opt) (*schema.Transcription, error) {
+func (c *Client) Transcribe(ctx context.Context, model string, r io.Reader, opt ...Opt) (_ *schema.Transcription, err error) {
 	var response *schema.Transcription
+	ctx, span := tracing.StartKind(ctx, tracing.KindClient, "client.transcribe", tracing.String("model", model))
+	defer func() {
+		if response != nil {
+			span.SetAttrs(tracing.String("language", response.Language), tracing.Duration("duration", time.Duration(response.Duration)))
+		}
+		span.End(err)
+	}()
 	switch {
 	case c.openai != nil && slices.Contains(openai.Models, model):
 		req, err := applyOpts(apiopenai, transcribe, model, r, opt...)
@@ -184,8 +200,15 @@ func (c *Client) Transcribe(ctx context.Context, model string, r io.Reader, opt
 }
 
 // Translate performs a transcription request and returns the result in english
-func (c *Client) Translate(ctx context.Context, model string, r io.Reader, opt ...Opt) (*schema.Transcription, error) {
+func (c *Client) Translate(ctx context.Context, model string, r io.Reader, opt ...Opt) (_ *schema.Transcription, err error) {
 	var response *schema.Transcription
+	ctx, span := tracing.StartKind(ctx, tracing.KindClient, "client.translate", tracing.String("model", model))
+	defer func() {
+		if response != nil {
+			span.SetAttrs(tracing.String("language", response.Language), tracing.Duration("duration", time.Duration(response.Duration)))
+		}
+		span.End(err)
+	}()
 	switch {
 	case c.openai != nil && slices.Contains(openai.Models, model):
 		if req, err := applyOpts(apiopenai, translate, model, r, opt...); err != nil {
@@ -216,3 +239,21 @@ func (c *Client) Translate(ctx context.Context, model string, r io.Reader, opt .
 	// Return success
 	return response, nil
 }
+
+// DetectLanguage returns the most probable languages spoken at the start of the audio
+func (c *Client) DetectLanguage(ctx context.Context, model string, r io.Reader, opt ...Opt) (_ *schema.LanguageDetection, err error) {
+	ctx, span := tracing.StartKind(ctx, tracing.KindClient, "client.detect_language", tracing.String("model", model))
+	defer func() {
+		span.End(err)
+	}()
+	switch {
+	case c.gowhisper != nil && model != "":
+		if req, err := applyOpts(apigowhisper, detect, model, r, opt...); err != nil {
+			return nil, err
+		} else {
+			return c.gowhisper.DetectLanguage(ctx, req.language)
+		}
+	default:
+		return nil, httpresponse.ErrNotImplemented.Withf("language detection with model %q is not supported", model)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	// Packages
 	"github.com/mutablelogic/go-client"
 	"github.com/mutablelogic/go-whisper/pkg/schema"
+	"github.com/mutablelogic/go-whisper/pkg/tracing"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -56,3 +57,14 @@ func (c *Client) ListModels(ctx context.Context) ([]schema.Model, error) {
 	// Return success
 	return response.Models, nil
 }
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return request options which continue the trace in the server
+func traceOpts(ctx context.Context) []client.RequestOpt {
+	if value := tracing.TraceParent(ctx); value != "" {
+		return []client.RequestOpt{client.OptReqHeader(tracing.TraceParentHeader, value)}
+	}
+	return nil
+}
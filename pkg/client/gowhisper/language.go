@@ -0,0 +1,39 @@
+package gowhisper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	// Packages
+	"github.com/mutablelogic/go-client"
+	"github.com/mutablelogic/go-whisper/pkg/schema"
+)
+
+/////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// DetectLanguage returns the most probable languages spoken at the start of the audio
+func (c *Client) DetectLanguage(ctx context.Context, req LanguageRequest) (*schema.LanguageDetection, error) {
+	var response schema.LanguageDetection
+
+	// Check file, set path if not provided
+	if req.File.Body == nil {
+		return nil, fmt.Errorf("file is required")
+	} else if req.File.Path == "" {
+		if f, ok := req.File.Body.(*os.File); ok {
+			req.File.Path = filepath.Base(f.Name())
+		}
+	}
+
+	// Create multipart request, and execute it
+	if payload, err := client.NewMultipartRequest(req, client.ContentTypeJson); err != nil {
+		return nil, err
+	} else if err := c.DoWithContext(ctx, payload, &response, append(traceOpts(ctx), client.OptPath(LanguagePath))...); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return &response, nil
+}
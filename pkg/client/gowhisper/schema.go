@@ -1,6 +1,7 @@
 package gowhisper
 
 import (
+	"github.com/mutablelogic/go-client/pkg/multipart"
 	"github.com/mutablelogic/go-whisper/pkg/client/openai"
 )
 
@@ -9,16 +10,51 @@ import (
 
 type TranslationRequest struct {
 	openai.TranslationRequest
-	Stream   *bool   `json:"stream,omitempty"`
-	Diarize  *bool   `json:"diarize,omitempty"`
-	Language *string `json:"language,omitempty"`
+	Stream             *bool    `json:"stream,omitempty"`
+	Diarize            *bool    `json:"diarize,omitempty"`
+	Language           *string  `json:"language,omitempty"`
+	AllowedLanguages   *string  `json:"allowed_languages,omitempty"`    // Comma-separated languages, when language is auto-detected
+	LanguagePerSegment *bool    `json:"language_per_segment,omitempty"` // Detect language for each segment
+	TargetLanguage     *string  `json:"target_language,omitempty"`      // Translate the transcription to this language
+	Vocabulary         *string  `json:"vocabulary,omitempty"`           // Comma-separated terms, with optional weights as term:weight
+	FileURL            *string  `json:"file_url,omitempty"`             // URL of the audio, read by the server instead of the file
+	StreamIndex        *uint64  `json:"stream_index,omitempty"`         // Index of the audio stream, rather than the best audio stream
+	StreamLanguage     *string  `json:"stream_language,omitempty"`      // Language of the audio stream in an uploaded file, such as eng
+	Start              *float64 `json:"start,omitempty"`                // Seconds from the start of the audio to transcribe from
+	End                *float64 `json:"end,omitempty"`                  // Seconds from the start of the audio to transcribe to
+	Preprocess         *string  `json:"preprocess,omitempty"`           // Comma-separated filters applied to the audio, as filter or filter=value
 }
 
 type TranscriptionRequest struct {
 	openai.TranscriptionRequest
-	Diarize *bool `json:"diarize,omitempty"`
+	Diarize            *bool    `json:"diarize,omitempty"`
+	AllowedLanguages   *string  `json:"allowed_languages,omitempty"`    // Comma-separated languages, when language is auto-detected
+	LanguagePerSegment *bool    `json:"language_per_segment,omitempty"` // Detect language for each segment
+	TargetLanguage     *string  `json:"target_language,omitempty"`      // Translate the transcription to this language
+	Vocabulary         *string  `json:"vocabulary,omitempty"`           // Comma-separated terms, with optional weights as term:weight
+	FileURL            *string  `json:"file_url,omitempty"`             // URL of the audio, read by the server instead of the file
+	StreamIndex        *uint64  `json:"stream_index,omitempty"`         // Index of the audio stream, rather than the best audio stream
+	StreamLanguage     *string  `json:"stream_language,omitempty"`      // Language of the audio stream in an uploaded file, such as eng
+	Start              *float64 `json:"start,omitempty"`                // Seconds from the start of the audio to transcribe from
+	End                *float64 `json:"end,omitempty"`                  // Seconds from the start of the audio to transcribe to
+	Preprocess         *string  `json:"preprocess,omitempty"`           // Comma-separated filters applied to the audio, as filter or filter=value
 }
 
 type TranscriptionResponse struct {
 	openai.TranscriptionResponse
 }
+
+type LanguageRequest struct {
+	Model            string         `json:"model"`
+	File             multipart.File `json:"file"`
+	Duration         *float64       `json:"duration,omitempty"`          // Seconds of audio to consider, from the start
+	TopK             *uint64        `json:"top_k,omitempty"`             // Number of languages to return
+	AllowedLanguages *string        `json:"allowed_languages,omitempty"` // Comma-separated languages to choose from
+}
+
+/////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	LanguagePath = "audio/language" // Endpoint for language detection
+)
@@ -8,6 +8,7 @@ import (
 
 	// Packages
 	"github.com/mutablelogic/go-client"
+	"github.com/mutablelogic/go-client/pkg/multipart"
 	"github.com/mutablelogic/go-server/pkg/types"
 	"github.com/mutablelogic/go-whisper/pkg/client/openai"
 	"github.com/mutablelogic/go-whisper/pkg/schema"
@@ -20,8 +21,14 @@ import (
 func (c *Client) Transcribe(ctx context.Context, req TranscriptionRequest) (*openai.TranscriptionResponse, error) {
 	var response openai.TranscriptionResponse
 
-	// Check file, set path if not provided
-	if req.File.Body == nil {
+	// Check file, set path if not provided. The file is not sent when the
+	// server reads the audio from a URL
+	if req.FileURL != nil {
+		if req.File.Body != nil {
+			return nil, fmt.Errorf("file and file_url cannot both be set")
+		}
+		req.File = multipart.File{}
+	} else if req.File.Body == nil {
 		return nil, fmt.Errorf("file is required")
 	} else if req.File.Path == "" {
 		if f, ok := req.File.Body.(*os.File); ok {
@@ -30,9 +37,9 @@ func (c *Client) Transcribe(ctx context.Context, req TranscriptionRequest) (*ope
 	}
 
 	// Set request options
-	opts := []client.RequestOpt{
+	opts := append([]client.RequestOpt{
 		client.OptPath(openai.TranscribePath),
-	}
+	}, traceOpts(ctx)...)
 	if types.PtrBool(req.Stream) {
 		opts = append(opts, client.OptTextStreamCallback(func(e client.TextStreamEvent) error {
 			// Ignore non-data events
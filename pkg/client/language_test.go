@@ -20,6 +20,10 @@ func Test_Language_001(t *testing.T) {
 		{"en", "en", "eng"},
 		{"eng", "en", "eng"},
 		{"german", "de", "deu"},
+		{"norwegian", "no", "nor"},
+		{"dan", "da", "dan"},
+		{"malay", "ms", "msa"},
+		{"ind", "id", "ind"},
 	}
 	for _, test := range tests {
 		t.Run(test.in, func(t *testing.T) {
@@ -17,7 +17,7 @@ import (
 
 type TranslationRequest struct {
 	Model       string         `json:"model"` // whisper-1
-	File        multipart.File `json:"file"`
+	File        multipart.File `json:"file,omitempty"`
 	Prompt      *string        `json:"prompt,omitempty"`
 	Format      *string        `json:"response_format,omitempty"` // json, text, srt, verbose_json, or vtt
 	Temperature *float64       `json:"temperature,omitempty"`     // 0.0 -> 1.0
@@ -37,6 +37,7 @@ type TranscriptionResponse struct {
 	Duration schema.Timestamp        `json:"duration,omitempty"`
 	Text     string                  `json:"text,omitempty"`
 	Segment  []*TranscriptionSegment `json:"segments,omitempty" writer:",width:40,wrap"`
+	Timings  *schema.Timings         `json:"timings,omitempty" writer:"-"` // Timings for verbose_json (gowhisper only)
 }
 
 type TranscriptionSegment struct {
@@ -45,6 +46,7 @@ type TranscriptionSegment struct {
 	Start            schema.Timestamp `json:"start"`
 	End              schema.Timestamp `json:"end"`
 	Text             string           `json:"text"`
+	Language         string           `json:"language,omitempty"`          // Language of the segment, when detected per segment (gowhisper only)
 	Tokens           []uint32         `json:"tokens,omitempty"`            // Array of token IDs for the text content.
 	Temperature      *float64         `json:"temperature,omitempty"`       // Temperature parameter used for generating the segment.
 	AvgLogProb       *float64         `json:"avg_logprob,omitempty"`       // Average logprob of the segment. If the value is lower than -1, consider the logprobs failed.
@@ -143,13 +145,15 @@ func (s *TranscriptionResponse) Segments() *schema.Transcription {
 		Duration: s.Duration,
 		Text:     s.Text,
 		Segments: make([]*schema.Segment, 0, len(s.Segment)),
+		Timings:  s.Timings,
 	}
 	for _, seg := range s.Segment {
 		resp.Segments = append(resp.Segments, &schema.Segment{
-			Id:    seg.Id,
-			Start: seg.Start,
-			End:   seg.End,
-			Text:  seg.Text,
+			Id:       seg.Id,
+			Start:    seg.Start,
+			End:      seg.End,
+			Text:     seg.Text,
+			Language: seg.Language,
 		})
 	}
 	return resp
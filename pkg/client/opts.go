@@ -3,6 +3,8 @@ package client
 import (
 	"io"
 	"slices"
+	"strings"
+	"time"
 
 	// Packages
 	"github.com/mutablelogic/go-client/pkg/multipart"
@@ -24,6 +26,7 @@ type opts struct {
 	elevenlabs elevenlabs.TranscribeRequest
 	transcribe gowhisper.TranscriptionRequest
 	translate  gowhisper.TranslationRequest
+	language   gowhisper.LanguageRequest
 	streamfn   func(schema.Event)
 }
 
@@ -44,6 +47,7 @@ const (
 const (
 	translate  = task("translate")
 	transcribe = task("transcribe")
+	detect     = task("detect")
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -65,6 +69,8 @@ func applyOpts(api apitype, task task, model string, r io.Reader, opt ...Opt) (*
 		o.transcribe.Model = model
 		o.translate.File = multipart.File{Body: r}
 		o.translate.Model = model
+		o.language.File = multipart.File{Body: r}
+		o.language.Model = model
 	}
 
 	for _, opt := range opt {
@@ -108,6 +114,89 @@ func OptLanguage(language string) Opt {
 	}
 }
 
+// Restrict the auto-detected language to a set of languages
+func OptAllowedLanguages(languages ...string) Opt {
+	return func(api apitype, o *opts) error {
+		if len(languages) == 0 {
+			return nil
+		}
+		switch api {
+		case apigowhisper:
+			// whisper uses two-letter language codes
+			codes := make([]string, 0, len(languages))
+			for _, language := range languages {
+				if code, _ := LanguageCode(language); code == "" {
+					return httpresponse.ErrBadRequest.Withf("language %q not supported", language)
+				} else {
+					codes = append(codes, code)
+				}
+			}
+			v := types.StringPtr(strings.Join(codes, ","))
+			o.transcribe.AllowedLanguages = v
+			o.translate.AllowedLanguages = v
+			o.language.AllowedLanguages = v
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptAllowedLanguages not supported")
+		}
+		return nil
+	}
+}
+
+// Detect the language for each segment of audio which switches language
+func OptLanguagePerSegment() Opt {
+	return func(api apitype, o *opts) error {
+		switch api {
+		case apigowhisper:
+			o.transcribe.LanguagePerSegment = types.BoolPtr(true)
+			o.translate.LanguagePerSegment = types.BoolPtr(true)
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptLanguagePerSegment not supported")
+		}
+		return nil
+	}
+}
+
+// Translate the transcription to a target language. Translation to languages
+// other than English requires a text translation backend on the server
+func OptTargetLanguage(language string) Opt {
+	return func(api apitype, o *opts) error {
+		if language == "" {
+			return nil
+		}
+		switch api {
+		case apigowhisper:
+			if code, _ := LanguageCode(language); code == "" {
+				return httpresponse.ErrBadRequest.Withf("language %q not supported", language)
+			} else {
+				o.transcribe.TargetLanguage = types.StringPtr(code)
+				o.translate.TargetLanguage = types.StringPtr(code)
+			}
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptTargetLanguage not supported")
+		}
+		return nil
+	}
+}
+
+// Bias the transcription towards domain terms, in the form "term" or
+// "term:weight", and correct words which closely match a term
+func OptVocabulary(terms ...string) Opt {
+	return func(api apitype, o *opts) error {
+		if len(terms) == 0 {
+			return nil
+		}
+		switch api {
+		case apigowhisper:
+			v := types.StringPtr(strings.Join(terms, ","))
+			o.transcribe.Vocabulary = v
+			o.translate.Vocabulary = v
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptVocabulary not supported")
+		}
+		return nil
+	}
+}
+
 // Set format for transcription (json, verbose_json, srt, vtt, text)
 func OptFormat(v string) Opt {
 	return func(api apitype, o *opts) error {
@@ -139,6 +228,103 @@ func OptPath(v string) Opt {
 		o.elevenlabs.File.Path = v
 		o.translate.File.Path = v
 		o.transcribe.File.Path = v
+		o.language.File.Path = v
+		return nil
+	}
+}
+
+// Read the audio from a URL on the server, instead of uploading the file.
+// The host should be allowed by the server
+func OptFileURL(v string) Opt {
+	return func(api apitype, o *opts) error {
+		switch api {
+		case apigowhisper:
+			o.transcribe.FileURL = types.StringPtr(v)
+			o.translate.FileURL = types.StringPtr(v)
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptFileURL not supported")
+		}
+		return nil
+	}
+}
+
+// Transcribe an audio stream by index, rather than the best audio stream
+func OptStreamIndex(v uint64) Opt {
+	return func(api apitype, o *opts) error {
+		switch api {
+		case apigowhisper:
+			o.transcribe.StreamIndex = types.Uint64Ptr(v)
+			o.translate.StreamIndex = types.Uint64Ptr(v)
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptStreamIndex not supported")
+		}
+		return nil
+	}
+}
+
+// Transcribe the first audio stream with a language in the stream metadata,
+// such as "eng", rather than the best audio stream. The server reads the
+// language from an uploaded file, not from a URL
+func OptStreamLanguage(v string) Opt {
+	return func(api apitype, o *opts) error {
+		switch api {
+		case apigowhisper:
+			o.transcribe.StreamLanguage = types.StringPtr(v)
+			o.translate.StreamLanguage = types.StringPtr(v)
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptStreamLanguage not supported")
+		}
+		return nil
+	}
+}
+
+// Transcribe the audio from a time, with timestamps from the start of the
+// audio
+func OptStart(v time.Duration) Opt {
+	return func(api apitype, o *opts) error {
+		if v < 0 {
+			return httpresponse.ErrBadRequest.Withf("invalid start %v", v)
+		}
+		switch api {
+		case apigowhisper:
+			o.transcribe.Start = types.Float64Ptr(v.Seconds())
+			o.translate.Start = types.Float64Ptr(v.Seconds())
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptStart not supported")
+		}
+		return nil
+	}
+}
+
+// Transcribe the audio up to a time from the start of the audio
+func OptEnd(v time.Duration) Opt {
+	return func(api apitype, o *opts) error {
+		if v <= 0 {
+			return httpresponse.ErrBadRequest.Withf("invalid end %v", v)
+		}
+		switch api {
+		case apigowhisper:
+			o.transcribe.End = types.Float64Ptr(v.Seconds())
+			o.translate.End = types.Float64Ptr(v.Seconds())
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptEnd not supported")
+		}
+		return nil
+	}
+}
+
+// Filters applied to the audio before it is transcribed, as filter or
+// filter=value
+func OptPreprocess(filters ...string) Opt {
+	return func(api apitype, o *opts) error {
+		v := strings.Join(filters, ",")
+		switch api {
+		case apigowhisper:
+			o.transcribe.Preprocess = types.StringPtr(v)
+			o.translate.Preprocess = types.StringPtr(v)
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptPreprocess not supported")
+		}
 		return nil
 	}
 }
@@ -225,6 +411,35 @@ func OptDiarize() Opt {
 	}
 }
 
+// Number of languages to return from language detection
+func OptTopK(v uint64) Opt {
+	return func(api apitype, o *opts) error {
+		switch api {
+		case apigowhisper:
+			o.language.TopK = types.Uint64Ptr(v)
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptTopK not supported")
+		}
+		return nil
+	}
+}
+
+// Duration of audio, from the start, to use for language detection
+func OptDuration(v time.Duration) Opt {
+	return func(api apitype, o *opts) error {
+		if v <= 0 {
+			return httpresponse.ErrBadRequest.Withf("invalid duration %v", v)
+		}
+		switch api {
+		case apigowhisper:
+			o.language.Duration = types.Float64Ptr(v.Seconds())
+		default:
+			return httpresponse.ErrNotImplemented.Withf("OptDuration not supported")
+		}
+		return nil
+	}
+}
+
 /*
 // Word-level timestamp granularities to populate for this transcription.
 func OptGranularityWord() Opt {
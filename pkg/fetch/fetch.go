@@ -0,0 +1,229 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	// Packages
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Fetcher reads media from URLs on allowed hosts, with a limit on the size
+// of the media and a timeout when the server does not respond
+type Fetcher struct {
+	hosts   []string
+	maxSize int64
+	timeout time.Duration
+	client  *http.Client
+}
+
+type Opt func(*Fetcher) error
+
+// body is a response body which is limited in size, and cancels the
+// request when no data is read within the timeout
+type body struct {
+	io.ReadCloser
+	host      string
+	remaining int64
+	limit     int64
+	timer     *time.Timer
+	timeout   time.Duration
+	expired   atomic.Bool
+	cancel    context.CancelFunc
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Default timeout for connecting, and between reads
+	defaultTimeout = 30 * time.Second
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a fetcher for URLs on the allowed hosts. A host is a name or
+// address with an optional port, or a domain prefixed by "*." for any
+// subdomain. URLs cannot be read when there are no allowed hosts
+func New(hosts []string, opt ...Opt) (*Fetcher, error) {
+	fetcher := &Fetcher{timeout: defaultTimeout}
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host == "" {
+			continue
+		} else if strings.ContainsAny(host, "/@") {
+			return nil, httpresponse.ErrBadRequest.Withf("invalid host %q", host)
+		} else {
+			fetcher.hosts = append(fetcher.hosts, host)
+		}
+	}
+	for _, fn := range opt {
+		if err := fn(fetcher); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check redirects against the allowed hosts
+	fetcher.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: fetcher.timeout}).DialContext,
+			TLSHandshakeTimeout:   fetcher.timeout,
+			ResponseHeaderTimeout: fetcher.timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return httpresponse.ErrGatewayError.With("too many redirects")
+			}
+			return fetcher.check(req.URL)
+		},
+	}
+
+	// Return success
+	return fetcher, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Set the maximum size of the media in bytes. A *http.MaxBytesError is
+// returned when the media is larger
+func OptMaxSize(v int64) Opt {
+	return func(f *Fetcher) error {
+		if v < 0 {
+			return httpresponse.ErrBadRequest.Withf("invalid maximum size %d", v)
+		}
+		f.maxSize = v
+		return nil
+	}
+}
+
+// Set the timeout for connecting, receiving the response headers and
+// between reads of the response body
+func OptTimeout(v time.Duration) Opt {
+	return func(f *Fetcher) error {
+		if v <= 0 {
+			return httpresponse.ErrBadRequest.Withf("invalid timeout %v", v)
+		}
+		f.timeout = v
+		return nil
+	}
+}
+
+// Return the allowed hosts
+func (f *Fetcher) Hosts() []string {
+	return f.hosts
+}
+
+// Open a http or https URL, and return the response body, which should be
+// closed by the caller
+func (f *Fetcher) Open(ctx context.Context, rawurl string) (io.ReadCloser, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, httpresponse.ErrBadRequest.Withf("invalid URL: %v", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, httpresponse.ErrBadRequest.Withf("unsupported URL scheme %q", u.Scheme)
+	} else if err := f.check(u); err != nil {
+		return nil, err
+	}
+
+	// Make the request, which is cancelled when the body is closed
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		cancel()
+		return nil, httpresponse.ErrBadRequest.Withf("invalid URL: %v", err)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		cancel()
+		var urlerr *url.Error
+		var code httpresponse.Err
+		if errors.As(err, &urlerr) && errors.As(urlerr.Err, &code) {
+			return nil, urlerr.Err
+		}
+		return nil, httpresponse.ErrGatewayError.Withf("%s: %v", u.Host, err)
+	} else if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, httpresponse.ErrGatewayError.Withf("%s: %s", u.Host, resp.Status)
+	} else if f.maxSize > 0 && resp.ContentLength > f.maxSize {
+		resp.Body.Close()
+		cancel()
+		return nil, &http.MaxBytesError{Limit: f.maxSize}
+	}
+
+	// Return the body
+	b := &body{
+		ReadCloser: resp.Body,
+		host:       u.Host,
+		remaining:  f.maxSize,
+		limit:      f.maxSize,
+		timeout:    f.timeout,
+		cancel:     cancel,
+	}
+	b.timer = time.AfterFunc(f.timeout, func() {
+		b.expired.Store(true)
+		cancel()
+	})
+	return b, nil
+}
+
+// Read from the body, returning an error when it is larger than the limit
+func (b *body) Read(p []byte) (int, error) {
+	if b.limit > 0 && int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && b.expired.Load() {
+		return n, httpresponse.ErrGatewayError.Withf("%s: no data received for %v", b.host, b.timeout)
+	}
+	b.timer.Reset(b.timeout)
+	if b.limit > 0 {
+		if int64(n) > b.remaining {
+			return int(b.remaining), &http.MaxBytesError{Limit: b.limit}
+		}
+		b.remaining -= int64(n)
+	}
+	return n, err
+}
+
+// Close the body and cancel the request
+func (b *body) Close() error {
+	b.timer.Stop()
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return an error if the host of the URL is not allowed
+func (f *Fetcher) check(u *url.URL) error {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	for _, allowed := range f.hosts {
+		name, allowedPort, err := net.SplitHostPort(allowed)
+		if err != nil {
+			name, allowedPort = strings.Trim(allowed, "[]"), ""
+		}
+		if allowedPort != "" && allowedPort != port {
+			continue
+		}
+		if host == name {
+			return nil
+		} else if suffix, ok := strings.CutPrefix(name, "*"); ok && strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return httpresponse.ErrForbidden.Withf("host %q is not allowed", u.Host)
+}
@@ -0,0 +1,119 @@
+package fetch_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	// Packages
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	fetch "github.com/mutablelogic/go-whisper/pkg/fetch"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_fetch_001(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/audio.wav":
+			w.Write([]byte(strings.Repeat("a", 100)))
+		case "/redirect":
+			http.Redirect(w, r, "http://other.example.com/audio.wav", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	// No hosts are allowed
+	fetcher, err := fetch.New(nil)
+	if assert.NoError(err) {
+		_, err := fetcher.Open(context.Background(), server.URL+"/audio.wav")
+		assert.ErrorIs(err, httpresponse.ErrForbidden)
+	}
+
+	// The host is allowed
+	fetcher, err = fetch.New([]string{"other.example.com", u.Host})
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	if r, err := fetcher.Open(context.Background(), server.URL+"/audio.wav"); assert.NoError(err) {
+		data, err := io.ReadAll(r)
+		assert.NoError(err)
+		assert.Len(data, 100)
+		assert.NoError(r.Close())
+	}
+
+	// Unsupported scheme, and a file which is not found
+	_, err = fetcher.Open(context.Background(), "file:///etc/passwd")
+	assert.ErrorIs(err, httpresponse.ErrBadRequest)
+	_, err = fetcher.Open(context.Background(), server.URL+"/missing.wav")
+	assert.ErrorIs(err, httpresponse.ErrGatewayError)
+
+	// Redirect to a host which is not allowed
+	fetcher, err = fetch.New([]string{u.Host})
+	if assert.NoError(err) {
+		_, err := fetcher.Open(context.Background(), server.URL+"/redirect")
+		assert.ErrorIs(err, httpresponse.ErrForbidden)
+	}
+
+	// Wildcard and port
+	fetcher, err = fetch.New([]string{"*.example.com:8080"})
+	if assert.NoError(err) {
+		_, err := fetcher.Open(context.Background(), "http://files.example.com/audio.wav")
+		assert.ErrorIs(err, httpresponse.ErrForbidden)
+	}
+}
+
+func Test_fetch_002(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/length":
+			w.Write([]byte(strings.Repeat("a", 100)))
+		case "/chunked":
+			for i := 0; i < 10; i++ {
+				w.Write([]byte(strings.Repeat("a", 10)))
+				w.(http.Flusher).Flush()
+			}
+		case "/slow":
+			w.Write([]byte("a"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	fetcher, err := fetch.New([]string{u.Host}, fetch.OptMaxSize(50), fetch.OptTimeout(100*time.Millisecond))
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	// The content length is larger than the maximum size
+	var maxerr *http.MaxBytesError
+	_, err = fetcher.Open(context.Background(), server.URL+"/length")
+	assert.True(errors.As(err, &maxerr))
+
+	// The body is larger than the maximum size
+	if r, err := fetcher.Open(context.Background(), server.URL+"/chunked"); assert.NoError(err) {
+		data, err := io.ReadAll(r)
+		assert.True(errors.As(err, &maxerr))
+		assert.Len(data, 50)
+		r.Close()
+	}
+
+	// No data is received within the timeout
+	if r, err := fetcher.Open(context.Background(), server.URL+"/slow"); assert.NoError(err) {
+		_, err := io.ReadAll(r)
+		assert.ErrorIs(err, httpresponse.ErrGatewayError)
+		r.Close()
+	}
+}
@@ -0,0 +1,254 @@
+package format
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Writer writes a transcription document as segments are transcribed
+type Writer interface {
+	// Write a segment
+	Write(seg *schema.Segment) error
+
+	// Complete the document. The transcription sets the task, language and
+	// duration for JSON documents, and can be nil
+	Close(t *schema.Transcription) error
+}
+
+type textWriter struct {
+	w io.Writer
+	n int
+}
+
+type srtWriter struct {
+	w io.Writer
+	n int
+}
+
+type vttWriter struct {
+	w io.Writer
+	n int
+}
+
+type jsonWriter struct {
+	w        io.Writer
+	verbose  bool
+	segments []*schema.Segment
+	text     strings.Builder
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	Text        = "text"
+	Srt         = "srt"
+	Vtt         = "vtt"
+	Json        = "json"
+	VerboseJson = "verbose_json"
+)
+
+var (
+	// Supported formats
+	Formats = []string{Text, Srt, Vtt, Json, VerboseJson}
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return a writer for a format. The format "txt" is the same as "text"
+func New(w io.Writer, format string) (Writer, error) {
+	switch Name(format) {
+	case Text:
+		return &textWriter{w: w}, nil
+	case Srt:
+		return &srtWriter{w: w}, nil
+	case Vtt:
+		return &vttWriter{w: w}, nil
+	case Json:
+		return &jsonWriter{w: w}, nil
+	case VerboseJson:
+		return &jsonWriter{w: w, verbose: true}, nil
+	default:
+		return nil, ErrBadParameter.Withf("unsupported format %q", format)
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the name of a format, or empty if the format is not supported
+func Name(format string) string {
+	switch format = strings.ToLower(strings.TrimSpace(format)); format {
+	case "txt":
+		return Text
+	case Text, Srt, Vtt, Json, VerboseJson:
+		return format
+	default:
+		return ""
+	}
+}
+
+// Return the file extension for a format, including the dot
+func Ext(format string) string {
+	switch Name(format) {
+	case Text:
+		return ".txt"
+	case VerboseJson:
+		return ".json"
+	default:
+		return "." + Name(format)
+	}
+}
+
+// Return the content type for a format
+func ContentType(format string) string {
+	switch Name(format) {
+	case Srt:
+		return "application/x-subrip"
+	case Vtt:
+		return "text/vtt"
+	case Json, VerboseJson:
+		return "application/json"
+	default:
+		return "text/plain"
+	}
+}
+
+// Write a transcription to a writer in a format
+func Write(w io.Writer, format string, t *schema.Transcription) error {
+	writer, err := New(w, format)
+	if err != nil {
+		return err
+	}
+	for _, seg := range t.Segments {
+		if err := writer.Write(seg); err != nil {
+			return err
+		}
+	}
+	return writer.Close(t)
+}
+
+// Return a copy of a segment with timestamps offset, for segments which are
+// relative to the start of a chunk of audio rather than the start of the file
+func Offset(seg *schema.Segment, ts time.Duration) *schema.Segment {
+	copy := *seg
+	copy.Start += schema.Timestamp(ts)
+	copy.End += schema.Timestamp(ts)
+	return &copy
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// TEXT
+
+// Segments without text are skipped
+func (t *textWriter) Write(seg *schema.Segment) error {
+	if strings.TrimSpace(seg.Text) == "" {
+		return nil
+	}
+	seg = renumber(seg, t.n)
+	t.n++
+	seg.WriteText(t.w)
+	return nil
+}
+
+func (t *textWriter) Close(*schema.Transcription) error {
+	if t.n > 0 {
+		_, err := io.WriteString(t.w, "\n")
+		return err
+	}
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// SRT
+
+// Cues are numbered from one, and segments without text are skipped
+func (t *srtWriter) Write(seg *schema.Segment) error {
+	if strings.TrimSpace(seg.Text) == "" {
+		return nil
+	}
+	t.n++
+	renumber(seg, t.n).WriteSRT(t.w, 0)
+	return nil
+}
+
+func (t *srtWriter) Close(*schema.Transcription) error {
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// VTT
+
+// The header is written before the first cue, or when the document is
+// closed with no cues. Segments without text are skipped
+func (t *vttWriter) Write(seg *schema.Segment) error {
+	if strings.TrimSpace(seg.Text) == "" {
+		return nil
+	} else if t.n == 0 {
+		if _, err := io.WriteString(t.w, "WEBVTT\n\n"); err != nil {
+			return err
+		}
+	}
+	t.n++
+	seg.WriteVTT(t.w, 0)
+	return nil
+}
+
+func (t *vttWriter) Close(*schema.Transcription) error {
+	if t.n == 0 {
+		_, err := io.WriteString(t.w, "WEBVTT\n\n")
+		return err
+	}
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// JSON
+
+// Segments are collected, and written as one document when closed
+func (t *jsonWriter) Write(seg *schema.Segment) error {
+	if strings.TrimSpace(seg.Text) != "" {
+		t.text.WriteString(seg.Text)
+	}
+	t.segments = append(t.segments, renumber(seg, len(t.segments)))
+	return nil
+}
+
+func (t *jsonWriter) Close(result *schema.Transcription) error {
+	var doc schema.Transcription
+	if t.verbose {
+		if result != nil {
+			doc.Task, doc.Language, doc.Duration, doc.Timings = result.Task, result.Language, result.Duration, result.Timings
+		}
+		doc.Segments = t.segments
+	}
+	doc.Text = strings.TrimSpace(t.text.String())
+
+	// Write the document
+	enc := json.NewEncoder(t.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return a copy of the segment with an id, so that segments are numbered
+// continuously across chunks of audio
+func renumber(seg *schema.Segment, id int) *schema.Segment {
+	copy := *seg
+	copy.Id = int32(id)
+	return &copy
+}
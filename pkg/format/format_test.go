@@ -0,0 +1,89 @@
+package format_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	// Packages
+	format "github.com/mutablelogic/go-whisper/pkg/format"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func segments() []*schema.Segment {
+	return []*schema.Segment{
+		{Id: 0, Start: schema.Timestamp(0), End: schema.Timestamp(1500 * time.Millisecond), Text: " Hello"},
+		{Id: 0, Start: schema.Timestamp(30 * time.Second), End: schema.Timestamp(32 * time.Second), Text: " world"},
+	}
+}
+
+func Test_format_001(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(format.Text, format.Name("txt"))
+	assert.Equal(format.VerboseJson, format.Name("VERBOSE_JSON"))
+	assert.Equal("", format.Name("docx"))
+	assert.Equal(".txt", format.Ext("text"))
+	assert.Equal(".json", format.Ext("verbose_json"))
+	assert.Equal(".srt", format.Ext("srt"))
+
+	_, err := format.New(&bytes.Buffer{}, "docx")
+	assert.Error(err)
+}
+
+func Test_format_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Cues are numbered continuously, even when segment ids restart
+	var buf bytes.Buffer
+	assert.NoError(format.Write(&buf, format.Srt, &schema.Transcription{Segments: segments()}))
+	assert.Equal("1\n00:00:00,000 --> 00:00:01,500\nHello\n\n2\n00:00:30,000 --> 00:00:32,000\nworld\n\n", buf.String())
+}
+
+func Test_format_003(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(format.Write(&buf, format.Vtt, &schema.Transcription{Segments: segments()}))
+	assert.Equal("WEBVTT\n\n00:00:00.000 --> 00:00:01.500\nHello\n\n00:00:30.000 --> 00:00:32.000\nworld\n\n", buf.String())
+
+	// An empty document has a header
+	buf.Reset()
+	assert.NoError(format.Write(&buf, format.Vtt, &schema.Transcription{}))
+	assert.Equal("WEBVTT\n\n", buf.String())
+}
+
+func Test_format_004(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(format.Write(&buf, format.Text, &schema.Transcription{Segments: segments()}))
+	assert.Equal("Hello world\n", buf.String())
+}
+
+func Test_format_005(t *testing.T) {
+	assert := assert.New(t)
+
+	// Segments are written as one document
+	var buf bytes.Buffer
+	assert.NoError(format.Write(&buf, format.VerboseJson, &schema.Transcription{Task: "transcribe", Language: "en", Segments: segments()}))
+
+	var doc schema.Transcription
+	if assert.NoError(json.Unmarshal(buf.Bytes(), &doc)) {
+		assert.Equal("transcribe", doc.Task)
+		assert.Equal("en", doc.Language)
+		assert.Equal("Hello world", doc.Text)
+		if assert.Len(doc.Segments, 2) {
+			assert.Equal(int32(0), doc.Segments[0].Id)
+			assert.Equal(int32(1), doc.Segments[1].Id)
+			assert.Equal(schema.Timestamp(30*time.Second), doc.Segments[1].Start)
+		}
+	}
+
+	// The json format has only text
+	buf.Reset()
+	assert.NoError(format.Write(&buf, format.Json, &schema.Transcription{Segments: segments()}))
+	assert.Equal("{\n  \"text\": \"Hello world\"\n}\n", buf.String())
+}
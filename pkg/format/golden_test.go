@@ -0,0 +1,117 @@
+package format_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// Packages
+	format "github.com/mutablelogic/go-whisper/pkg/format"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	assert "github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// A chunk of segments from the remote engine, with timestamps relative to
+// the start of the chunk, and ids which restart for each chunk
+type chunk struct {
+	ts       time.Duration
+	segments []*schema.Segment
+}
+
+func seconds(v float64) schema.Timestamp {
+	return schema.Timestamp(time.Duration(v * float64(time.Second)))
+}
+
+// Segments from the local engine, with timestamps relative to the start of
+// the file, and ids which continue across chunks
+func localSegments() []*schema.Segment {
+	return []*schema.Segment{
+		{Id: 0, Start: seconds(0), End: seconds(2.5), Text: " And so, my fellow Americans,"},
+		{Id: 1, Start: seconds(2.5), End: seconds(5.25), Text: " ask not what your country can do for you,"},
+		{Id: 2, Start: seconds(3598), End: seconds(3601.125), Text: " ask what you can do for your country."},
+		{Id: 3, Start: seconds(3601.125), End: seconds(3602), Text: " "},
+		{Id: 4, Start: seconds(3602), End: seconds(3604), Text: " Thank you.", SpeakerTurn: true},
+	}
+}
+
+// The same segments from the remote engine
+func remoteChunks() []chunk {
+	return []chunk{
+		{0, []*schema.Segment{
+			{Id: 0, Start: seconds(0), End: seconds(2.5), Text: " And so, my fellow Americans,"},
+			{Id: 1, Start: seconds(2.5), End: seconds(5.25), Text: " ask not what your country can do for you,"},
+		}},
+		{3598 * time.Second, []*schema.Segment{
+			{Id: 0, Start: seconds(0), End: seconds(3.125), Text: " ask what you can do for your country."},
+			{Id: 1, Start: seconds(3.125), End: seconds(4), Text: " "},
+			{Id: 2, Start: seconds(4), End: seconds(6), Text: " Thank you.", SpeakerTurn: true},
+		}},
+	}
+}
+
+func Test_golden_001(t *testing.T) {
+	result := &schema.Transcription{Task: "transcribe", Language: "en", Duration: seconds(3604)}
+	for _, name := range format.Formats {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Write segments from the local engine
+			var local bytes.Buffer
+			w, err := format.New(&local, name)
+			if !assert.NoError(err) {
+				t.FailNow()
+			}
+			for _, seg := range localSegments() {
+				assert.NoError(w.Write(seg))
+			}
+			assert.NoError(w.Close(result))
+
+			// Write segments from the remote engine, offset by the chunk
+			var remote bytes.Buffer
+			w, err = format.New(&remote, name)
+			if !assert.NoError(err) {
+				t.FailNow()
+			}
+			for _, chunk := range remoteChunks() {
+				for _, seg := range chunk.segments {
+					assert.NoError(w.Write(format.Offset(seg, chunk.ts)))
+				}
+			}
+			assert.NoError(w.Close(result))
+
+			// The documents are the same, and match the golden file
+			assert.Equal(local.String(), remote.String())
+			golden(t, filepath.Join("testdata", "transcription."+name+".golden"), local.Bytes())
+		})
+	}
+}
+
+func Test_golden_002(t *testing.T) {
+	// Documents without segments
+	for _, name := range format.Formats {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, format.Write(&buf, name, &schema.Transcription{}))
+			golden(t, filepath.Join("testdata", "empty."+name+".golden"), buf.Bytes())
+		})
+	}
+}
+
+// Compare the data with a golden file, or write the golden file when the
+// -update flag is set
+func golden(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected, err := os.ReadFile(path); assert.NoError(t, err) {
+		assert.Equal(t, string(expected), string(data))
+	}
+}
@@ -0,0 +1,376 @@
+package limit
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Limits for each API key or client address. A zero value means there is
+// no limit
+type Limits struct {
+	RequestsPerMinute  int     `json:"requests_per_minute,omitempty"`
+	AudioMinutesPerDay float64 `json:"audio_minutes_per_day,omitempty"`
+	MaxConcurrent      int     `json:"max_concurrent,omitempty"`
+}
+
+// Usage is the number of requests and the audio processed by an API key
+// or client address on a day. The name of an API key is set when the
+// usage is returned
+type Usage struct {
+	Id       string  `json:"id"`
+	Name     string  `json:"name,omitempty"`
+	Date     string  `json:"date"`
+	Requests uint64  `json:"requests"`
+	Rejected uint64  `json:"rejected"`
+	Audio    float64 `json:"audio_seconds"`
+}
+
+// Limiter applies limits to each API key or client address, and counts
+// usage, which is saved to a file
+type Limiter struct {
+	sync.Mutex
+	Limits
+
+	path    string
+	clients map[string]*client
+	swept   time.Time
+	usage   map[usageKey]*Usage
+	dirty   bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Error is returned when a limit is exceeded, with the time after which
+// the request can be retried
+type LimitError struct {
+	Code       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+// client is the rate limiting state for an API key or client address
+type client struct {
+	tokens  float64
+	updated time.Time
+	used    time.Time
+	jobs    int
+}
+
+type usageKey struct {
+	id, date string
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Codes for errors
+	CodeRequests   = "rate_limit_exceeded"
+	CodeConcurrent = "concurrent_limit_exceeded"
+	CodeQuota      = "insufficient_quota"
+
+	// Usage is kept for this number of days
+	usageDays = 31
+
+	// Usage is saved to the file at this interval, when it has changed
+	saveInterval = 10 * time.Second
+
+	// The state for a client is removed when it has not been used for this
+	// time, after which the request rate bucket is full
+	idleInterval = time.Minute
+
+	// Format for the usage date, in UTC
+	dateFormat = time.DateOnly
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a limiter, which loads and saves usage to a file. The usage is not
+// saved if the path is empty
+func New(limits Limits, path string) (*Limiter, error) {
+	if limits.RequestsPerMinute < 0 || limits.AudioMinutesPerDay < 0 || limits.MaxConcurrent < 0 {
+		return nil, ErrBadParameter.With("limits cannot be negative")
+	}
+
+	limiter := &Limiter{
+		Limits:  limits,
+		path:    path,
+		clients: make(map[string]*client),
+		usage:   make(map[usageKey]*Usage),
+		done:    make(chan struct{}),
+	}
+
+	// Load the usage, and save it in the background
+	if path != "" {
+		if err := limiter.load(); err != nil {
+			return nil, err
+		}
+		limiter.wg.Add(1)
+		go limiter.run()
+	}
+
+	// Return success
+	return limiter, nil
+}
+
+// Save the usage and stop the limiter
+func (limiter *Limiter) Close() error {
+	if limiter.path == "" {
+		return nil
+	}
+	close(limiter.done)
+	limiter.wg.Wait()
+	return limiter.save()
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Start a request for an API key or client address. Returns an error if
+// the request rate, the daily audio quota or the number of concurrent
+// requests is exceeded. Otherwise, the release function should be called
+// when the request is complete
+func (limiter *Limiter) Acquire(id string) (func(), error) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	now := time.Now()
+	usage := limiter.today(id, now)
+	limiter.sweep(now)
+	state, exists := limiter.clients[id]
+	if !exists {
+		state = &client{tokens: float64(limiter.RequestsPerMinute), updated: now}
+		limiter.clients[id] = state
+	}
+	state.used = now
+
+	// Check the daily audio quota, which resets at midnight UTC
+	if limiter.AudioMinutesPerDay > 0 && usage.Audio >= limiter.AudioMinutesPerDay*60 {
+		usage.Rejected++
+		limiter.dirty = true
+		midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		return nil, &LimitError{
+			Code:       CodeQuota,
+			Message:    "Daily audio quota exceeded",
+			RetryAfter: midnight.Sub(now),
+		}
+	}
+
+	// Check the number of concurrent requests
+	if limiter.MaxConcurrent > 0 && state.jobs >= limiter.MaxConcurrent {
+		usage.Rejected++
+		limiter.dirty = true
+		return nil, &LimitError{
+			Code:       CodeConcurrent,
+			Message:    "Too many concurrent requests",
+			RetryAfter: time.Second,
+		}
+	}
+
+	// Check the request rate, with a bucket which holds a minute of requests
+	// and refills continuously
+	if rate := float64(limiter.RequestsPerMinute) / 60; rate > 0 {
+		state.tokens = math.Min(float64(limiter.RequestsPerMinute), state.tokens+now.Sub(state.updated).Seconds()*rate)
+		state.updated = now
+		if state.tokens < 1 {
+			usage.Rejected++
+			limiter.dirty = true
+			return nil, &LimitError{
+				Code:       CodeRequests,
+				Message:    "Rate limit exceeded",
+				RetryAfter: time.Duration((1 - state.tokens) / rate * float64(time.Second)),
+			}
+		}
+		state.tokens--
+	}
+
+	// Count the request
+	usage.Requests++
+	state.jobs++
+	limiter.dirty = true
+
+	// Return the release function
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			limiter.Lock()
+			defer limiter.Unlock()
+			state.jobs--
+			state.used = time.Now()
+		})
+	}, nil
+}
+
+// Add audio processed for an API key or client address to the usage for today
+func (limiter *Limiter) AddAudio(id string, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	limiter.Lock()
+	defer limiter.Unlock()
+	limiter.today(id, time.Now()).Audio += duration.Seconds()
+	limiter.dirty = true
+}
+
+// Return the usage for an API key or client address, or for all if the id
+// is empty, ordered by date and id
+func (limiter *Limiter) Usage(id string) []Usage {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	result := make([]Usage, 0, len(limiter.usage))
+	for key, usage := range limiter.usage {
+		if id == "" || key.id == id {
+			result = append(result, *usage)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the usage for today. Should be called with the lock held
+func (limiter *Limiter) today(id string, now time.Time) *Usage {
+	key := usageKey{id, now.UTC().Format(dateFormat)}
+	usage, exists := limiter.usage[key]
+	if !exists {
+		usage = &Usage{Id: key.id, Date: key.date}
+		limiter.usage[key] = usage
+		limiter.expire(now)
+	}
+	return usage
+}
+
+// Remove the state for clients which have no requests in progress and have
+// been idle for long enough that their state is the same as a new client.
+// Should be called with the lock held
+func (limiter *Limiter) sweep(now time.Time) {
+	if now.Sub(limiter.swept) < idleInterval {
+		return
+	}
+	limiter.swept = now
+	for id, state := range limiter.clients {
+		if state.jobs == 0 && now.Sub(state.used) >= idleInterval {
+			delete(limiter.clients, id)
+		}
+	}
+}
+
+// Remove usage older than the retention period. Should be called with the
+// lock held
+func (limiter *Limiter) expire(now time.Time) {
+	oldest := now.UTC().AddDate(0, 0, -usageDays).Format(dateFormat)
+	for key := range limiter.usage {
+		if key.date < oldest {
+			delete(limiter.usage, key)
+		}
+	}
+}
+
+// Save the usage in the background when it changes
+func (limiter *Limiter) run() {
+	defer limiter.wg.Done()
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-limiter.done:
+			return
+		case <-ticker.C:
+			limiter.save()
+		}
+	}
+}
+
+// Load the usage from the file, if it exists
+func (limiter *Limiter) load() error {
+	data, err := os.ReadFile(limiter.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var usage []*Usage
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return ErrBadParameter.Withf("%s: %v", limiter.path, err)
+	}
+	for _, usage := range usage {
+		limiter.usage[usageKey{usage.Id, usage.Date}] = usage
+	}
+	limiter.expire(time.Now())
+
+	// Return success
+	return nil
+}
+
+// Save the usage to the file if it has changed, replacing the file so it
+// is not left partially written
+func (limiter *Limiter) save() error {
+	limiter.Lock()
+	if !limiter.dirty {
+		limiter.Unlock()
+		return nil
+	}
+	usage := make([]Usage, 0, len(limiter.usage))
+	for _, u := range limiter.usage {
+		usage = append(usage, *u)
+	}
+	limiter.dirty = false
+	limiter.Unlock()
+
+	// Mark the usage as changed if it could not be saved
+	err := limiter.write(usage)
+	if err != nil {
+		limiter.Lock()
+		limiter.dirty = true
+		limiter.Unlock()
+	}
+	return err
+}
+
+func (limiter *Limiter) write(usage []Usage) error {
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(limiter.path), ".usage-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	} else if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), limiter.path)
+}
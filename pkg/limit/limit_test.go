@@ -0,0 +1,179 @@
+package limit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	// Packages
+	auth "github.com/mutablelogic/go-whisper/pkg/auth"
+	limit "github.com/mutablelogic/go-whisper/pkg/limit"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_limit_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Requests per minute
+	limiter, err := limit.New(limit.Limits{RequestsPerMinute: 2}, "")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	for i := 0; i < 2; i++ {
+		release, err := limiter.Acquire("a")
+		if assert.NoError(err) {
+			release()
+		}
+	}
+	_, err = limiter.Acquire("a")
+	if assert.IsType(&limit.LimitError{}, err) {
+		assert.Equal(limit.CodeRequests, err.(*limit.LimitError).Code)
+		assert.InDelta(30*time.Second, err.(*limit.LimitError).RetryAfter, float64(time.Second))
+	}
+
+	// Other clients are not limited
+	_, err = limiter.Acquire("b")
+	assert.NoError(err)
+
+	usage := limiter.Usage("a")
+	if assert.Len(usage, 1) {
+		assert.Equal(uint64(2), usage[0].Requests)
+		assert.Equal(uint64(1), usage[0].Rejected)
+	}
+	assert.Len(limiter.Usage(""), 2)
+}
+
+func Test_limit_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Concurrent requests
+	limiter, err := limit.New(limit.Limits{MaxConcurrent: 1}, "")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	release, err := limiter.Acquire("a")
+	assert.NoError(err)
+	_, err = limiter.Acquire("a")
+	if assert.Error(err) {
+		assert.Equal(limit.CodeConcurrent, err.(*limit.LimitError).Code)
+	}
+	release()
+	release()
+	release, err = limiter.Acquire("a")
+	assert.NoError(err)
+	release()
+
+	_, err = limit.New(limit.Limits{MaxConcurrent: -1}, "")
+	assert.Error(err)
+}
+
+func Test_limit_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// Audio quota, which is saved and loaded
+	path := filepath.Join(t.TempDir(), "usage.json")
+	limiter, err := limit.New(limit.Limits{AudioMinutesPerDay: 1}, path)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	release, err := limiter.Acquire("a")
+	assert.NoError(err)
+	limiter.AddAudio("a", 90*time.Second)
+	release()
+	assert.NoError(limiter.Close())
+
+	limiter, err = limit.New(limit.Limits{AudioMinutesPerDay: 1}, path)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	defer limiter.Close()
+	usage := limiter.Usage("a")
+	if assert.Len(usage, 1) {
+		assert.Equal(90.0, usage[0].Audio)
+		assert.Equal(time.Now().UTC().Format(time.DateOnly), usage[0].Date)
+	}
+	_, err = limiter.Acquire("a")
+	if assert.Error(err) {
+		assert.Equal(limit.CodeQuota, err.(*limit.LimitError).Code)
+	}
+}
+
+func Test_limit_004(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter, err := limit.New(limit.Limits{RequestsPerMinute: 1}, "")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	handler := limit.Middleware(limiter, nil)(func(w http.ResponseWriter, r *http.Request) {
+		limit.AddAudio(r.Context(), time.Minute)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// The first request is served, and the second is rejected with an
+	// OpenAI-style error
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(err)
+	assert.True(retry > 0 && retry <= 60)
+	var response schema.Error
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(schema.ErrorTypeRateLimit, response.Err.Type)
+	if assert.NotNil(response.Err.Code) {
+		assert.Equal(limit.CodeRequests, *response.Err.Code)
+	}
+
+	// Audio is added for the client address
+	usage := limiter.Usage("192.0.2.1")
+	if assert.Len(usage, 1) {
+		assert.Equal(60.0, usage[0].Audio)
+	}
+
+	// No limiter, and no request in the context
+	assert.NotNil(limit.Middleware(nil, nil)(handler))
+	limit.AddAudio(context.Background(), time.Minute)
+}
+
+func Test_limit_005(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter, err := limit.New(limit.Limits{}, "")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	handler := limit.Middleware(limiter, nil)(func(w http.ResponseWriter, r *http.Request) {
+		limit.AddAudio(r.Context(), time.Minute)
+	})
+
+	// Usage is counted for each API key, by id rather than name
+	keys := auth.NewKeys()
+	assert.NoError(keys.Add("token-0123456789abcdef", "app"))
+	assert.NoError(keys.Add("token-fedcba9876543210", "app"))
+	for _, token := range []string{"token-0123456789abcdef", "token-fedcba9876543210", "token-fedcba9876543210"} {
+		key := keys.Lookup(token)
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		handler(httptest.NewRecorder(), r.WithContext(auth.WithKey(r.Context(), key)))
+	}
+	usage := limiter.Usage("")
+	if assert.Len(usage, 2) {
+		for _, usage := range usage {
+			key := keys.ById(usage.Id)
+			if assert.NotNil(key) {
+				assert.Equal("app", key.Name)
+			}
+		}
+	}
+	assert.Equal(120.0, limiter.Usage(keys.Lookup("token-fedcba9876543210").Id)[0].Audio)
+}
@@ -0,0 +1,100 @@
+package limit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	// Packages
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	auth "github.com/mutablelogic/go-whisper/pkg/auth"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type requestKey struct{}
+
+type request struct {
+	limiter *Limiter
+	id      string
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return middleware which applies the limits to each request, for the API
+// key of the request or the client address when there is no key. A 429
+// error with a Retry-After header is returned when a limit is exceeded,
+// written with errfn or in the form returned by the OpenAI API when errfn
+// is nil. If the limiter is nil, the handler is returned unchanged
+func Middleware(limiter *Limiter, errfn func(http.ResponseWriter, error) error) func(http.HandlerFunc) http.HandlerFunc {
+	if errfn == nil {
+		errfn = writeError
+	}
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if limiter == nil {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			id := Id(r)
+			release, err := limiter.Acquire(id)
+			if limit, ok := err.(*LimitError); ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(limit.RetryAfter.Seconds())))))
+			}
+			if err != nil {
+				errfn(w, err)
+				return
+			}
+			defer release()
+
+			// Serve the request, so the audio processed can be added to the usage
+			next(w, r.WithContext(context.WithValue(r.Context(), requestKey{}, request{limiter, id})))
+		}
+	}
+}
+
+// Add the audio processed by a request to the usage for the API key or
+// client address
+func AddAudio(ctx context.Context, duration time.Duration) {
+	if req, ok := ctx.Value(requestKey{}).(request); ok {
+		req.limiter.AddAudio(req.id, duration)
+	}
+}
+
+// Return the OpenAI error type for a limit error, which is insufficient_quota
+// when the daily audio quota is exceeded
+func (e *LimitError) Type() string {
+	if e.Code == CodeQuota {
+		return schema.ErrorTypeQuota
+	}
+	return schema.ErrorTypeRateLimit
+}
+
+// Return the id for a request, which is the id of the API key or the
+// client address
+func Id(r *http.Request) string {
+	if key := auth.KeyFromContext(r.Context()); key != nil {
+		return key.Id
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Write an error in the form returned by the OpenAI API
+func writeError(w http.ResponseWriter, err error) error {
+	limit, ok := err.(*LimitError)
+	if !ok {
+		return httpresponse.JSON(w, http.StatusInternalServerError, 0, schema.NewError(schema.ErrorTypeServer, "", err.Error()))
+	}
+	return httpresponse.JSON(w, http.StatusTooManyRequests, 0, schema.NewError(limit.Type(), limit.Code, limit.Message))
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	// Packages
+	logger "github.com/mutablelogic/go-server/pkg/logger"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Format is the output format for log lines
+type Format string
+
+// handler adds the request id from the context to each log record
+type handler struct {
+	slog.Handler
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+	FormatTerm Format = "term"
+)
+
+const (
+	// Attribute for the request id
+	RequestIdKey = "request_id"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new structured logger which writes to w in the specified format
+// (text, json or term). Debug messages are only written when debug is true.
+// The request id in the context is added to each log line
+func New(w io.Writer, format Format, debug bool) (*slog.Logger, error) {
+	var f logger.Format
+	switch Format(strings.ToLower(string(format))) {
+	case FormatText:
+		f = logger.Text
+	case FormatJSON:
+		f = logger.JSON
+	case FormatTerm, "":
+		f = logger.Term
+	default:
+		return nil, ErrBadParameter.Withf("unsupported log format: %q", format)
+	}
+	return slog.New(NewHandler(logger.New(w, f, debug).Handler())), nil
+}
+
+// Return a handler which adds the request id from the context to each
+// log record
+func NewHandler(h slog.Handler) slog.Handler {
+	if _, ok := h.(*handler); ok {
+		return h
+	}
+	return &handler{h}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestId(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIdKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{h.Handler.WithGroup(name)}
+}
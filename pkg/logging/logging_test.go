@@ -0,0 +1,73 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	// Packages
+	logging "github.com/mutablelogic/go-whisper/pkg/logging"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_logging_001(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	log, err := logging.New(&buf, logging.FormatJSON, false)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	log.InfoContext(logging.WithRequestId(context.Background(), "abc"), "hello", "key", "value")
+	log.DebugContext(context.Background(), "not logged")
+
+	var line map[string]any
+	assert.NoError(json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal("hello", line["msg"])
+	assert.Equal("abc", line[logging.RequestIdKey])
+	assert.Equal("value", line["key"])
+
+	_, err = logging.New(&buf, "xml", false)
+	assert.Error(err)
+}
+
+func Test_logging_002(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	log, err := logging.New(&buf, logging.FormatText, false)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	var id string
+	handler := logging.Middleware(log)(func(w http.ResponseWriter, r *http.Request) {
+		id = logging.RequestId(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Generated request id
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+	assert.NotEmpty(id)
+	assert.Equal(id, w.Header().Get(logging.RequestIdHeader))
+	assert.Contains(buf.String(), "request_id="+id)
+
+	// Request id from the client
+	r := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+	r.Header.Set(logging.RequestIdHeader, "client-id.1")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal("client-id.1", id)
+
+	// Invalid request id from the client is replaced
+	r = httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+	r.Header.Set(logging.RequestIdHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.NotEqual("bad id\n", id)
+	assert.Equal(id, w.Header().Get(logging.RequestIdHeader))
+}
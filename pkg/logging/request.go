@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	// Packages
+	logger "github.com/mutablelogic/go-server/pkg/logger"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type requestIdKey struct{}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Header for the request id, which is accepted from the client or
+	// generated, and returned in the response
+	RequestIdHeader = "X-Request-Id"
+
+	// Maximum length of a request id accepted from the client
+	maxRequestId = 64
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return a new random request id
+func NewRequestId() string {
+	var data [8]byte
+	if _, err := rand.Read(data[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(data[:])
+}
+
+// Return a context with the request id
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// Return the request id from the context, or empty if there is none
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// Return middleware which sets a request id on each request, returns it in
+// the X-Request-Id header, and logs each request with the request id
+func Middleware(log *slog.Logger) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now()
+
+			// Accept a request id from the client, or generate one
+			id := r.Header.Get(RequestIdHeader)
+			if !validRequestId(id) {
+				id = NewRequestId()
+			}
+			w.Header().Set(RequestIdHeader, id)
+			r = r.WithContext(WithRequestId(r.Context(), id))
+
+			// Serve the request
+			nw := logger.NewResponseWriter(w)
+			next(nw, r)
+
+			// Log the response
+			level := slog.LevelInfo
+			if nw.Status() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			log.Log(r.Context(), level, fmt.Sprintf("%v %v -> [%v]", r.Method, r.URL, nw.Status()),
+				"delta_ms", time.Since(now).Milliseconds(),
+				"method", r.Method,
+				"status", nw.Status(),
+				"path", r.URL.Path,
+				"size", nw.Size(),
+			)
+		}
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Request ids from the client are limited to letters, digits and some
+// punctuation, so they can be safely logged and returned
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestId {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Metrics collects request counts and timings per model, which are written
+// in the Prometheus text exposition format
+type Metrics struct {
+	sync.Mutex
+
+	requests map[request]uint64
+	audio    map[string]float64
+	stages   map[stage]float64
+}
+
+// Gauge is a value which is sampled when the metrics are written, such as
+// the number of contexts in use
+type Gauge struct {
+	Name  string
+	Help  string
+	Value float64
+}
+
+type request struct {
+	model, task, status string
+}
+
+type stage struct {
+	model, stage string
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+var (
+	// Escape label values
+	escape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new metrics collector
+func New() *Metrics {
+	return &Metrics{
+		requests: make(map[request]uint64),
+		audio:    make(map[string]float64),
+		stages:   make(map[stage]float64),
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Record a successful request for a model and task (transcribe or translate),
+// with the duration of the audio and the request timings, which can be nil
+func (m *Metrics) Observe(model, task string, duration time.Duration, timings *schema.Timings) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.requests[request{model, task, StatusOK}]++
+	m.audio[model] += duration.Seconds()
+	if timings == nil {
+		return
+	}
+	for name, value := range map[string]schema.Timestamp{
+		"audio_decode": timings.AudioDecode,
+		"wait":         timings.Wait,
+		"load":         timings.Load,
+		"detect":       timings.Detect,
+		"prepare":      timings.Prepare,
+		"first_token":  timings.FirstToken,
+		"tokens":       timings.Tokens,
+		"total":        timings.Total,
+	} {
+		m.stages[stage{model, name}] += time.Duration(value).Seconds()
+	}
+}
+
+// Record a failed request for a model and task
+func (m *Metrics) Error(model, task string) {
+	m.Lock()
+	defer m.Unlock()
+	m.requests[request{model, task, StatusError}]++
+}
+
+// Write the metrics and gauges in the Prometheus text exposition format
+func (m *Metrics) Write(w io.Writer, gauges ...Gauge) error {
+	m.Lock()
+	defer m.Unlock()
+
+	var b strings.Builder
+
+	// Gauges
+	for _, gauge := range gauges {
+		header(&b, gauge.Name, gauge.Help, "gauge")
+		fmt.Fprintf(&b, "%s %v\n", gauge.Name, gauge.Value)
+	}
+
+	// Requests
+	header(&b, "whisper_requests_total", "Number of transcription and translation requests", "counter")
+	requests := make([]request, 0, len(m.requests))
+	for key := range m.requests {
+		requests = append(requests, key)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].String() < requests[j].String()
+	})
+	for _, key := range requests {
+		fmt.Fprintf(&b, "whisper_requests_total{%s} %d\n", key, m.requests[key])
+	}
+
+	// Audio duration
+	header(&b, "whisper_audio_seconds_total", "Duration of audio processed", "counter")
+	models := make([]string, 0, len(m.audio))
+	for model := range m.audio {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		fmt.Fprintf(&b, "whisper_audio_seconds_total{%s} %v\n", labels("model", model), m.audio[model])
+	}
+
+	// Processing time for each stage. The real time factor is the total
+	// processing time divided by the audio duration
+	header(&b, "whisper_processing_seconds_total", "Time spent processing requests, by stage", "counter")
+	stages := make([]stage, 0, len(m.stages))
+	for key := range m.stages {
+		stages = append(stages, key)
+	}
+	sort.Slice(stages, func(i, j int) bool {
+		return stages[i].String() < stages[j].String()
+	})
+	for _, key := range stages {
+		fmt.Fprintf(&b, "whisper_processing_seconds_total{%s} %v\n", key, m.stages[key])
+	}
+
+	// Write the metrics
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (r request) String() string {
+	return labels("model", r.model, "task", r.task, "status", r.status)
+}
+
+func (s stage) String() string {
+	return labels("model", s.model, "stage", s.stage)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func header(b *strings.Builder, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+}
+
+// Return label pairs, escaping the values
+func labels(kv ...string) string {
+	var pairs []string
+	for i := 0; i+1 < len(kv); i += 2 {
+		pairs = append(pairs, kv[i]+`="`+escape.Replace(kv[i+1])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
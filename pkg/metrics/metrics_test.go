@@ -0,0 +1,38 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	// Packages
+	metrics "github.com/mutablelogic/go-whisper/pkg/metrics"
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_metrics_001(t *testing.T) {
+	assert := assert.New(t)
+	m := metrics.New()
+
+	m.Observe("tiny", "transcribe", 10*time.Second, &schema.Timings{
+		FirstToken: schema.Timestamp(time.Second),
+		Total:      schema.Timestamp(2 * time.Second),
+	})
+	m.Observe("tiny", "transcribe", 5*time.Second, nil)
+	m.Error("tiny", "translate")
+	m.Error(`odd"model`, "transcribe")
+
+	var b strings.Builder
+	assert.NoError(m.Write(&b, metrics.Gauge{Name: "whisper_pool_contexts_in_use", Help: "Contexts in use", Value: 1}))
+	out := b.String()
+	t.Log(out)
+
+	assert.Contains(out, "# TYPE whisper_pool_contexts_in_use gauge\nwhisper_pool_contexts_in_use 1\n")
+	assert.Contains(out, `whisper_requests_total{model="tiny",task="transcribe",status="ok"} 2`)
+	assert.Contains(out, `whisper_requests_total{model="tiny",task="translate",status="error"} 1`)
+	assert.Contains(out, `whisper_requests_total{model="odd\"model",task="transcribe",status="error"} 1`)
+	assert.Contains(out, `whisper_audio_seconds_total{model="tiny"} 15`)
+	assert.Contains(out, `whisper_processing_seconds_total{model="tiny",stage="first_token"} 1`)
+	assert.Contains(out, `whisper_processing_seconds_total{model="tiny",stage="total"} 2`)
+}
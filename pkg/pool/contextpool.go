@@ -2,11 +2,17 @@ package pool
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
 	// Packages
 	schema "github.com/mutablelogic/go-whisper/pkg/schema"
 	task "github.com/mutablelogic/go-whisper/pkg/task"
+	whisper "github.com/mutablelogic/go-whisper/sys/whisper"
 
 	// Namespace imports
 	. "github.com/djthorpe/go-errors"
@@ -15,7 +21,10 @@ import (
 //////////////////////////////////////////////////////////////////////////////
 // TYPES
 
-// ContextPool is a pool of context objects
+// ContextPool is a pool of context objects. Each loaded model is shared
+// between the contexts which use it, and each context has its own decoding
+// state, so concurrent transcriptions with the same model load the model
+// weights only once
 type ContextPool struct {
 	// Pool of context objects
 	*Pool
@@ -25,6 +34,36 @@ type ContextPool struct {
 
 	// GPU flags
 	gpu int
+
+	// Logger for loading and unloading models, or nil
+	logger *slog.Logger
+
+	// Loaded models, and the model used by each context
+	lock   sync.Mutex
+	models map[string]*loaded
+	tasks  map[*task.Context]*lease
+}
+
+// lease is the model and the decoding state used by a context
+type lease struct {
+	model *loaded
+	state *whisper.State
+}
+
+// loaded is a model in memory, which is reference counted by the contexts
+// using it. Decoding states are kept with the model when they are no longer
+// in use, so they can be used by the next context for the model
+type loaded struct {
+	id     string
+	ctx    *whisper.Context
+	states []*whisper.State
+	refs   int
+	used   time.Time
+	drain  bool
+
+	// Closed when the model has been loaded, or failed to load
+	ready chan struct{}
+	err   error
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -33,36 +72,76 @@ type ContextPool struct {
 // Create a new context pool of context objects, up to 'max' items
 // Set the path for the model storage
 // If GPU is -1 then disable, if 0 then use default, if >0 then enable
-// and use the specified device
+// and use the specified device. Up to 'max' models are kept loaded, and
+// the least recently used model is unloaded when another model is needed
 func NewContextPool(path string, max int, gpu int) *ContextPool {
 	pool := new(ContextPool)
 	pool.Pool = NewPool(max, func() any {
 		return task.New()
 	})
+	if pool.Pool == nil {
+		return nil
+	}
 	pool.path = path
 	pool.gpu = gpu
+	pool.models = make(map[string]*loaded, max)
+	pool.tasks = make(map[*task.Context]*lease, max)
 
 	// Return success
 	return pool
 }
 
-// Close the pool and release all resources
+// Close the pool and release all resources. Models which are in use
+// are released when their contexts are put back in the pool
 func (m *ContextPool) Close() error {
-	return m.Pool.Close()
+	result := m.Pool.Close()
+
+	// Release the models
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for id, model := range m.models {
+		delete(m.models, id)
+		if model.refs == 0 {
+			model.free()
+		} else {
+			model.drain = true
+		}
+	}
+
+	// Return any errors
+	return result
 }
 
 //////////////////////////////////////////////////////////////////////////////
 // STRINGIFY
 
 func (m *ContextPool) MarshalJSON() ([]byte, error) {
+	type j struct {
+		Id   string    `json:"id"`
+		Refs int       `json:"refs"`
+		Used time.Time `json:"last_used"`
+	}
+
+	m.lock.Lock()
+	models := make([]j, 0, len(m.models))
+	for _, model := range m.models {
+		models = append(models, j{Id: model.id, Refs: model.refs, Used: model.used})
+	}
+	m.lock.Unlock()
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Id < models[j].Id
+	})
+
 	return json.Marshal(struct {
-		Gpu int `json:"gpu"`
-		N   int `json:"n"`
-		Max int `json:"max"`
+		Gpu    int `json:"gpu"`
+		N      int `json:"n"`
+		Max    int `json:"max"`
+		Models []j `json:"models,omitempty"`
 	}{
-		Gpu: m.gpu,
-		N:   m.N(),
-		Max: m.max,
+		Gpu:    m.gpu,
+		N:      m.N(),
+		Max:    m.max,
+		Models: models,
 	})
 }
 
@@ -77,7 +156,8 @@ func (m *ContextPool) String() string {
 //////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
-// Get a context from the pool, for a model
+// Get a context from the pool, for a model. The model is loaded if it is
+// not already loaded, and otherwise shared with other contexts
 func (m *ContextPool) Get(model *schema.Model) (*task.Context, error) {
 	// Check parameters
 	if model == nil {
@@ -85,34 +165,287 @@ func (m *ContextPool) Get(model *schema.Model) (*task.Context, error) {
 	}
 
 	// Get a context from the pool
+	start := time.Now()
 	t, ok := m.Pool.Get().(*task.Context)
 	if !ok || t == nil {
 		return nil, ErrChannelBlocked.With("unable to get a context from the pool, try again later")
 	}
+	wait := time.Since(start)
 
-	// If the model matches, return it, or else release the resources
-	if t.Is(model) {
-		return t, nil
-	} else if err := t.Close(); err != nil {
+	// Get the model, loading it if necessary
+	shared, ready, load, err := m.acquire(model)
+	if err != nil {
+		m.Pool.Put(t)
 		return nil, err
 	}
 
-	// Initialise the context
-	if err := t.Init(m.path, model, m.gpu); err != nil {
+	// Initialise the context with a state for the model
+	state, err := m.state(shared)
+	if err != nil {
+		m.release(shared, nil)
+		m.Pool.Put(t)
+		return nil, err
+	}
+	if err := t.Init(model, shared.ctx, state); err != nil {
+		m.release(shared, state)
+		m.Pool.Put(t)
 		return nil, err
 	}
 
 	// Return the context
+	t.SetPoolTimings(wait+ready, load)
+	m.lock.Lock()
+	m.tasks[t] = &lease{model: shared, state: state}
+	m.lock.Unlock()
 	return t, nil
 }
 
-// Put a context back into the pool
+// Put a context back into the pool, returning its state to the model and
+// releasing its reference to the model
 func (m *ContextPool) Put(ctx *task.Context) {
+	if ctx == nil {
+		return
+	}
+
+	m.lock.Lock()
+	lease := m.tasks[ctx]
+	delete(m.tasks, ctx)
+	m.lock.Unlock()
+
+	// Release the state and the model
+	ctx.Close()
+	if lease != nil {
+		m.release(lease.model, lease.state)
+	}
 	m.Pool.Put(ctx)
 }
 
-// Drain the pool of all contexts for a model, freeing resources
+// Set the logger for loading and unloading models
+func (m *ContextPool) SetLogger(logger *slog.Logger) {
+	m.logger = logger
+}
+
+// Return the number of models loaded
+func (m *ContextPool) Loaded() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.models)
+}
+
+// Drain the pool of a model. The model is unloaded immediately if it is
+// not in use, or else when the last context using it is put back in the pool
 func (m *ContextPool) Drain(model *schema.Model) error {
-	fmt.Println("TODO: DRAIN", model.Id)
+	if model == nil {
+		return ErrBadParameter
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	shared, exists := m.models[model.Id]
+	if !exists {
+		return nil
+	}
+	delete(m.models, model.Id)
+	if shared.refs == 0 {
+		m.debug("unload model", "model", shared.id, "reason", "drain")
+		shared.free()
+	} else {
+		m.debug("drain model", "model", shared.id, "refs", shared.refs)
+		shared.drain = true
+	}
+
+	// Return success
 	return nil
 }
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return a loaded model and increment the reference count. If the model is
+// being loaded by another caller, wait for it to be loaded, and return the
+// time waited. Otherwise, return the time taken to load the model
+func (m *ContextPool) acquire(model *schema.Model) (*loaded, time.Duration, time.Duration, error) {
+	m.lock.Lock()
+	if shared, exists := m.models[model.Id]; exists {
+		shared.refs++
+		shared.used = time.Now()
+		m.lock.Unlock()
+
+		start := time.Now()
+		<-shared.ready
+		if shared.err != nil {
+			m.release(shared, nil)
+			return nil, 0, 0, shared.err
+		}
+		return shared, time.Since(start), 0, nil
+	}
+
+	// Make room for the model, and mark it as loading. If every loaded
+	// model is in use, then no model can be unloaded
+	m.evict(m.max - 1)
+	if len(m.models) >= m.max {
+		m.lock.Unlock()
+		return nil, 0, 0, ErrChannelBlocked.Withf("unable to load %q as all loaded models are in use, try again later", model.Id)
+	}
+	shared := newLoaded(model.Id)
+	m.models[model.Id] = shared
+	m.lock.Unlock()
+
+	// Load the model outside of the lock, as this can take some time
+	start := time.Now()
+	shared.ctx, shared.err = m.load(model)
+	if shared.err != nil {
+		m.error("load model", "model", model.Id, "error", shared.err)
+		m.lock.Lock()
+		if m.models[model.Id] == shared {
+			delete(m.models, model.Id)
+		}
+		m.lock.Unlock()
+	}
+	close(shared.ready)
+
+	// Return any error
+	if shared.err != nil {
+		m.release(shared, nil)
+		return nil, 0, 0, shared.err
+	}
+	m.debug("load model", "model", model.Id, "duration_ms", time.Since(start).Milliseconds())
+	return shared, 0, time.Since(start), nil
+}
+
+// Return a decoding state for a model, re-using a state which is no longer
+// in use, or else allocating a new state
+func (m *ContextPool) state(shared *loaded) (*whisper.State, error) {
+	m.lock.Lock()
+	if n := len(shared.states); n > 0 {
+		state := shared.states[n-1]
+		shared.states = shared.states[:n-1]
+		m.lock.Unlock()
+		return state, nil
+	}
+	m.lock.Unlock()
+
+	// Allocate a new state outside of the lock
+	if state := whisper.Whisper_init_state(shared.ctx); state == nil {
+		return nil, ErrInternalAppError.With("whisper_init_state")
+	} else {
+		return state, nil
+	}
+}
+
+// Return a state to a model, decrement the reference count of the model, and
+// unload the model if it has been drained and is no longer in use
+func (m *ContextPool) release(shared *loaded, state *whisper.State) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if state != nil {
+		shared.states = append(shared.states, state)
+	}
+	shared.refs--
+	shared.used = time.Now()
+	if shared.refs == 0 && shared.drain {
+		m.debug("unload model", "model", shared.id, "reason", "drain")
+		shared.free()
+	}
+}
+
+// Unload the least recently used models which are not in use, until there
+// are at most n models loaded. Should be called with the lock held
+func (m *ContextPool) evict(n int) {
+	for len(m.models) > n {
+		var lru *loaded
+		for _, shared := range m.models {
+			if shared.refs > 0 {
+				continue
+			}
+			if lru == nil || shared.used.Before(lru.used) {
+				lru = shared
+			}
+		}
+		if lru == nil {
+			return
+		}
+		delete(m.models, lru.id)
+		m.debug("unload model", "model", lru.id, "reason", "evict")
+		lru.free()
+	}
+}
+
+// Load a model from its source, or from the models directory
+func (m *ContextPool) load(model *schema.Model) (*whisper.Context, error) {
+	// Get default parameters
+	params := whisper.DefaultContextParams()
+
+	// If gpu is -1, then disable
+	// If gpu is 0, then use whatever the default is
+	// If gpu is >0, then enable and set the device
+	if m.gpu == -1 {
+		params.SetUseGpu(false)
+	} else if m.gpu > 0 {
+		params.SetUseGpu(true)
+		params.SetGpuDevice(m.gpu)
+	}
+
+	// Load the model from the source, or from the models directory. A source
+	// which can copy the model to a file is loaded from a temporary file
+	var ctx *whisper.Context
+	if source, ok := model.Source.(schema.FileSource); ok {
+		if path, err := source.CreateTemp(m.path); err != nil {
+			return nil, err
+		} else {
+			defer os.Remove(path)
+			ctx = whisper.Whisper_init_from_file_with_params(path, params)
+		}
+	} else if model.Source != nil {
+		if data, err := model.Source.Bytes(); err != nil {
+			return nil, err
+		} else if len(data) == 0 {
+			return nil, ErrBadParameter.Withf("empty model data: %q", model.Id)
+		} else {
+			ctx = whisper.Whisper_init_from_buffer_with_params(data, params)
+		}
+	} else {
+		ctx = whisper.Whisper_init_from_file_with_params(filepath.Join(m.path, model.Path), params)
+	}
+	if ctx == nil {
+		return nil, ErrInternalAppError.Withf("whisper_init: %q", model.Id)
+	}
+
+	// Return success
+	return ctx, nil
+}
+
+func (m *ContextPool) debug(msg string, args ...any) {
+	if m.logger != nil {
+		m.logger.Debug(msg, args...)
+	}
+}
+
+func (m *ContextPool) error(msg string, args ...any) {
+	if m.logger != nil {
+		m.logger.Error(msg, args...)
+	}
+}
+
+func newLoaded(id string) *loaded {
+	return &loaded{
+		id:    id,
+		refs:  1,
+		used:  time.Now(),
+		ready: make(chan struct{}),
+	}
+}
+
+// Unload the model and its states
+func (shared *loaded) free() {
+	for _, state := range shared.states {
+		whisper.Whisper_free_state(state)
+	}
+	shared.states = nil
+	if shared.ctx != nil {
+		whisper.Whisper_free(shared.ctx)
+	}
+	shared.ctx = nil
+}
@@ -52,3 +52,27 @@ func Test_contextpool_001(t *testing.T) {
 	t.Log("Closing the pool")
 	pool.Close()
 }
+
+func Test_contextpool_002(t *testing.T) {
+	var pool = pool.NewContextPool(t.TempDir(), 2, 0)
+	defer pool.Close()
+
+	// Getting a model which does not exist returns an error, and releases
+	// the context back into the pool
+	for i := 0; i < 4; i++ {
+		if _, err := pool.Get(&schema.Model{Id: "missing", Path: "missing.bin"}); err == nil {
+			t.Error("Expected error")
+		}
+	}
+	if n := pool.N(); n != 0 {
+		t.Error("Expected no contexts in use, got", n)
+	}
+
+	// Draining a model which is not loaded is not an error
+	if err := pool.Drain(&schema.Model{Id: "missing"}); err != nil {
+		t.Error(err)
+	}
+	if err := pool.Drain(nil); err == nil {
+		t.Error("Expected error")
+	}
+}
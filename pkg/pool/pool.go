@@ -52,17 +52,16 @@ func NewPool(max int, fn NewFunc) *Pool {
 func (m *Pool) Close() error {
 	var result error
 
-	// We repeatedly call Get until we get nil
-	m.setEmpty(true)
-	for {
-		ctx := m.Get()
-		if ctx == nil {
-			break
-		}
-		// If it is an io.Closer, then close it
-		if ctx, ok := ctx.(io.Closer); ok {
-			result = errors.Join(result, ctx.Close())
-		}
+	// Take the items which are not in use, and put the pool in drain mode
+	m.Lock()
+	m.empty = true
+	items := m.pool
+	m.pool = nil
+	m.Unlock()
+
+	// If an item is an io.Closer, then close it
+	for _, item := range items {
+		result = errors.Join(result, closeItem(item))
 	}
 
 	// Return any error
@@ -77,27 +76,36 @@ func (m *Pool) Get() any {
 	m.Lock()
 	defer m.Unlock()
 
-	// Create a new item
+	// Re-use an item, or create a new item
 	var item any
 	if len(m.pool) > 0 {
 		item, m.pool = m.pool[0], m.pool[1:]
 	} else {
 		item = m.fn()
-		if item != nil {
-			m.n++
-		}
+	}
+	if item != nil {
+		m.n++
 	}
 	return item
 }
 
-// Puts the context back in the pool
+// Puts the context back in the pool. If the pool has been closed, then
+// the context is closed instead
 func (m *Pool) Put(ctx any) {
-	m.Lock()
-	defer m.Unlock()
+	if ctx == nil {
+		return
+	}
 
-	if ctx != nil {
+	m.Lock()
+	m.n--
+	empty := m.empty
+	if !empty {
 		m.pool = append(m.pool, ctx)
-		m.n--
+	}
+	m.Unlock()
+
+	if empty {
+		closeItem(ctx)
 	}
 }
 
@@ -108,6 +116,11 @@ func (m *Pool) N() int {
 	return m.n
 }
 
+// Return the maximum number of contexts
+func (m *Pool) Max() int {
+	return m.max
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
@@ -116,9 +129,10 @@ func (m *Pool) atCapacity() bool {
 	return m.n >= m.max || m.empty
 }
 
-// Set pool in drain mode, no more contexts will be added
-func (m *Pool) setEmpty(v bool) {
-	m.Lock()
-	defer m.Unlock()
-	m.empty = v
+// Close an item if it is an io.Closer
+func closeItem(item any) error {
+	if item, ok := item.(io.Closer); ok {
+		return item.Close()
+	}
+	return nil
 }
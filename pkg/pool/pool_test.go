@@ -72,3 +72,36 @@ func Test_basepool_002(t *testing.T) {
 	t.Log("Closing the pool")
 	pool.Close()
 }
+
+func Test_basepool_003(t *testing.T) {
+	var pool = pool.NewPool(2, func() any {
+		return &Item{t, false}
+	})
+
+	item1, _ := pool.Get().(*Item)
+	item2, _ := pool.Get().(*Item)
+	if n := pool.N(); n != 2 {
+		t.Error("Expected two items in use, got", n)
+	}
+	pool.Put(item1)
+
+	// Closing the pool closes the items which are not in use, and items
+	// which are put back afterwards
+	pool.Close()
+	if n := pool.N(); n != 1 {
+		t.Error("Expected one item in use, got", n)
+	}
+	if !item1.closed || item2.closed {
+		t.Error("Expected only item1 to be closed")
+	}
+	pool.Put(item2)
+	if n := pool.N(); n != 0 {
+		t.Error("Expected no items in use, got", n)
+	}
+	if !item2.closed {
+		t.Error("Expected item2 to be closed")
+	}
+	if item := pool.Get(); item != nil {
+		t.Error("Expected no item from a closed pool")
+	}
+}
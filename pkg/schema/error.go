@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"encoding/json"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Error is an error response in the form returned by the OpenAI API, so
+// that OpenAI SDKs can report the error
+type Error struct {
+	Err ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	ErrorTypeInvalidRequest = "invalid_request_error"
+	ErrorTypeRateLimit      = "rate_limit_error"
+	ErrorTypeQuota          = "insufficient_quota"
+	ErrorTypeServer         = "server_error"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return an error response with a type, code and message
+func NewError(typ, code, message string) *Error {
+	e := &Error{Err: ErrorDetail{Message: message, Type: typ}}
+	if code != "" {
+		e.Err.Code = &code
+	}
+	return e
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e *Error) String() string {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func (e *Error) Error() string {
+	return e.Err.Message
+}
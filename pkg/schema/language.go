@@ -0,0 +1,39 @@
+package schema
+
+import (
+	"encoding/json"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Language struct {
+	Code        string  `json:"code" writer:",width:6"`
+	Name        string  `json:"name,omitempty" writer:",width:20"`
+	Probability float32 `json:"probability" writer:",width:10,right"`
+}
+
+type LanguageDetection struct {
+	Language  string      `json:"language,omitempty" writer:",width:8"`
+	Duration  Timestamp   `json:"duration,omitempty" writer:",width:8,right"`
+	Languages []*Language `json:"languages,omitempty" writer:",width:40,wrap"`
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (l *Language) String() string {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func (l *LanguageDetection) String() string {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
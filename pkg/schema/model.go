@@ -13,6 +13,24 @@ type Model struct {
 	Path    string `json:"path,omitempty" writer:",width:40,wrap"`
 	Created int64  `json:"created,omitempty"`
 	OwnedBy string `json:"owned_by,omitempty"`
+	Source  Source `json:"-" writer:"-"`
+}
+
+// Source provides the data for a model which is not a file in the models
+// directory, such as a model embedded in the binary or held in blob storage
+type Source interface {
+	// Return the model data, which is read into memory when the model is loaded
+	Bytes() ([]byte, error)
+}
+
+// FileSource is a Source which can copy the model data to a file, so that
+// a large model is loaded without first reading it into memory
+type FileSource interface {
+	Source
+
+	// Copy the model data to a new file in a directory, and return the path
+	// of the file. The caller removes the file once the model is loaded
+	CreateTemp(dir string) (string, error)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -17,6 +17,7 @@ type Segment struct {
 	Start       Timestamp `json:"start"`
 	End         Timestamp `json:"end"`
 	Text        string    `json:"text"`
+	Language    string    `json:"language,omitempty"`
 	Tokens      []string  `json:"tokens,omitempty"`       // TODO
 	Speaker     string    `json:"speaker,omitempty"`      // TODO
 	SpeakerTurn bool      `json:"speaker_turn,omitempty"` // TODO
@@ -72,11 +73,10 @@ func (seg *Segment) WriteText(w io.Writer) {
 		return
 	}
 	if seg.Speaker != "" {
-		fmt.Fprintf(w, "\n\n[%s] ", seg.Speaker)
+		fmt.Fprintf(w, "\n\n[%s] %s", seg.Speaker, strings.TrimSpace(seg.Text))
 	} else if seg.SpeakerTurn {
-		fmt.Fprint(w, "\n\n[SPEAKER] ")
-	}
-	if seg.Id > 0 {
+		fmt.Fprint(w, "\n\n[SPEAKER] "+strings.TrimSpace(seg.Text))
+	} else if seg.Id > 0 {
 		fmt.Fprint(w, seg.Text)
 	} else {
 		fmt.Fprint(w, strings.TrimSpace(seg.Text))
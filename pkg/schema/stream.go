@@ -10,6 +10,15 @@ type Event struct {
 	Delta string          `json:"delta,omitempty"` // transcript.text.delta
 	Text  string          `json:"text,omitempty"`  // transcript.text.done and transcript.text.language
 	JSON  json.RawMessage `json:"json,omitempty"`  // transcript.text.delta and transcript.text.done when format = json or verbose_json
+
+	// transcript.progress, between zero and one
+	Progress *float64 `json:"progress,omitempty"`
+
+	// transcript.text.error, in the form returned by the OpenAI API
+	Error *ErrorDetail `json:"error,omitempty"`
+
+	// The request id, which is also returned in the X-Request-Id header
+	RequestId string `json:"request_id,omitempty"`
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -26,6 +35,7 @@ const (
 	TranscribeStreamDoneType     = "transcript.text.done"
 	TranscribeStreamErrorType    = "transcript.text.error"
 	TranscribeStreamLanguageType = "transcript.text.language"
+	TranscribeStreamProgressType = "transcript.progress"
 )
 
 //////////////////////////////////////////////////////////////////////////////
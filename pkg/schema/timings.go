@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"encoding/json"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Timings for a transcription or translation request, in seconds. The
+// prepare, first token and tokens timings are phases of whisper_full
+// measured in Go, between the callbacks which whisper.cpp makes when the
+// encoder begins and when each token is sampled. They are not the mel,
+// encoder and decoder timings of whisper.cpp, which are only kept for the
+// default state of a model. The real time factor is the total time divided
+// by the duration of the audio
+type Timings struct {
+	AudioDecode    Timestamp `json:"audio_decode"`     // decoding and resampling the audio
+	Wait           Timestamp `json:"wait"`             // waiting for a context, or for another request to load the model
+	Load           Timestamp `json:"load"`             // loading the model, if not already loaded
+	Detect         Timestamp `json:"detect,omitempty"` // detecting the language
+	Prepare        Timestamp `json:"prepare"`          // until the encoder begins, including the mel spectrogram
+	FirstToken     Timestamp `json:"first_token"`      // from when the encoder begins until the first token is sampled
+	Tokens         Timestamp `json:"tokens"`           // from the first token until the end, decoding and sampling tokens
+	Total          Timestamp `json:"total"`            // total time for the request
+	RealTimeFactor float64   `json:"real_time_factor"` // total time divided by audio duration
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (t *Timings) String() string {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
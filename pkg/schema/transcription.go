@@ -16,6 +16,7 @@ type Transcription struct {
 	Duration Timestamp  `json:"duration,omitempty" writer:",width:8,right"`
 	Text     string     `json:"text,omitempty" writer:",width:60,wrap"`
 	Segments []*Segment `json:"segments,omitempty" writer:",width:40,wrap"`
+	Timings  *Timings   `json:"timings,omitempty" writer:"-"`
 }
 
 //////////////////////////////////////////////////////////////////////////////
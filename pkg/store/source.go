@@ -0,0 +1,107 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type bytesSource []byte
+
+type readerAtSource struct {
+	r    io.ReaderAt
+	size int64
+}
+
+type fsSource struct {
+	fs   fs.FS
+	path string
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return a model source for model data which is already in memory
+func NewBytesSource(data []byte) schema.Source {
+	return bytesSource(data)
+}
+
+// Return a model source which reads size bytes from r, such as a file or an
+// object in blob storage. The data is copied to a file when the model is
+// loaded, rather than read into memory
+func NewReaderAtSource(r io.ReaderAt, size int64) schema.Source {
+	return &readerAtSource{r, size}
+}
+
+// Return a model source which reads a file from a filesystem, such as an
+// embed.FS compiled into the binary
+func NewFSSource(fsys fs.FS, path string) schema.Source {
+	return &fsSource{fsys, path}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (s bytesSource) Bytes() ([]byte, error) {
+	if len(s) == 0 {
+		return nil, ErrBadParameter.With("empty model data")
+	}
+	return s, nil
+}
+
+func (s *readerAtSource) Bytes() ([]byte, error) {
+	if s.r == nil || s.size <= 0 {
+		return nil, ErrBadParameter.With("empty model data")
+	}
+	data := make([]byte, s.size)
+	if n, err := s.r.ReadAt(data, 0); n < len(data) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *readerAtSource) CreateTemp(dir string) (string, error) {
+	if s.r == nil || s.size <= 0 {
+		return "", ErrBadParameter.With("empty model data")
+	}
+	f, err := os.CreateTemp(dir, ".model-*.bin")
+	if err != nil {
+		return "", err
+	}
+
+	// Copy the data, and remove the file on error
+	n, err := io.Copy(f, io.NewSectionReader(s.r, 0, s.size))
+	if err == nil && n < s.size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err := errors.Join(err, f.Close()); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	// Return success
+	return f.Name(), nil
+}
+
+func (s *fsSource) Bytes() ([]byte, error) {
+	if data, err := fs.ReadFile(s.fs, s.path); err != nil {
+		return nil, err
+	} else if len(data) == 0 {
+		return nil, ErrBadParameter.Withf("empty model data: %q", s.path)
+	} else {
+		return data, nil
+	}
+}
@@ -0,0 +1,101 @@
+package store_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	store "github.com/mutablelogic/go-whisper/pkg/store"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_source_001(t *testing.T) {
+	assert := assert.New(t)
+	data := []byte("ggml model data")
+
+	t.Run("Bytes", func(t *testing.T) {
+		v, err := store.NewBytesSource(data).Bytes()
+		assert.NoError(err)
+		assert.Equal(data, v)
+
+		_, err = store.NewBytesSource(nil).Bytes()
+		assert.Error(err)
+	})
+
+	t.Run("ReaderAt", func(t *testing.T) {
+		v, err := store.NewReaderAtSource(bytes.NewReader(data), int64(len(data))).Bytes()
+		assert.NoError(err)
+		assert.Equal(data, v)
+
+		// Truncated data
+		_, err = store.NewReaderAtSource(bytes.NewReader(data), int64(len(data)+1)).Bytes()
+		assert.Error(err)
+	})
+
+	t.Run("FS", func(t *testing.T) {
+		fsys := fstest.MapFS{"models/ggml-tiny.en.bin": &fstest.MapFile{Data: data}}
+		v, err := store.NewFSSource(fsys, "models/ggml-tiny.en.bin").Bytes()
+		assert.NoError(err)
+		assert.Equal(data, v)
+
+		_, err = store.NewFSSource(fsys, "models/missing.bin").Bytes()
+		assert.Error(err)
+	})
+}
+
+func Test_source_002(t *testing.T) {
+	assert := assert.New(t)
+	s, err := store.NewStore(t.TempDir(), ".bin", "https://huggingface.co/ggerganov/whisper.cpp/resolve/main/?download=true")
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	model, err := s.Register("tiny.en", store.NewBytesSource([]byte("ggml model data")))
+	if assert.NoError(err) {
+		assert.Equal("tiny.en", model.Id)
+		assert.NotNil(model.Source)
+	}
+	assert.Equal(model, s.ById("tiny.en"))
+	assert.Len(s.List(), 1)
+
+	// Duplicate
+	_, err = s.Register("tiny.en", store.NewBytesSource([]byte("ggml model data")))
+	assert.Error(err)
+
+	// Rescan keeps registered models, and delete removes them
+	assert.NoError(s.Rescan())
+	assert.Len(s.List(), 1)
+	assert.NoError(s.Delete("tiny.en"))
+	assert.Nil(s.ById("tiny.en"))
+}
+
+func Test_source_003(t *testing.T) {
+	assert := assert.New(t)
+	data := []byte("ggml model data")
+
+	// The data is copied to a file in the directory
+	source, ok := store.NewReaderAtSource(bytes.NewReader(data), int64(len(data))).(schema.FileSource)
+	if !assert.True(ok) {
+		t.SkipNow()
+	}
+	dir := t.TempDir()
+	path, err := source.CreateTemp(dir)
+	if assert.NoError(err) {
+		assert.Equal(dir, filepath.Dir(path))
+		v, err := os.ReadFile(path)
+		assert.NoError(err)
+		assert.Equal(data, v)
+	}
+
+	// Truncated data returns an error, and does not leave a file behind
+	source = store.NewReaderAtSource(bytes.NewReader(data), int64(len(data)+1)).(schema.FileSource)
+	_, err = source.CreateTemp(dir)
+	assert.Error(err)
+	entries, err := os.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(entries, 1)
+}
@@ -9,8 +9,10 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	// Packages
 	schema "github.com/mutablelogic/go-whisper/pkg/schema"
@@ -29,8 +31,10 @@ type Store struct {
 	// Path to the models directory and file extension
 	path, ext string
 
-	// list of all models
-	models []*schema.Model
+	// list of all models in the models directory, and models
+	// registered with a source
+	models     []*schema.Model
+	registered []*schema.Model
 
 	// download models
 	client whisper.Client
@@ -105,7 +109,37 @@ func (s *Store) String() string {
 func (s *Store) List() []*schema.Model {
 	s.RLock()
 	defer s.RUnlock()
-	return s.models
+	if len(s.registered) == 0 {
+		return s.models
+	}
+	return append(append(make([]*schema.Model, 0, len(s.models)+len(s.registered)), s.models...), s.registered...)
+}
+
+// Register a model which is read from a source rather than the models
+// directory, such as a model embedded in the binary
+func (s *Store) Register(id string, source schema.Source) (*schema.Model, error) {
+	if id = strings.TrimSpace(id); id == "" {
+		return nil, ErrBadParameter.With("missing model id")
+	} else if source == nil {
+		return nil, ErrBadParameter.Withf("missing source for model %q", id)
+	}
+
+	// Check for an existing model, and register the model, under one lock
+	s.Lock()
+	defer s.Unlock()
+	if s.byId(id) != nil {
+		return nil, ErrDuplicateEntry.Withf("%q", id)
+	}
+	model := &schema.Model{
+		Id:      id,
+		Object:  "model",
+		Created: time.Now().Unix(),
+		Source:  source,
+	}
+	s.registered = append(s.registered, model)
+
+	// Return success
+	return model, nil
 }
 
 // Rescan models directory
@@ -124,13 +158,7 @@ func (s *Store) Rescan() error {
 func (s *Store) ById(id string) *schema.Model {
 	s.RLock()
 	defer s.RUnlock()
-
-	for _, model := range s.models {
-		if model.Id == id {
-			return model
-		}
-	}
-	return nil
+	return s.byId(id)
 }
 
 // Return a model by path
@@ -156,6 +184,14 @@ func (s *Store) Delete(id string) error {
 	s.Lock()
 	defer s.Unlock()
 
+	// Remove a registered model
+	if model.Source != nil {
+		s.registered = slices.DeleteFunc(s.registered, func(m *schema.Model) bool {
+			return m == model
+		})
+		return nil
+	}
+
 	// Delete the model
 	path := filepath.Join(s.path, model.Path)
 	if err := os.Remove(path); err != nil {
@@ -250,6 +286,21 @@ func (s *Store) Download(ctx context.Context, path string, fn func(curBytes, tot
 //////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Return a model by its Id. Should be called with the lock held
+func (s *Store) byId(id string) *schema.Model {
+	for _, model := range s.models {
+		if model.Id == id {
+			return model
+		}
+	}
+	for _, model := range s.registered {
+		if model.Id == id {
+			return model
+		}
+	}
+	return nil
+}
+
 // Convert 404 errors to ErrNotFound
 func toError(err error) error {
 	if err == nil {
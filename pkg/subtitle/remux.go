@@ -0,0 +1,285 @@
+package subtitle
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	// Packages
+	ff "github.com/mutablelogic/go-media/sys/ffmpeg71"
+	ffsys "github.com/mutablelogic/go-whisper/sys/ffmpeg"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type opts struct {
+	codec    string
+	language string
+}
+
+type Opt func(*opts) error
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Subtitle codec for MP4 and QuickTime containers, which prefixes the
+	// text of each packet with its length
+	CodecMovText = "mov_text"
+
+	// Time base of the subtitle encoder, in milliseconds
+	timeBase = 1000
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Remux copies the streams of a media file to an output file without
+// re-encoding them, and adds a subtitle stream with the subtitles from an SRT
+// or WebVTT file. The output format is guessed from the output file
+// extension, and the output file is removed on error
+func Remux(ctx context.Context, output, path, subtitles string, opt ...Opt) (err error) {
+	o := opts{codec: "srt"}
+	for _, fn := range opt {
+		if err := fn(&o); err != nil {
+			return err
+		}
+	}
+
+	// Open the media and the subtitles
+	input, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer ff.AVFormat_close_input(input)
+	text, err := openInput(subtitles)
+	if err != nil {
+		return err
+	}
+	defer ff.AVFormat_close_input(text)
+	if text.NumStreams() != 1 || text.Stream(0).CodecPar().CodecType() != ff.AVMEDIA_TYPE_SUBTITLE {
+		return ErrBadParameter.Withf("%s: not a subtitle file", subtitles)
+	}
+
+	// Create the output file, which is removed on error
+	out, err := ff.AVFormat_create_file(output, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, ff.AVFormat_close_writer(out))
+		if err != nil {
+			os.Remove(output)
+		}
+	}()
+
+	// Copy the audio and video streams of the media. Subtitle streams are
+	// copied when they have the codec of the new subtitle stream, which the
+	// container can write
+	codec := ff.AVCodec_find_encoder_by_name(o.codec)
+	if codec == nil {
+		return ErrBadParameter.Withf("subtitle codec %q not found", o.codec)
+	}
+	streams := make([]int, input.NumStreams())
+	index := 0
+	for i := range streams {
+		par := input.Stream(i).CodecPar()
+		switch {
+		case par.CodecType() == ff.AVMEDIA_TYPE_AUDIO, par.CodecType() == ff.AVMEDIA_TYPE_VIDEO, par.CodecID() == codec.ID():
+			stream := ff.AVFormat_new_stream(out, nil)
+			if stream == nil {
+				return errors.New("failed to create stream")
+			} else if err := ff.AVCodec_parameters_copy(stream.CodecPar(), par); err != nil {
+				return err
+			}
+			stream.CodecPar().SetCodecTag(0)
+			if language := ffsys.AVStream_language(input.Stream(i)); language != "" {
+				if err := ffsys.AVStream_set_language(stream, language); err != nil {
+					return err
+				}
+			}
+			streams[i] = index
+			index++
+		default:
+			streams[i] = -1
+		}
+	}
+
+	// Add the subtitle stream
+	stream, err := newStream(out, codec)
+	if err != nil {
+		return err
+	} else if o.language != "" {
+		if err := ffsys.AVStream_set_language(stream, o.language); err != nil {
+			return err
+		}
+	}
+
+	// Write the header, which sets the time base of the output streams
+	if err := ff.AVFormat_write_header(out, nil); err != nil {
+		return err
+	}
+
+	// Allocate packets for the media and the subtitles
+	pkt := ff.AVCodec_packet_alloc()
+	if pkt == nil {
+		return errors.New("failed to allocate packet")
+	}
+	defer ff.AVCodec_packet_free(pkt)
+	sub := ff.AVCodec_packet_alloc()
+	if sub == nil {
+		return errors.New("failed to allocate packet")
+	}
+	defer ff.AVCodec_packet_free(sub)
+
+	// Write the media packets, and the subtitles which start before each
+	// packet, so that the packets are interleaved
+	pending, err := readPacket(text, sub)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := ff.AVFormat_read_frame(input, pkt); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		in := input.Stream(pkt.StreamIndex())
+		index := streams[pkt.StreamIndex()]
+		if index < 0 {
+			ff.AVCodec_packet_unref(pkt)
+			continue
+		}
+		for pending && pkt.Dts() != ff.AV_NOPTS_VALUE && ff.AVUtil_compare_ts(sub.Pts(), text.Stream(0).TimeBase(), pkt.Dts(), in.TimeBase()) <= 0 {
+			if err := o.writeSubtitle(out, stream, text.Stream(0), sub); err != nil {
+				return err
+			} else if pending, err = readPacket(text, sub); err != nil {
+				return err
+			}
+		}
+		if err := writePacket(out, out.Stream(index), in, pkt); err != nil {
+			return err
+		}
+	}
+
+	// Write the subtitles after the end of the media
+	for pending {
+		if err := o.writeSubtitle(out, stream, text.Stream(0), sub); err != nil {
+			return err
+		} else if pending, err = readPacket(text, sub); err != nil {
+			return err
+		}
+	}
+
+	// Write the trailer
+	return ff.AVFormat_write_trailer(out)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Set the codec of the subtitle stream, which is "srt" by default. Use
+// "mov_text" for MP4 and QuickTime containers and "webvtt" for WebM
+func OptCodec(name string) Opt {
+	return func(o *opts) error {
+		if ff.AVCodec_find_encoder_by_name(name) == nil {
+			return ErrBadParameter.Withf("subtitle codec %q not found", name)
+		}
+		o.codec = name
+		return nil
+	}
+}
+
+// Set the language of the subtitle stream, which is usually an ISO 639-2
+// code such as "eng"
+func OptLanguage(language string) Opt {
+	return func(o *opts) error {
+		o.language = language
+		return nil
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Open an input file and find the streams
+func openInput(path string) (*ff.AVFormatContext, error) {
+	input, err := ff.AVFormat_open_url(path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := ff.AVFormat_find_stream_info(input, nil); err != nil {
+		ff.AVFormat_close_input(input)
+		return nil, err
+	}
+	return input, nil
+}
+
+// Add a subtitle stream with the codec parameters of a subtitle encoder,
+// which include the sample description for mov_text
+func newStream(out *ff.AVFormatContext, codec *ff.AVCodec) (*ff.AVStream, error) {
+	encoder := ff.AVCodec_alloc_context(codec)
+	if encoder == nil {
+		return nil, errors.New("failed to allocate codec context")
+	}
+	defer ff.AVCodec_free_context(encoder)
+	encoder.SetTimeBase(ff.AVUtil_rational(1, timeBase))
+	if err := ff.AVCodec_open(encoder, codec, nil); err != nil {
+		return nil, err
+	}
+
+	// Create the stream
+	stream := ff.AVFormat_new_stream(out, nil)
+	if stream == nil {
+		return nil, errors.New("failed to create stream")
+	} else if err := ff.AVCodec_parameters_from_context(stream.CodecPar(), encoder); err != nil {
+		return nil, err
+	}
+	stream.SetTimeBase(encoder.TimeBase())
+	return stream, nil
+}
+
+// Read the next packet, and return false at the end of the input
+func readPacket(input *ff.AVFormatContext, pkt *ff.AVPacket) (bool, error) {
+	if err := ff.AVFormat_read_frame(input, pkt); errors.Is(err, io.EOF) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Write a packet to an output stream
+func writePacket(out *ff.AVFormatContext, stream, in *ff.AVStream, pkt *ff.AVPacket) error {
+	ff.AVCodec_packet_rescale_ts(pkt, in.TimeBase(), stream.TimeBase())
+	pkt.SetStreamIndex(stream.Index())
+	pkt.SetPos(-1)
+	return ff.AVFormat_interleaved_write_frame(out, pkt)
+}
+
+// Write a subtitle packet, with the text converted for the codec
+func (o opts) writeSubtitle(out *ff.AVFormatContext, stream, in *ff.AVStream, pkt *ff.AVPacket) error {
+	if o.codec == CodecMovText {
+		if err := ffsys.AVCodec_packet_set_data(pkt, movText(pkt.Bytes())); err != nil {
+			return err
+		}
+	}
+	return writePacket(out, stream, in, pkt)
+}
+
+// Return the text of a subtitle as a mov_text sample, which is the text
+// prefixed with its length as a big-endian 16-bit integer
+func movText(text []byte) []byte {
+	text = text[:min(len(text), 0xFFFF)]
+	sample := binary.BigEndian.AppendUint16(make([]byte, 0, len(text)+2), uint16(len(text)))
+	return append(sample, text...)
+}
@@ -0,0 +1,122 @@
+package subtitle_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	// Packages
+	ff "github.com/mutablelogic/go-media/sys/ffmpeg71"
+	subtitle "github.com/mutablelogic/go-whisper/pkg/subtitle"
+	ffsys "github.com/mutablelogic/go-whisper/sys/ffmpeg"
+	assert "github.com/stretchr/testify/assert"
+)
+
+const (
+	SAMPLE_EN  = "../../samples/jfk.wav"
+	SAMPLE_SRT = `1
+00:00:00,000 --> 00:00:03,000
+And so my fellow Americans
+
+2
+00:00:03,000 --> 00:00:08,000
+ask not what your country can do for you
+`
+)
+
+// Return the subtitle packets of the last stream of a file, and the stream
+func readSubtitles(t *testing.T, path string) (*ff.AVStream, [][]byte) {
+	t.Helper()
+	input, err := ff.AVFormat_open_url(path, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ff.AVFormat_close_input(input)
+	if err := ff.AVFormat_find_stream_info(input, nil); err != nil {
+		t.Fatal(err)
+	}
+	pkt := ff.AVCodec_packet_alloc()
+	defer ff.AVCodec_packet_free(pkt)
+
+	// Read the packets
+	index := int(input.NumStreams()) - 1
+	var result [][]byte
+	for {
+		if err := ff.AVFormat_read_frame(input, pkt); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if pkt.StreamIndex() == index {
+			result = append(result, pkt.Bytes())
+		}
+		ff.AVCodec_packet_unref(pkt)
+	}
+	return input.Stream(index), result
+}
+
+func Test_remux_001(t *testing.T) {
+	dir := t.TempDir()
+	srt := filepath.Join(dir, "jfk.srt")
+	if err := os.WriteFile(srt, []byte(SAMPLE_SRT), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		output string
+		codec  string
+		id     string
+		prefix bool
+	}{
+		{"jfk.mkv", "srt", "subrip", false},
+		{"jfk.mov", subtitle.CodecMovText, "mov_text", true},
+		{"jfk.webm.mkv", "webvtt", "webvtt", false},
+	}
+	for _, test := range tests {
+		t.Run(test.output, func(t *testing.T) {
+			assert := assert.New(t)
+			output := filepath.Join(dir, test.output)
+			if !assert.NoError(subtitle.Remux(context.Background(), output, SAMPLE_EN, srt, subtitle.OptCodec(test.codec), subtitle.OptLanguage("eng"))) {
+				t.FailNow()
+			}
+
+			// The audio is copied, and a subtitle stream is added
+			stream, packets := readSubtitles(t, output)
+			assert.Equal(1, stream.Index())
+			assert.Equal(ff.AVMEDIA_TYPE_SUBTITLE, stream.CodecPar().CodecType())
+			assert.Equal(test.id, stream.CodecPar().CodecID().Name())
+			assert.Equal("eng", ffsys.AVStream_language(stream))
+			if assert.Len(packets, 2) {
+				text := packets[0]
+				if test.prefix {
+					assert.Equal(len(text)-2, int(binary.BigEndian.Uint16(text)))
+					text = text[2:]
+				}
+				assert.Equal("And so my fellow Americans", strings.TrimSpace(string(text)))
+			}
+		})
+	}
+}
+
+func Test_remux_002(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	output := filepath.Join(dir, "jfk.mkv")
+
+	// Unknown codecs are an error
+	assert.Error(subtitle.Remux(context.Background(), output, SAMPLE_EN, SAMPLE_EN, subtitle.OptCodec("missing")))
+
+	// Subtitles which are not a subtitle file are an error, and the output
+	// is not written
+	assert.Error(subtitle.Remux(context.Background(), output, SAMPLE_EN, SAMPLE_EN))
+	_, err := os.Stat(output)
+	assert.True(os.IsNotExist(err))
+
+	// Missing media is an error
+	assert.Error(subtitle.Remux(context.Background(), output, filepath.Join(dir, "missing.mp4"), SAMPLE_EN))
+}
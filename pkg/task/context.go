@@ -4,12 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	// Packages
+	logging "github.com/mutablelogic/go-whisper/pkg/logging"
 	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+	tracing "github.com/mutablelogic/go-whisper/pkg/tracing"
+	vocabulary "github.com/mutablelogic/go-whisper/pkg/vocabulary"
 	whisper "github.com/mutablelogic/go-whisper/sys/whisper"
 
 	// Namespace imports
@@ -23,20 +28,47 @@ import (
 type Context struct {
 	sync.Mutex
 
-	// Model Id and whisper context
+	// Model Id, the shared whisper context and the decoding state
 	model   string
 	whisper *whisper.Context
+	state   *whisper.State
 
 	// Parameters for the next transcription
 	params whisper.FullParams
 
-	// Collect the transcription
-	result *schema.Transcription
+	// Languages allowed when the language is auto-detected
+	allowed []int
+
+	// Detect the language for each call to Transcribe
+	perSegment bool
+
+	// Vocabulary for logit biasing and correction of the text
+	vocabulary *vocabulary.Vocabulary
+	hotwords   []vocabulary.Hotword
+
+	// Transcribe the audio within a range, where an end of zero is the end
+	// of the audio
+	start, end time.Duration
+
+	// Report progress across all calls to Transcribe
+	progress ProgressFunc
+	total    time.Duration
+
+	// Collect the transcription and timings
+	result  *schema.Transcription
+	timings schema.Timings
+
+	// Tracer for calls to whisper.cpp, or nil
+	tracer *tracing.Tracer
 }
 
 // Callback for new segments during the transcription process
 type NewSegmentFunc func(*schema.Segment)
 
+// Callback for overall progress during the transcription process, between
+// zero and one
+type ProgressFunc func(progress float64)
+
 //////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
@@ -45,45 +77,31 @@ func New() *Context {
 	return new(Context)
 }
 
-// Init the context
-func (m *Context) Init(path string, model *schema.Model, gpu int) error {
+// Init the context with a loaded model and a decoding state for the model.
+// The model can be shared with other contexts, and each context has its own
+// state, so that transcriptions using the same model can run concurrently.
+// The model and the state are owned by the caller
+func (m *Context) Init(model *schema.Model, ctx *whisper.Context, state *whisper.State) error {
 	m.Lock()
 	defer m.Unlock()
 
 	// Check parameters
-	if model == nil {
+	if model == nil || ctx == nil || state == nil {
 		return ErrBadParameter
 	}
 
-	// Get default parameters
-	params := whisper.DefaultContextParams()
-
-	// If gpu is -1, then disable
-	// If gpu is 0, then use whatever the default is
-	// If gpu is >0, then enable and set the device
-	if gpu == -1 {
-		params.SetUseGpu(false)
-	} else if gpu > 0 {
-		params.SetUseGpu(true)
-		params.SetGpuDevice(gpu)
-	}
-
-	// Get a context
-	ctx := whisper.Whisper_init_from_file_with_params(filepath.Join(path, model.Path), params)
-	if ctx == nil {
-		return ErrInternalAppError.With("whisper_init")
-	}
-
 	// Set resources
 	m.whisper = ctx
+	m.state = state
 	m.model = model.Id
 
 	// Return success
 	return nil
 }
 
-// Close the context and release all resources. The context
-// itself can be re-used by calling Init again
+// Close the context, and release its references to the model and the
+// state, which are owned by the caller. The context itself can be re-used
+// by calling Init again
 func (ctx *Context) Close() error {
 	// Do nothing if nil
 	if ctx == nil {
@@ -91,9 +109,7 @@ func (ctx *Context) Close() error {
 	}
 
 	// Release resources
-	if ctx.whisper != nil {
-		whisper.Whisper_free(ctx.whisper)
-	}
+	ctx.state = nil
 	ctx.whisper = nil
 	ctx.model = ""
 
@@ -109,11 +125,13 @@ func (ctx *Context) MarshalJSON() ([]byte, error) {
 		Model   string             `json:"model"`
 		Params  whisper.FullParams `json:"params"`
 		Context string             `json:"context"`
+		State   string             `json:"state"`
 	}
 	return json.Marshal(j{
 		Model:   ctx.model,
 		Params:  ctx.params,
 		Context: fmt.Sprintf("%p", ctx.whisper),
+		State:   fmt.Sprintf("%p", ctx.state),
 	})
 }
 
@@ -139,11 +157,24 @@ func (ctx *Context) Is(model *schema.Model) bool {
 	return ctx.model == model.Id
 }
 
+// Return the model Id, or empty if the context is not initialised
+func (ctx *Context) Model() string {
+	return ctx.model
+}
+
 // Reset task context for re-use
 func (task *Context) CopyParams() {
 	task.params = whisper.DefaultFullParams(whisper.SAMPLING_BEAM_SEARCH)
 	task.params.SetLanguage("auto")
+	task.allowed = nil
+	task.perSegment = false
+	task.vocabulary = nil
+	task.hotwords = nil
+	task.start, task.end = 0, 0
+	task.progress = nil
+	task.total = 0
 	task.result = new(schema.Transcription)
+	task.timings = schema.Timings{Wait: task.timings.Wait, Load: task.timings.Load}
 }
 
 // Model is multilingual and can translate
@@ -153,10 +184,34 @@ func (task *Context) CanTranslate() bool {
 
 // Transcribe samples. The samples should be 16KHz float32 samples in
 // a single channel. Appends the transcription to the result, and includes
-// segment data if the new segment function is not nil
+// segment data if the new segment function is not nil. Samples outside the
+// range set with SetRange are not transcribed
 func (task *Context) Transcribe(ctx context.Context, ts time.Duration, samples []float32, fn NewSegmentFunc) error {
+	// Remove the callbacks when done
+	defer func() {
+		task.params.SetAbortCallback(task.state, nil)
+		task.params.SetSegmentCallback(task.state, nil)
+		task.params.SetProgressCallback(task.state, nil)
+		task.params.SetEncoderBeginCallback(task.state, nil)
+		task.params.SetLogitsFilterCallback(task.state, nil)
+	}()
+
+	// Transcribe the samples within the range, which whisper skips to with
+	// the offset and duration parameters
+	duration := time.Duration(len(samples)) * time.Second / time.Duration(whisper.SampleRate)
+	offset, length := task.clip(ts, duration)
+	if length <= 0 {
+		return nil
+	}
+	task.params.SetOffsetMS(int(offset.Milliseconds()))
+	if offset+length < duration {
+		task.params.SetDurationMS(int(length.Milliseconds()))
+	} else {
+		task.params.SetDurationMS(0)
+	}
+
 	// Set the 'abort' function
-	task.params.SetAbortCallback(task.whisper, func() bool {
+	task.params.SetAbortCallback(task.state, func() bool {
 		select {
 		case <-ctx.Done():
 			return true
@@ -167,24 +222,100 @@ func (task *Context) Transcribe(ctx context.Context, ts time.Duration, samples [
 
 	// Set the new segment function
 	if fn != nil {
-		task.params.SetSegmentCallback(task.whisper, func(new_segments int) {
-			task.result.Language = whisper.Whisper_lang_str_full(task.whisper.DefaultLangId())
-			num_segments := task.whisper.NumSegments()
+		task.params.SetSegmentCallback(task.state, func(new_segments int) {
+			task.result.Language = whisper.Whisper_lang_str_full(task.state.LangId())
+			num_segments := task.state.NumSegments()
 			offset := len(task.result.Segments)
 			for i := num_segments - new_segments; i < num_segments; i++ {
-				fn(newSegment(ts, int32(offset), task.whisper.Segment(i)))
+				fn(task.segment(ts, int32(offset), i))
 			}
 		})
 	}
 
+	// Report progress as the proportion of the total duration processed,
+	// where ts is the start of the samples and the total duration is from
+	// the start of the range
+	if task.progress != nil && task.total > 0 {
+		task.params.SetProgressCallback(task.state, func(progress int) {
+			processed := ts + offset + length*time.Duration(progress)/100 - task.start
+			task.progress(min(1, float64(processed)/float64(task.total)))
+		})
+	}
+
+	// Detect the language for these samples, restricting the detected
+	// language to the allowed set. Unless the language is detected for every
+	// call, the first detected language is used for subsequent calls
+	if auto := task.params.Language() == "auto"; auto && (len(task.allowed) > 0 || task.perSegment) {
+		if probs, id, err := task.detect(ctx, samples[int(offset*time.Duration(whisper.SampleRate)/time.Second):]); err != nil {
+			return err
+		} else if len(task.allowed) > 0 {
+			task.params.SetLanguage(whisper.Whisper_lang_str(task.best(probs)))
+		} else {
+			task.params.SetLanguage(whisper.Whisper_lang_str(id))
+		}
+		if task.perSegment {
+			defer task.params.SetLanguage("auto")
+		}
+	}
+
+	// Measure the phases of the transcription between callbacks: until the
+	// encoder first begins, from when the encoder begins until a token is
+	// sampled, and the remainder. Bias decoding towards the vocabulary when
+	// sampling tokens
+	var prepare, encode time.Duration
+	var encoding time.Time
+	var lock sync.Mutex
+	start := time.Now()
+	task.params.SetEncoderBeginCallback(task.state, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		if encoding = time.Now(); prepare == 0 {
+			prepare = encoding.Sub(start)
+		}
+		return true
+	})
+	task.params.SetLogitsFilterCallback(task.state, func(tokens []int32, logits []float32) {
+		lock.Lock()
+		if !encoding.IsZero() {
+			encode += time.Since(encoding)
+			encoding = time.Time{}
+		}
+		lock.Unlock()
+		if len(task.hotwords) > 0 {
+			vocabulary.Bias(task.hotwords, tokens, logits, task.boundary)
+		}
+	})
+
 	// Perform the transcription
-	if err := whisper.Whisper_full(task.whisper, task.params, samples); err != nil {
+	_, span := tracing.Start(tracing.WithTracer(ctx, task.tracer), "whisper_full",
+		tracing.String("model", task.model),
+		tracing.Duration("offset", ts+offset),
+		tracing.Duration("duration", length),
+	)
+	// Log lines from whisper.cpp are tagged with the request id
+	if err := whisper.Whisper_log_tag(logging.RequestId(ctx), func() error {
+		return whisper.Whisper_full_with_state(task.whisper, task.state, task.params, samples)
+	}); err != nil {
 		if ctx.Err() != nil {
-			return ctx.Err()
-		} else {
-			return err
+			err = ctx.Err()
 		}
+		span.End(err)
+		return err
 	}
+	span.SetAttrs(
+		tracing.String("language", whisper.Whisper_lang_str(task.state.LangId())),
+		tracing.Int("segments", int64(task.state.NumSegments())),
+	)
+	span.End(nil)
+
+	// Accumulate the timings
+	total := time.Since(start)
+	if !encoding.IsZero() {
+		encode += time.Since(encoding)
+	}
+	task.timings.Prepare += schema.Timestamp(prepare)
+	task.timings.FirstToken += schema.Timestamp(encode)
+	task.timings.Tokens += schema.Timestamp(max(0, total-prepare-encode))
 
 	// Set the task, language and duration
 	if task.params.Translate() {
@@ -192,12 +323,8 @@ func (task *Context) Transcribe(ctx context.Context, ts time.Duration, samples [
 	} else {
 		task.result.Task = "transcribe"
 	}
-	task.result.Language = whisper.Whisper_lang_str_full(task.whisper.DefaultLangId())
-	task.result.Duration = schema.Timestamp(float64(len(samples)) * float64(time.Second) / float64(whisper.SampleRate))
-
-	// Remove the callbacks
-	task.params.SetAbortCallback(task.whisper, nil)
-	task.params.SetSegmentCallback(task.whisper, nil)
+	task.result.Language = whisper.Whisper_lang_str_full(task.state.LangId())
+	task.result.Duration = schema.Timestamp(ts + offset + length)
 
 	// Append the transcription
 	task.appendResult(ts, fn != nil)
@@ -206,6 +333,144 @@ func (task *Context) Transcribe(ctx context.Context, ts time.Duration, samples [
 	return nil
 }
 
+// Detect the spoken language of the samples, which should be 16KHz float32
+// samples in a single channel. Only the first 30 seconds of audio are considered
+// by the model. Returns the top k languages ordered by probability, or all
+// languages if k is zero
+func (task *Context) DetectLanguage(ctx context.Context, samples []float32, k int) (*schema.LanguageDetection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Detect the language
+	probs, id, err := task.detect(ctx, samples)
+	if err != nil {
+		return nil, err
+	}
+	if len(task.allowed) > 0 {
+		id = task.best(probs)
+	}
+
+	// Order languages by probability
+	languages := make([]*schema.Language, 0, len(probs))
+	for i, p := range probs {
+		if len(task.allowed) > 0 && !slices.Contains(task.allowed, i) {
+			continue
+		}
+		languages = append(languages, &schema.Language{
+			Code:        whisper.Whisper_lang_str(i),
+			Name:        whisper.Whisper_lang_str_full(i),
+			Probability: p,
+		})
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].Probability > languages[j].Probability
+	})
+	if k > 0 && k < len(languages) {
+		languages = languages[:k]
+	}
+
+	// Return success
+	return &schema.LanguageDetection{
+		Language:  whisper.Whisper_lang_str_full(id),
+		Duration:  schema.Timestamp(float64(len(samples)) * float64(time.Second) / float64(whisper.SampleRate)),
+		Languages: languages,
+	}, nil
+}
+
+// Restrict the auto-detected language to a set of languages, which can be
+// short or long language names (e.g. "no" or "norwegian"). An empty set
+// allows all languages
+func (ctx *Context) SetAllowedLanguages(v ...string) error {
+	allowed := make([]int, 0, len(v))
+	for _, lang := range v {
+		id := whisper.Whisper_lang_id(strings.ToLower(strings.TrimSpace(lang)))
+		if id == -1 {
+			return ErrBadParameter.Withf("invalid language: %q", lang)
+		}
+		if !slices.Contains(allowed, id) {
+			allowed = append(allowed, id)
+		}
+	}
+	ctx.allowed = allowed
+	return nil
+}
+
+// Return the languages allowed when the language is auto-detected
+func (ctx *Context) AllowedLanguages() []string {
+	result := make([]string, 0, len(ctx.allowed))
+	for _, id := range ctx.allowed {
+		result = append(result, whisper.Whisper_lang_str(id))
+	}
+	return result
+}
+
+// Detect the language separately for each call to Transcribe, and record
+// the language on each segment, for audio which switches language
+func (ctx *Context) SetLanguagePerSegment(v bool) {
+	ctx.perSegment = v
+}
+
+// Return the language per segment flag
+func (ctx *Context) LanguagePerSegment() bool {
+	return ctx.perSegment
+}
+
+// Set the vocabulary of domain terms. Decoding is biased towards the terms
+// according to their weights, and words in the text which closely match a
+// term are corrected after decoding. Set to nil to remove the vocabulary
+func (ctx *Context) SetVocabulary(v *vocabulary.Vocabulary) error {
+	var hotwords []vocabulary.Hotword
+	if v != nil {
+		for _, term := range v.Terms {
+			if term.Weight == 0 {
+				continue
+			}
+			// Tokenize with and without a leading space, which is part of the
+			// token at the start of a word
+			h := vocabulary.Hotword{Weight: float32(term.Weight)}
+			for _, text := range []string{" " + term.Text, term.Text} {
+				if tokens, err := whisper.Whisper_tokenize(ctx.whisper, text); err != nil {
+					return ErrBadParameter.Withf("vocabulary term %q: %v", term.Text, err)
+				} else if len(tokens) > 0 {
+					h.Tokens = append(h.Tokens, tokens)
+				}
+			}
+			hotwords = append(hotwords, h)
+		}
+	}
+	ctx.vocabulary = v
+	ctx.hotwords = hotwords
+	return nil
+}
+
+// Return the vocabulary of domain terms
+func (ctx *Context) Vocabulary() *vocabulary.Vocabulary {
+	return ctx.vocabulary
+}
+
+// Set the range of audio which is transcribed, across all calls to
+// Transcribe, with timestamps from the start of the audio. Whisper skips
+// the samples outside the range. An end of zero is the end of the audio
+func (ctx *Context) SetRange(start, end time.Duration) error {
+	if start < 0 {
+		return ErrBadParameter.Withf("start %v is negative", start)
+	} else if end != 0 && end <= start {
+		return ErrBadParameter.Withf("end %v is not after start %v", end, start)
+	}
+	ctx.start, ctx.end = start, end
+	return nil
+}
+
+// Set a callback for the progress of the transcription, across all calls
+// to Transcribe. The total duration of the audio from the start of the
+// range is required to compute the progress. Set the callback to nil to
+// remove it
+func (ctx *Context) SetProgress(total time.Duration, fn ProgressFunc) {
+	ctx.total = total
+	ctx.progress = fn
+}
+
 // Set temperature for sampling
 func (ctx *Context) SetTemperature(v float64) error {
 	if v < 0 || v > 1 {
@@ -266,21 +531,108 @@ func (ctx *Context) Result() *schema.Transcription {
 	return ctx.result
 }
 
+// Clear the transcription result, when the same audio is transcribed more
+// than once or segments are not kept after they are written
+func (ctx *Context) ClearResult() {
+	ctx.result = new(schema.Transcription)
+}
+
+// Set the time spent waiting for the context and loading the model, which
+// are kept when the context parameters are reset
+func (ctx *Context) SetPoolTimings(wait, load time.Duration) {
+	ctx.timings.Wait = schema.Timestamp(wait)
+	ctx.timings.Load = schema.Timestamp(load)
+}
+
+// Set the tracer, which records a span for each call to whisper.cpp
+func (ctx *Context) SetTracer(tracer *tracing.Tracer) {
+	ctx.tracer = tracer
+}
+
+// Return the timings for the transcription. The prepare time includes
+// language detection within whisper.cpp when the language is auto-detected
+func (ctx *Context) Timings() *schema.Timings {
+	timings := ctx.timings
+	return &timings
+}
+
 //////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Return the offset and length of the samples at timestamp ts with a
+// duration which are within the range. The length is zero or negative
+// when the samples are outside the range
+func (ctx *Context) clip(ts, duration time.Duration) (time.Duration, time.Duration) {
+	offset := max(ctx.start-ts, 0)
+	end := ts + duration
+	if ctx.end > 0 {
+		end = min(end, ctx.end)
+	}
+	return offset, end - ts - offset
+}
+
 func (ctx *Context) appendResult(ts time.Duration, segments bool) {
 	offset := len(ctx.result.Segments)
 
 	// Append text
-	for i := 0; i < ctx.whisper.NumSegments(); i++ {
-		seg := ctx.whisper.Segment(i)
-		ctx.result.Text += seg.Text
+	for i := 0; i < ctx.state.NumSegments(); i++ {
+		seg := ctx.whisper.SegmentFromState(ctx.state, i)
+		ctx.result.Text += ctx.vocabulary.Correct(seg.Text)
 	}
 	if segments {
 		// Append segments
-		for i := 0; i < ctx.whisper.NumSegments(); i++ {
-			ctx.result.Segments = append(ctx.result.Segments, newSegment(ts, int32(offset), ctx.whisper.Segment(i)))
+		for i := 0; i < ctx.state.NumSegments(); i++ {
+			ctx.result.Segments = append(ctx.result.Segments, ctx.segment(ts, int32(offset), i))
 		}
 	}
 }
+
+// Compute the mel spectrogram of the samples and return the probabilities
+// of all languages, and the most probable language id. Log lines from
+// whisper.cpp are tagged with the request id
+func (task *Context) detect(ctx context.Context, samples []float32) (probs []float32, id int, err error) {
+	defer func(start time.Time) {
+		task.timings.Detect += schema.Timestamp(time.Since(start))
+	}(time.Now())
+
+	threads := task.params.NumThreads()
+	err = whisper.Whisper_log_tag(logging.RequestId(ctx), func() error {
+		if err := whisper.Whisper_pcm_to_mel_with_state(task.whisper, task.state, samples, threads); err != nil {
+			return err
+		}
+		probs, id, err = whisper.Whisper_lang_auto_detect_with_state(task.whisper, task.state, 0, threads)
+		return err
+	})
+	return probs, id, err
+}
+
+// Return the most probable language id within the allowed set
+func (ctx *Context) best(probs []float32) int {
+	best := ctx.allowed[0]
+	for _, id := range ctx.allowed[1:] {
+		if probs[id] > probs[best] {
+			best = id
+		}
+	}
+	return best
+}
+
+// Return a segment, with the detected language if language is detected per segment
+func (ctx *Context) segment(ts time.Duration, offset int32, n int) *schema.Segment {
+	seg := newSegment(ts, offset, ctx.whisper.SegmentFromState(ctx.state, n))
+	seg.Text = ctx.vocabulary.Correct(seg.Text)
+	if ctx.perSegment {
+		seg.Language = whisper.Whisper_lang_str(ctx.state.LangId())
+	}
+	return seg
+}
+
+// Return true if a token ends a word, so that the next token can start a
+// vocabulary term. Special tokens, such as timestamps, start a segment
+func (ctx *Context) boundary(token int32) bool {
+	if token >= whisper.Whisper_token_eot(ctx.whisper) {
+		return true
+	}
+	text := whisper.Whisper_token_to_str(ctx.whisper, token)
+	return text != "" && strings.ContainsRune(".,;:!?", rune(text[len(text)-1]))
+}
@@ -32,14 +32,23 @@ func newSegment(ts time.Duration, offset int32, seg *whisper.Segment) *schema.Se
 //////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
+// Write a segment as an SRT cue
+//
+// Deprecated: Use format.New with the srt format, which numbers the cues
 func WriteSegmentSrt(w io.Writer, seg *schema.Segment) {
 	seg.WriteSRT(w, 0)
 }
 
+// Write a segment as a VTT cue
+//
+// Deprecated: Use format.New with the vtt format, which writes the header
 func WriteSegmentVtt(w io.Writer, seg *schema.Segment) {
 	seg.WriteVTT(w, 0)
 }
 
+// Write the text of a segment
+//
+// Deprecated: Use format.New with the text format
 func WriteSegmentText(w io.Writer, seg *schema.Segment) {
 	seg.WriteText(w)
 }
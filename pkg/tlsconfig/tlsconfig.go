@@ -0,0 +1,174 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	// Packages
+	httpserver "github.com/mutablelogic/go-server/pkg/httpserver"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type opts struct {
+	certFile, keyFile string
+	selfSigned        []string
+	clientCA          string
+}
+
+type Opt func(*opts) error
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Validity of self-signed certificates
+	selfSignedValidity = 30 * 24 * time.Hour
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return a TLS configuration for a server, with a certificate and private
+// key from files, which are loaded by the go-server package, or a
+// self-signed certificate. HTTP/2 is negotiated with clients which support
+// it, and TLS 1.2 is the minimum version
+func New(opt ...Opt) (*tls.Config, error) {
+	var o opts
+	for _, fn := range opt {
+		if err := fn(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	// Load or generate the certificate
+	var config *tls.Config
+	switch {
+	case o.certFile != "" && o.selfSigned != nil:
+		return nil, ErrBadParameter.With("a certificate and a self-signed certificate cannot both be set")
+	case o.certFile != "":
+		if c, err := httpserver.TLSConfig("", true, o.certFile, o.keyFile); err != nil {
+			return nil, err
+		} else {
+			config = &tls.Config{Certificates: c.Certificates}
+		}
+	case o.selfSigned != nil:
+		if c, err := SelfSigned(o.selfSigned...); err != nil {
+			return nil, err
+		} else {
+			config = &tls.Config{Certificates: []tls.Certificate{c}}
+		}
+	default:
+		return nil, ErrBadParameter.With("a certificate is required")
+	}
+	config.MinVersion = tls.VersionTLS12
+	config.NextProtos = []string{"h2", "http/1.1"}
+
+	// Require client certificates signed by the CA
+	if o.clientCA != "" {
+		data, err := os.ReadFile(o.clientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, ErrBadParameter.Withf("no certificates in %q", o.clientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	// Return success
+	return config, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Use a PEM-encoded certificate and PKCS #8 private key
+func OptCertificate(certFile, keyFile string) Opt {
+	return func(o *opts) error {
+		if certFile == "" || keyFile == "" {
+			return ErrBadParameter.With("certificate and key files are required")
+		}
+		o.certFile, o.keyFile = certFile, keyFile
+		return nil
+	}
+}
+
+// Use a self-signed certificate for the host names and addresses, for
+// development
+func OptSelfSigned(hosts ...string) Opt {
+	return func(o *opts) error {
+		o.selfSigned = append([]string{}, hosts...)
+		return nil
+	}
+}
+
+// Require clients to present a certificate signed by a CA in the
+// PEM-encoded file
+func OptClientCA(path string) Opt {
+	return func(o *opts) error {
+		if path == "" {
+			return ErrBadParameter.With("client CA file is required")
+		}
+		o.clientCA = path
+		return nil
+	}
+}
+
+// Generate a self-signed certificate for the host names and addresses,
+// which is also valid for localhost
+func SelfSigned(hosts ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"go-whisper"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range append(hosts, "localhost", "127.0.0.1", "::1") {
+		if host == "" {
+			continue
+		} else if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
@@ -0,0 +1,99 @@
+package tlsconfig_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	// Packages
+	tlsconfig "github.com/mutablelogic/go-whisper/pkg/tlsconfig"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_tlsconfig_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// A certificate is required
+	_, err := tlsconfig.New()
+	assert.Error(err)
+	_, err = tlsconfig.New(tlsconfig.OptCertificate("", ""))
+	assert.Error(err)
+
+	// Self-signed certificate
+	config, err := tlsconfig.New(tlsconfig.OptSelfSigned("whisper.local", "10.0.0.1"))
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.Equal([]string{"h2", "http/1.1"}, config.NextProtos)
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if assert.NoError(err) {
+		assert.NoError(leaf.VerifyHostname("whisper.local"))
+		assert.NoError(leaf.VerifyHostname("10.0.0.1"))
+		assert.NoError(leaf.VerifyHostname("localhost"))
+	}
+}
+
+func Test_tlsconfig_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Serve HTTP/2 with a self-signed certificate
+	config, err := tlsconfig.New(tlsconfig.OptSelfSigned())
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.TLS = config
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	leaf, _ := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	roots.AddCert(leaf)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get(server.URL)
+	if assert.NoError(err) {
+		defer resp.Body.Close()
+		assert.Equal(2, resp.ProtoMajor)
+	}
+}
+
+func Test_tlsconfig_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// The leaf of a self-signed certificate is the certificate which is served
+	cert, err := tlsconfig.SelfSigned("whisper.local")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.Equal(cert.Certificate[0], cert.Leaf.Raw)
+
+	// Load the certificate and key from PEM-encoded files
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	assert.NoError(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600))
+	assert.NoError(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600))
+	config, err := tlsconfig.New(tlsconfig.OptCertificate(certFile, keyFile))
+	if assert.NoError(err) {
+		assert.Equal(cert.Certificate, config.Certificates[0].Certificate)
+		assert.Equal(uint16(tls.VersionTLS12), config.MinVersion)
+	}
+
+	// Missing files
+	_, err = tlsconfig.New(tlsconfig.OptCertificate(certFile, filepath.Join(dir, "missing.key")))
+	assert.Error(err)
+}
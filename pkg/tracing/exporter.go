@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+
+	// Packages
+	otlptracehttp "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	stdouttrace "go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// file writes spans to a file, which is closed when the exporter is shut down
+type file struct {
+	Exporter
+	f *os.File
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Path for traces on an OTLP/HTTP collector
+	otlpTracesPath = "/v1/traces"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create an exporter for an OpenTelemetry collector with the OTLP/HTTP
+// protocol, such as http://localhost:4318. The traces path is added if the
+// endpoint has no path
+func NewOTLPExporter(endpoint string) (Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, ErrBadParameter.Withf("otlp endpoint: %v", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, ErrBadParameter.Withf("otlp endpoint: unsupported scheme %q", u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpTracesPath
+	}
+	return otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(u.String()))
+}
+
+// Create an exporter which writes spans to w as JSON lines, for debugging
+func NewWriterExporter(w io.Writer) (Exporter, error) {
+	return stdouttrace.New(stdouttrace.WithWriter(w))
+}
+
+// Create an exporter which appends spans to a file as JSON lines, for debugging
+func NewFileExporter(path string) (Exporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := NewWriterExporter(f)
+	if err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+	return &file{exporter, f}, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (exporter *file) Shutdown(ctx context.Context) error {
+	return errors.Join(exporter.Exporter.Shutdown(ctx), exporter.f.Close())
+}
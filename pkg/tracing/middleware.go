@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	// Packages
+	logger "github.com/mutablelogic/go-server/pkg/logger"
+	propagation "go.opentelemetry.io/otel/propagation"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return middleware which adds the tracer to the request context and
+// records a server span for each request. A W3C traceparent header from
+// the client is used as the parent of the span. If the tracer is nil, the
+// handler is returned unchanged
+func Middleware(tracer *Tracer) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if tracer == nil {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx := propagator.Extract(WithTracer(r.Context(), tracer), propagation.HeaderCarrier(r.Header))
+			ctx, span := StartKind(ctx, KindServer, fmt.Sprint(r.Method, " ", r.URL.Path),
+				String("http.request.method", r.Method),
+				String("url.path", r.URL.Path),
+			)
+
+			// Serve the request
+			nw := logger.NewResponseWriter(w)
+			next(nw, r.WithContext(ctx))
+
+			// End the span, with an error status for server errors
+			span.SetAttrs(Int("http.response.status_code", int64(nw.Status())))
+			if nw.Status() >= http.StatusInternalServerError {
+				span.End(fmt.Errorf("%v", http.StatusText(nw.Status())))
+			} else {
+				span.End(nil)
+			}
+		}
+	}
+}
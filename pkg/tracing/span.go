@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	// Packages
+	attribute "go.opentelemetry.io/otel/attribute"
+	codes "go.opentelemetry.io/otel/codes"
+	propagation "go.opentelemetry.io/otel/propagation"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Span is a timed operation within a trace. Methods on a nil span do
+// nothing, so instrumented code does not need to check whether tracing
+// is enabled
+type Span struct {
+	span trace.Span
+}
+
+// Attr is a span attribute
+type Attr = attribute.KeyValue
+
+// Kind is the kind of span
+type Kind = trace.SpanKind
+
+type tracerKey struct{}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	KindInternal = trace.SpanKindInternal
+	KindServer   = trace.SpanKindServer
+	KindClient   = trace.SpanKindClient
+)
+
+const (
+	// Header for propagating the trace context between services
+	TraceParentHeader = "traceparent"
+)
+
+var (
+	// Propagates the trace context with W3C traceparent headers
+	propagator = propagation.TraceContext{}
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - ATTRIBUTES
+
+func String(key, value string) Attr {
+	return attribute.String(key, value)
+}
+
+func Int(key string, value int64) Attr {
+	return attribute.Int64(key, value)
+}
+
+func Float(key string, value float64) Attr {
+	return attribute.Float64(key, value)
+}
+
+func Bool(key string, value bool) Attr {
+	return attribute.Bool(key, value)
+}
+
+// Duration is recorded in seconds
+func Duration(key string, value time.Duration) Attr {
+	return attribute.Float64(key, value.Seconds())
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - CONTEXT
+
+// Return a context with the tracer, which is used for spans started with
+// the context
+func WithTracer(ctx context.Context, tracer *Tracer) context.Context {
+	if tracer == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// Start an internal span, as a child of the span in the context. Returns
+// the context with the new span, and the span, which is nil if there is
+// no tracer in the context. The span should be ended with End
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return StartKind(ctx, KindInternal, name, attrs...)
+}
+
+// Start a span of the specified kind, as a child of the span in the context
+func StartKind(ctx context.Context, kind Kind, name string, attrs ...Attr) (context.Context, *Span) {
+	tracer, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := tracer.tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+	return ctx, &Span{span}
+}
+
+// Record a span which has already completed, as a child of the span in
+// the context, such as a stage which is measured elsewhere
+func Record(ctx context.Context, name string, start, end time.Time, attrs ...Attr) {
+	tracer, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if tracer == nil {
+		return
+	}
+	_, span := tracer.tracer.Start(ctx, name, trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	span.End(trace.WithTimestamp(end))
+}
+
+// Return the W3C traceparent header value for the span in the context,
+// or empty if there is no span
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier.Get(TraceParentHeader)
+}
+
+// Set the W3C traceparent header from the span in the context, so the
+// trace continues in a remote service
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - SPAN
+
+// Set attributes on the span
+func (span *Span) SetAttrs(attrs ...Attr) {
+	if span == nil {
+		return
+	}
+	span.span.SetAttributes(attrs...)
+}
+
+// End the span, recording the error if it is not nil
+func (span *Span) End(err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.span.RecordError(err)
+		span.span.SetStatus(codes.Error, err.Error())
+	}
+	span.span.End()
+}
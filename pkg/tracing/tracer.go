@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	// Packages
+	attribute "go.opentelemetry.io/otel/attribute"
+	resource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	trace "go.opentelemetry.io/otel/trace"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Tracer records spans with an OpenTelemetry tracer provider, which exports
+// ended spans in batches
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// Exporter writes spans to a collector, or elsewhere
+type Exporter = sdktrace.SpanExporter
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Instrumentation scope for the spans
+	scopeName = "github.com/mutablelogic/go-whisper"
+
+	// Maximum time to export the remaining spans when the tracer is closed
+	shutdownTimeout = 10 * time.Second
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a tracer for a service, which exports spans with the exporter
+func New(service string, exporter Exporter) (*Tracer, error) {
+	if service == "" || exporter == nil {
+		return nil, ErrBadParameter.With("service name and exporter are required")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+	)
+
+	// Return success
+	return &Tracer{
+		provider: provider,
+		tracer:   provider.Tracer(scopeName),
+	}, nil
+}
+
+// Export any remaining spans and stop the tracer
+func (tracer *Tracer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return tracer.provider.Shutdown(ctx)
+}
@@ -0,0 +1,182 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	// Packages
+	tracing "github.com/mutablelogic/go-whisper/pkg/tracing"
+	assert "github.com/stretchr/testify/assert"
+	codes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// exporter records the exported spans
+type exporter struct {
+	sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (e *exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.Lock()
+	defer e.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *exporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func Test_tracing_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Without a tracer, spans are nil and methods do nothing
+	ctx, span := tracing.Start(context.Background(), "noop")
+	assert.Nil(span)
+	assert.Equal("", tracing.TraceParent(ctx))
+	span.SetAttrs(tracing.String("key", "value"))
+	span.End(nil)
+	tracing.Record(ctx, "noop", time.Now(), time.Now())
+
+	_, err := tracing.New("whisper", nil)
+	assert.Error(err)
+}
+
+func Test_tracing_002(t *testing.T) {
+	assert := assert.New(t)
+
+	e := new(exporter)
+	tracer, err := tracing.New("whisper", e)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	// Parent and child spans share the trace id
+	ctx, parent := tracing.Start(tracing.WithTracer(context.Background(), tracer), "parent")
+	_, child := tracing.Start(ctx, "child", tracing.String("model", "ggml-tiny"))
+	child.End(errors.New("failed"))
+	tracing.Record(ctx, "load", time.Now().Add(-time.Second), time.Now())
+	parent.End(nil)
+	assert.NoError(tracer.Close())
+
+	if assert.Len(e.spans, 3) {
+		root := e.spans[2]
+		assert.Equal("parent", root.Name())
+		assert.False(root.Parent().IsValid())
+		assert.Equal("whisper", root.Resource().Attributes()[0].Value.AsString())
+
+		assert.Equal("child", e.spans[0].Name())
+		assert.Equal(root.SpanContext().TraceID(), e.spans[0].SpanContext().TraceID())
+		assert.Equal(root.SpanContext().SpanID(), e.spans[0].Parent().SpanID())
+		assert.Equal(codes.Error, e.spans[0].Status().Code)
+		assert.Equal("failed", e.spans[0].Status().Description)
+
+		assert.Equal("load", e.spans[1].Name())
+		assert.Equal(root.SpanContext().SpanID(), e.spans[1].Parent().SpanID())
+		assert.InDelta(time.Second, e.spans[1].EndTime().Sub(e.spans[1].StartTime()), float64(10*time.Millisecond))
+	}
+}
+
+func Test_tracing_003(t *testing.T) {
+	assert := assert.New(t)
+
+	e := new(exporter)
+	tracer, err := tracing.New("whisper", e)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	// The server span continues the trace from the traceparent header, and
+	// records server errors
+	var header http.Header
+	handler := tracing.Middleware(tracer)(func(w http.ResponseWriter, r *http.Request) {
+		header = make(http.Header)
+		tracing.Inject(r.Context(), header)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", nil)
+	r.Header.Set(tracing.TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), r)
+	assert.NoError(tracer.Close())
+
+	if assert.Len(e.spans, 1) {
+		span := e.spans[0]
+		assert.Equal("POST /v1/audio/transcriptions", span.Name())
+		assert.Equal(trace.SpanKindServer, span.SpanKind())
+		assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		assert.Equal("00f067aa0ba902b7", span.Parent().SpanID().String())
+		assert.Equal(codes.Error, span.Status().Code)
+		assert.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-"+span.SpanContext().SpanID().String()+"-01", header.Get(tracing.TraceParentHeader))
+	}
+}
+
+func Test_tracing_004(t *testing.T) {
+	assert := assert.New(t)
+
+	// Export to a collector with OTLP/HTTP
+	var path string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	otlp, err := tracing.NewOTLPExporter(server.URL)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	tracer, err := tracing.New("whisper", otlp)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	_, span := tracing.Start(tracing.WithTracer(context.Background(), tracer), "whisper_full", tracing.Int("segments", 3))
+	span.End(nil)
+	assert.NoError(tracer.Close())
+	assert.Equal("/v1/traces", path)
+	assert.Contains(string(body), "whisper_full")
+
+	_, err = tracing.NewOTLPExporter("localhost:4318")
+	assert.Error(err)
+}
+
+func Test_tracing_005(t *testing.T) {
+	assert := assert.New(t)
+
+	// Write spans to a writer
+	var buf bytes.Buffer
+	w, err := tracing.NewWriterExporter(&buf)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	tracer, _ := tracing.New("whisper", w)
+	_, span := tracing.Start(tracing.WithTracer(context.Background(), tracer), "decode")
+	span.End(nil)
+	assert.NoError(tracer.Close())
+	assert.Contains(buf.String(), `"Name":"decode"`)
+
+	// Append spans to a file
+	path := filepath.Join(t.TempDir(), "spans.json")
+	f, err := tracing.NewFileExporter(path)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	tracer, _ = tracing.New("whisper", f)
+	_, span = tracing.Start(tracing.WithTracer(context.Background(), tracer), "encode")
+	span.End(nil)
+	assert.NoError(tracer.Close())
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Contains(string(data), `"Name":"encode"`)
+}
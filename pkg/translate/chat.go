@@ -0,0 +1,127 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	// Packages
+	client "github.com/mutablelogic/go-client"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Chat translates text using an OpenAI chat-completions compatible endpoint
+type Chat struct {
+	*client.Client
+	model string
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	chatPath   = "chat/completions"
+	chatPrompt = "You translate subtitles. The user message is a JSON array of strings. " +
+		"Translate each string %sto %s and reply with only a JSON array of the translated strings, " +
+		"with the same number of elements in the same order. Do not merge or split elements."
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// NewChat creates a translator for an OpenAI chat-completions compatible endpoint,
+// with an optional API key
+func NewChat(endpoint, apikey, model string, opts ...client.ClientOpt) (*Chat, error) {
+	if model == "" {
+		return nil, ErrBadParameter.With("model is required")
+	}
+	opts = append([]client.ClientOpt{
+		client.OptEndpoint(endpoint),
+	}, opts...)
+	if apikey != "" {
+		opts = append(opts, client.OptReqToken(client.Token{
+			Scheme: "Bearer",
+			Value:  apikey,
+		}))
+	}
+	if client, err := client.New(opts...); err != nil {
+		return nil, err
+	} else {
+		return &Chat{Client: client, model: model}, nil
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Translate text from the source language to the target language
+func (c *Chat) Translate(ctx context.Context, source, target string, text []string) ([]string, error) {
+	if target == "" {
+		return nil, ErrBadParameter.With("target language is required")
+	} else if len(text) == 0 {
+		return []string{}, nil
+	}
+
+	// Create the prompt
+	from := ""
+	if source != "" && source != "auto" {
+		from = fmt.Sprintf("from %s ", source)
+	}
+	content, err := json.Marshal(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the request
+	var response chatResponse
+	if payload, err := client.NewJSONRequest(chatRequest{
+		Model: c.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: fmt.Sprintf(chatPrompt, from, target)},
+			{Role: "user", Content: string(content)},
+		},
+	}); err != nil {
+		return nil, err
+	} else if err := c.DoWithContext(ctx, payload, &response, client.OptPath(chatPath)); err != nil {
+		return nil, err
+	} else if len(response.Choices) == 0 {
+		return nil, ErrUnexpectedResponse.With("no choices in response")
+	}
+
+	// Parse the response, which may be wrapped in a code block
+	var result []string
+	reply := strings.TrimSpace(response.Choices[0].Message.Content)
+	reply = strings.TrimPrefix(strings.TrimPrefix(reply, "```json"), "```")
+	reply = strings.TrimSuffix(reply, "```")
+	if err := json.Unmarshal([]byte(strings.TrimSpace(reply)), &result); err != nil {
+		return nil, ErrUnexpectedResponse.Withf("invalid translation: %v", err)
+	} else if len(result) != len(text) {
+		return nil, ErrUnexpectedResponse.Withf("expected %d translations, got %d", len(text), len(result))
+	}
+
+	// Return success
+	return result, nil
+}
@@ -0,0 +1,31 @@
+package translate
+
+import (
+	"context"
+	"slices"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Local is a stand-in translator which returns the text unchanged, for
+// development and testing without a translation service
+type Local struct{}
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func NewLocal() *Local {
+	return new(Local)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Translate returns a copy of the text
+func (*Local) Translate(ctx context.Context, source, target string, text []string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(text), nil
+}
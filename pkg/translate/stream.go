@@ -0,0 +1,139 @@
+package translate
+
+import (
+	"context"
+	"sync"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// SegmentsFunc translates the text of segments in place from the source
+// language to the target language
+type SegmentsFunc func(ctx context.Context, source, target string, segments ...*schema.Segment) error
+
+// EmitFunc is called with each translated segment and its source language
+type EmitFunc func(language string, seg *schema.Segment) error
+
+// Stream translates segments in the background, so that decoding is not
+// stalled by the translation backend. Segments which are queued while a
+// batch is translated are translated together in the next batch, and
+// emitted in order
+type Stream struct {
+	ch   chan streamItem
+	wg   sync.WaitGroup
+	err  error
+	emit EmitFunc
+}
+
+type streamItem struct {
+	language string
+	seg      *schema.Segment
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Maximum number of segments queued for translation, before decoding
+	// waits for the translation backend
+	streamQueueSize = 64
+
+	// Maximum number of segments translated in one request to the backend
+	streamBatchSize = 16
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Start translating segments to the target language with a translation
+// function. Translated segments are passed to emit. On the first error,
+// cancel is called with the error and the remaining segments are discarded
+func NewStream(ctx context.Context, fn SegmentsFunc, target string, cancel context.CancelCauseFunc, emit EmitFunc) *Stream {
+	s := &Stream{
+		ch:   make(chan streamItem, streamQueueSize),
+		emit: emit,
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for batch := s.next(); len(batch) > 0; batch = s.next() {
+			if s.err != nil || ctx.Err() != nil {
+				continue
+			}
+			if err := s.translate(ctx, fn, target, batch); err != nil {
+				s.err = err
+				cancel(err)
+			}
+		}
+	}()
+	return s
+}
+
+// Wait for the queued segments to be translated, and return the first error
+func (s *Stream) Close() error {
+	close(s.ch)
+	s.wg.Wait()
+	return s.err
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Queue a segment in a language for translation
+func (s *Stream) Add(language string, seg *schema.Segment) {
+	s.ch <- streamItem{language, seg}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the next batch of segments, waiting for at least one segment and
+// adding the segments which are already queued. Returns nil when the queue
+// is closed
+func (s *Stream) next() []streamItem {
+	item, ok := <-s.ch
+	if !ok {
+		return nil
+	}
+	batch := []streamItem{item}
+	for len(batch) < streamBatchSize {
+		select {
+		case item, ok := <-s.ch:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, item)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// Translate a batch of segments, in runs of the same language, and emit them
+func (s *Stream) translate(ctx context.Context, fn SegmentsFunc, target string, batch []streamItem) error {
+	for len(batch) > 0 {
+		n := 1
+		for n < len(batch) && batch[n].language == batch[0].language {
+			n++
+		}
+		segments := make([]*schema.Segment, n)
+		for i, item := range batch[:n] {
+			segments[i] = item.seg
+		}
+		if err := fn(ctx, batch[0].language, target, segments...); err != nil {
+			return err
+		}
+		for _, item := range batch[:n] {
+			if err := s.emit(item.language, item.seg); err != nil {
+				return err
+			}
+		}
+		batch = batch[n:]
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package translate
+
+import (
+	"context"
+	"strings"
+
+	// Packages
+	schema "github.com/mutablelogic/go-whisper/pkg/schema"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Translator translates text from the source language to the target language.
+// The source language may be empty, in which case it is detected by the
+// translator. The result has the same number of elements as the input text.
+type Translator interface {
+	Translate(ctx context.Context, source, target string, text []string) ([]string, error)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Segments translates the text of each segment in place, preserving the timing
+func Segments(ctx context.Context, t Translator, source, target string, segments ...*schema.Segment) error {
+	if t == nil {
+		return ErrNotImplemented.With("no translation backend")
+	} else if len(segments) == 0 {
+		return nil
+	}
+
+	// Collect the text of the segments
+	text := make([]string, len(segments))
+	for i, segment := range segments {
+		text[i] = segment.Text
+	}
+
+	// Translate the text
+	result, err := t.Translate(ctx, source, target, text)
+	if err != nil {
+		return err
+	} else if len(result) != len(text) {
+		return ErrUnexpectedResponse.Withf("expected %d translations, got %d", len(text), len(result))
+	}
+
+	// Replace the text of the segments, retaining leading whitespace
+	for i, segment := range segments {
+		segment.Text = leadingSpace(segment.Text) + strings.TrimSpace(result[i])
+	}
+
+	// Return success
+	return nil
+}
+
+// Transcription translates the segments and text of a transcription in place
+func Transcription(ctx context.Context, t Translator, target string, transcription *schema.Transcription) error {
+	if t == nil {
+		return ErrNotImplemented.With("no translation backend")
+	}
+
+	// Where there are no segments, translate the text
+	if len(transcription.Segments) == 0 {
+		if strings.TrimSpace(transcription.Text) == "" {
+			return nil
+		}
+		result, err := t.Translate(ctx, transcription.Language, target, []string{transcription.Text})
+		if err != nil {
+			return err
+		} else if len(result) != 1 {
+			return ErrUnexpectedResponse.Withf("expected 1 translation, got %d", len(result))
+		}
+		transcription.Text = result[0]
+		return nil
+	}
+
+	// Translate the segments and join the text
+	if err := Segments(ctx, t, transcription.Language, target, transcription.Segments...); err != nil {
+		return err
+	}
+	var text strings.Builder
+	for _, segment := range transcription.Segments {
+		text.WriteString(segment.Text)
+	}
+	transcription.Text = text.String()
+
+	// Return success
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func leadingSpace(v string) string {
+	return v[:len(v)-len(strings.TrimLeft(v, " \t"))]
+}
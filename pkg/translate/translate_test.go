@@ -0,0 +1,138 @@
+package translate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	// Packages
+	"github.com/mutablelogic/go-whisper/pkg/schema"
+	"github.com/mutablelogic/go-whisper/pkg/translate"
+	"github.com/stretchr/testify/assert"
+)
+
+///////////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Translate_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Local translator returns the text unchanged, preserving timing
+	segments := []*schema.Segment{
+		{Id: 0, Start: schema.SecToTimestamp(0), End: schema.SecToTimestamp(1), Text: " Hallo"},
+		{Id: 1, Start: schema.SecToTimestamp(1), End: schema.SecToTimestamp(2), Text: " Welt"},
+	}
+	assert.NoError(translate.Segments(context.Background(), translate.NewLocal(), "de", "fr", segments...))
+	assert.Equal(" Hallo", segments[0].Text)
+	assert.Equal(schema.SecToTimestamp(1), segments[1].Start)
+
+	// No translator
+	assert.Error(translate.Segments(context.Background(), nil, "de", "fr", segments...))
+}
+
+func Test_Translate_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Chat-completions endpoint which uppercases the text
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if !assert.Equal("/chat/completions", r.URL.Path) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal("Bearer key", r.Header.Get("Authorization"))
+		assert.NoError(json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal("model", req.Model)
+		assert.Len(req.Messages, 2)
+		assert.Contains(req.Messages[0].Content, "french")
+
+		var text []string
+		assert.NoError(json.Unmarshal([]byte(req.Messages[1].Content), &text))
+		for i := range text {
+			text[i] = strings.ToUpper(text[i])
+		}
+		content, _ := json.Marshal(text)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]any{"role": "assistant", "content": "```json\n" + string(content) + "\n```"}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	translator, err := translate.NewChat(server.URL, "key", "model")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	transcription := &schema.Transcription{
+		Language: "german",
+		Segments: []*schema.Segment{
+			{Id: 0, Start: schema.SecToTimestamp(0), End: schema.SecToTimestamp(1), Text: " hallo"},
+			{Id: 1, Start: schema.SecToTimestamp(1), End: schema.SecToTimestamp(2), Text: " welt"},
+		},
+	}
+	assert.NoError(translate.Transcription(context.Background(), translator, "french", transcription))
+	assert.Equal(" HALLO", transcription.Segments[0].Text)
+	assert.Equal(" WELT", transcription.Segments[1].Text)
+	assert.Equal(" HALLO WELT", transcription.Text)
+	assert.Equal(schema.SecToTimestamp(2), transcription.Segments[1].End)
+}
+
+func Test_Translate_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// Segments are translated in the background, and emitted in order with
+	// their language
+	upper := func(ctx context.Context, source, target string, segments ...*schema.Segment) error {
+		for _, segment := range segments {
+			segment.Text = strings.ToUpper(segment.Text)
+		}
+		return nil
+	}
+	var emitted []string
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stream := translate.NewStream(ctx, upper, "french", cancel, func(language string, seg *schema.Segment) error {
+		emitted = append(emitted, language+":"+seg.Text)
+		return nil
+	})
+	stream.Add("german", &schema.Segment{Text: " hallo"})
+	stream.Add("english", &schema.Segment{Text: " hello"})
+	stream.Add("german", &schema.Segment{Text: " welt"})
+	assert.NoError(stream.Close())
+	assert.Equal([]string{"german: HALLO", "english: HELLO", "german: WELT"}, emitted)
+	assert.NoError(context.Cause(ctx))
+}
+
+func Test_Translate_004(t *testing.T) {
+	assert := assert.New(t)
+
+	// The first error cancels the context, and the remaining segments are
+	// discarded
+	failed := errors.New("backend failed")
+	fail := func(ctx context.Context, source, target string, segments ...*schema.Segment) error {
+		return failed
+	}
+	var emitted int
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stream := translate.NewStream(ctx, fail, "french", cancel, func(string, *schema.Segment) error {
+		emitted++
+		return nil
+	})
+	stream.Add("german", &schema.Segment{Text: " hallo"})
+	stream.Add("german", &schema.Segment{Text: " welt"})
+	assert.ErrorIs(stream.Close(), failed)
+	assert.ErrorIs(context.Cause(ctx), failed)
+	assert.Zero(emitted)
+}
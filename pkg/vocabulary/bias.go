@@ -0,0 +1,51 @@
+package vocabulary
+
+import (
+	"slices"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Hotword is the token sequences of a term, such as the term with and without
+// a leading space, and the weight added to the logits of its tokens
+type Hotword struct {
+	Tokens [][]int32
+	Weight float32
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Bias adds the weights of hotwords to the logits of the next token. Where
+// the decoded tokens end with the start of a term, the next token of the
+// term is boosted. The first token of a term is only boosted at a word
+// boundary, where no tokens have been decoded or boundary returns true for
+// the last token, so that decoding is not pulled towards the terms elsewhere
+func Bias(hotwords []Hotword, tokens []int32, logits []float32, boundary func(token int32) bool) {
+	start := len(tokens) == 0 || boundary(tokens[len(tokens)-1])
+	for _, h := range hotwords {
+		for _, seq := range h.Tokens {
+			if id, ok := next(seq, tokens, start); ok && int(id) < len(logits) {
+				logits[id] += h.Weight
+			}
+		}
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the next token of a sequence, where the tokens end with the longest
+// start of the sequence, or the first token at a word boundary
+func next(seq, tokens []int32, start bool) (int32, bool) {
+	for k := min(len(seq)-1, len(tokens)); k > 0; k-- {
+		if slices.Equal(tokens[len(tokens)-k:], seq[:k]) {
+			return seq[k], true
+		}
+	}
+	if start && len(seq) > 0 {
+		return seq[0], true
+	}
+	return 0, false
+}
@@ -0,0 +1,211 @@
+package vocabulary
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Term is a word or phrase, with a weight used to boost the likelihood of the
+// term during decoding
+type Term struct {
+	Text   string  `json:"text"`
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// Vocabulary is a list of domain terms
+type Vocabulary struct {
+	Terms []Term `json:"terms"`
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	DefaultWeight = 2.0
+	MaxWeight     = 10.0
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Parse terms in the form "term" or "term:weight" where the weight is
+// between zero and MaxWeight. Terms may be comma-separated. Returns nil if
+// there are no terms
+func Parse(v ...string) (*Vocabulary, error) {
+	vocabulary := new(Vocabulary)
+	for _, value := range v {
+		for _, term := range strings.Split(value, ",") {
+			if term = strings.TrimSpace(term); term == "" {
+				continue
+			}
+			weight := DefaultWeight
+			if i := strings.LastIndexByte(term, ':'); i >= 0 {
+				if w, err := strconv.ParseFloat(strings.TrimSpace(term[i+1:]), 64); err != nil {
+					return nil, ErrBadParameter.Withf("invalid weight for %q", term)
+				} else {
+					term, weight = strings.TrimSpace(term[:i]), w
+				}
+			}
+			if term == "" {
+				return nil, ErrBadParameter.With("empty vocabulary term")
+			} else if weight < 0 || weight > MaxWeight {
+				return nil, ErrBadParameter.Withf("weight for %q must be between 0 and %v", term, MaxWeight)
+			}
+			vocabulary.Terms = append(vocabulary.Terms, Term{Text: term, Weight: weight})
+		}
+	}
+	if len(vocabulary.Terms) == 0 {
+		return nil, nil
+	}
+	return vocabulary, nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (v *Vocabulary) String() string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Correct replaces words and phrases in the text which closely match a term
+// with the term itself, preserving surrounding whitespace and punctuation. A
+// term which replaces a capitalised word, such as at the start of a sentence,
+// is capitalised
+func (v *Vocabulary) Correct(text string) string {
+	if v == nil || len(v.Terms) == 0 {
+		return text
+	}
+	words := split(text)
+	for _, term := range v.Terms {
+		n := len(strings.Fields(term.Text))
+		if n == 0 {
+			continue
+		}
+		for i := 0; i+n <= len(words); i++ {
+			// Compare the words, without punctuation
+			window := words[i : i+n]
+			candidate := make([]string, n)
+			for j, w := range window {
+				candidate[j] = w.word
+			}
+			if !similar(strings.Join(candidate, " "), term.Text) {
+				continue
+			}
+
+			// Replace the window with the term
+			window[0].word = matchCase(term.Text, window[0].word)
+			window[0].suffix = window[n-1].suffix
+			words = append(words[:i+1], words[i+n:]...)
+		}
+	}
+	return join(words)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+type word struct {
+	prefix, word, suffix string
+}
+
+// Return the term, capitalised when the matched text is capitalised
+func matchCase(term, matched string) string {
+	m, _ := utf8.DecodeRuneInString(matched)
+	r, n := utf8.DecodeRuneInString(term)
+	if unicode.IsUpper(m) && unicode.IsLower(r) {
+		return string(unicode.ToUpper(r)) + term[n:]
+	}
+	return term
+}
+
+// Split text into words, retaining whitespace and punctuation around each word
+func split(text string) []word {
+	var result []word
+	for len(text) > 0 {
+		var w word
+		i := strings.IndexFunc(text, isWord)
+		if i < 0 {
+			if len(result) > 0 {
+				result[len(result)-1].suffix += text
+			} else {
+				result = append(result, word{prefix: text})
+			}
+			break
+		}
+		w.prefix, text = text[:i], text[i:]
+		j := strings.IndexFunc(text, func(r rune) bool { return !isWord(r) })
+		if j < 0 {
+			j = len(text)
+		}
+		w.word, text = text[:j], text[j:]
+		k := strings.IndexFunc(text, isWord)
+		if k < 0 {
+			k = len(text)
+		}
+		w.suffix, text = text[:k], text[k:]
+		result = append(result, w)
+	}
+	return result
+}
+
+func join(words []word) string {
+	var result strings.Builder
+	for _, w := range words {
+		result.WriteString(w.prefix)
+		result.WriteString(w.word)
+		result.WriteString(w.suffix)
+	}
+	return result.String()
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '-'
+}
+
+// Return true if the candidate is a close match for the term, allowing one
+// edit for every four characters in the term. Short terms must match exactly,
+// ignoring case
+func similar(candidate, term string) bool {
+	a, b := []rune(strings.ToLower(candidate)), []rune(strings.ToLower(term))
+	if len(b) < 4 {
+		return string(a) == string(b)
+	}
+	return distance(a, b) <= len(b)/4
+}
+
+// Return the Levenshtein distance between two strings
+func distance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,97 @@
+package vocabulary_test
+
+import (
+	"testing"
+
+	// Packages
+	"github.com/mutablelogic/go-whisper/pkg/vocabulary"
+	"github.com/stretchr/testify/assert"
+)
+
+///////////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func Test_Vocabulary_001(t *testing.T) {
+	assert := assert.New(t)
+
+	v, err := vocabulary.Parse("metformin:5", "Roe v. Wade, atorvastatin")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.Len(v.Terms, 3)
+	assert.Equal(vocabulary.Term{Text: "metformin", Weight: 5}, v.Terms[0])
+	assert.Equal(vocabulary.Term{Text: "Roe v. Wade", Weight: vocabulary.DefaultWeight}, v.Terms[1])
+	assert.Equal("atorvastatin", v.Terms[2].Text)
+
+	// Empty
+	v, err = vocabulary.Parse("", " , ")
+	assert.NoError(err)
+	assert.Nil(v)
+
+	// Invalid weights
+	_, err = vocabulary.Parse("metformin:x")
+	assert.Error(err)
+	_, err = vocabulary.Parse("metformin:100")
+	assert.Error(err)
+}
+
+func Test_Vocabulary_002(t *testing.T) {
+	assert := assert.New(t)
+
+	v, err := vocabulary.Parse("metformin", "atorvastatin", "Dobbs", "Jackson Women's Health")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	tests := []struct {
+		in, out string
+	}{
+		{" The patient takes metforman daily.", " The patient takes metformin daily."},
+		{" Start Atorvastatine, 20mg.", " Start Atorvastatin, 20mg."},
+		{" Metforman is prescribed.", " Metformin is prescribed."},
+		{" dobbs versus jackson", " Dobbs versus jackson"},
+		{" Dobbs versus Jackson Woman's Health.", " Dobbs versus Jackson Women's Health."},
+		{" Dabs are not corrected", " Dabs are not corrected"},
+		{"", ""},
+		{" ...", " ..."},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			assert.Equal(test.out, v.Correct(test.in))
+		})
+	}
+
+	// Nil vocabulary
+	var empty *vocabulary.Vocabulary
+	assert.Equal(" metforman", empty.Correct(" metforman"))
+}
+
+func Test_Vocabulary_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// A term of three tokens, and a word boundary after token 9
+	hotwords := []vocabulary.Hotword{{Tokens: [][]int32{{1, 2, 3}}, Weight: 5}}
+	boundary := func(token int32) bool { return token == 9 }
+	bias := func(tokens ...int32) []float32 {
+		logits := make([]float32, 10)
+		vocabulary.Bias(hotwords, tokens, logits, boundary)
+		return logits
+	}
+
+	// The first token is boosted at the start, and at a word boundary
+	assert.Equal(float32(5), bias()[1])
+	assert.Equal(float32(5), bias(7, 9)[1])
+
+	// The first token is not boosted within text
+	assert.Equal(make([]float32, 10), bias(7, 8))
+
+	// The next token is boosted where the tokens end with the start of the
+	// term, and the first token is not
+	logits := bias(7, 1)
+	assert.Equal(float32(5), logits[2])
+	assert.Zero(logits[1])
+	assert.Equal(float32(5), bias(7, 1, 2)[3])
+
+	// Nothing is boosted after the term
+	assert.Equal(make([]float32, 10), bias(1, 2, 3))
+}
@@ -0,0 +1,134 @@
+// Package ffmpeg provides the ffmpeg functions which are not bound by
+// the go-media bindings, on the types of those bindings
+package ffmpeg
+
+import (
+	"io"
+	"syscall"
+	"unsafe"
+
+	// Packages
+	ff "github.com/mutablelogic/go-media/sys/ffmpeg71"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CGO
+
+/*
+#cgo pkg-config: libavformat libavfilter
+#include <libavformat/avformat.h>
+#include <libavfilter/avfilter.h>
+#include <libavfilter/buffersrc.h>
+#include <libavfilter/buffersink.h>
+#include <stdlib.h>
+#include <string.h>
+
+static void graph_set_resample_opts(AVFilterGraph* graph, const char* opts) {
+	av_freep(&graph->aresample_swr_opts);
+	if (opts[0] != '\0') {
+		graph->aresample_swr_opts = av_strdup(opts);
+	}
+}
+*/
+import "C"
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	keyLanguage = "language"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// STREAM
+
+// Return the language of a stream from the stream metadata, usually an
+// ISO 639-2 code such as "eng", or an empty string
+func AVStream_language(stream *ff.AVStream) string {
+	ctx := (*C.AVStream)(unsafe.Pointer(stream))
+	key := C.CString(keyLanguage)
+	defer C.free(unsafe.Pointer(key))
+	if entry := C.av_dict_get(ctx.metadata, key, nil, 0); entry != nil {
+		return C.GoString(entry.value)
+	}
+	return ""
+}
+
+// Set the language of a stream in the stream metadata
+func AVStream_set_language(stream *ff.AVStream, language string) error {
+	ctx := (*C.AVStream)(unsafe.Pointer(stream))
+	key, value := C.CString(keyLanguage), C.CString(language)
+	defer C.free(unsafe.Pointer(key))
+	defer C.free(unsafe.Pointer(value))
+	if err := C.av_dict_set(&ctx.metadata, key, value, 0); err < 0 {
+		return ff.AVError(err)
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PACKET
+
+// Replace the data of a packet, keeping the timestamps
+func AVCodec_packet_set_data(pkt *ff.AVPacket, data []byte) error {
+	ctx := (*C.AVPacket)(unsafe.Pointer(pkt))
+	if err := C.av_packet_make_writable(ctx); err < 0 {
+		return ff.AVError(err)
+	}
+	if n := len(data) - int(ctx.size); n > 0 {
+		if err := C.av_grow_packet(ctx, C.int(n)); err < 0 {
+			return ff.AVError(err)
+		}
+	} else {
+		C.av_shrink_packet(ctx, C.int(len(data)))
+	}
+	if len(data) > 0 {
+		C.memcpy(unsafe.Pointer(ctx.data), unsafe.Pointer(&data[0]), C.size_t(len(data)))
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// FILTER
+
+// Link an output pad of a filter to an input pad of another filter
+func AVFilter_link(src *ff.AVFilterContext, srcpad int, dst *ff.AVFilterContext, dstpad int) error {
+	if err := C.avfilter_link((*C.AVFilterContext)(unsafe.Pointer(src)), C.uint(srcpad), (*C.AVFilterContext)(unsafe.Pointer(dst)), C.uint(dstpad)); err < 0 {
+		return ff.AVError(err)
+	}
+	return nil
+}
+
+// Set the swresample options of the aresample filters which are inserted
+// into a filter graph to convert between sample rates, such as
+// "filter_size=64:cutoff=0.97" or "resampler=soxr"
+func AVFilterGraph_set_resample_opts(graph *ff.AVFilterGraph, opts string) {
+	cOpts := C.CString(opts)
+	defer C.free(unsafe.Pointer(cOpts))
+	C.graph_set_resample_opts((*C.AVFilterGraph)(unsafe.Pointer(graph)), cOpts)
+}
+
+// Add a frame to a buffersrc filter, or mark the end of the stream when the
+// frame is nil. The frame is reset, as the filter takes its references
+func AVBufferSrc_add_frame_flags(ctx *ff.AVFilterContext, frame *ff.AVFrame, flags int) error {
+	if err := ff.AVError(C.av_buffersrc_add_frame_flags((*C.AVFilterContext)(unsafe.Pointer(ctx)), (*C.AVFrame)(unsafe.Pointer(frame)), C.int(flags))); err != 0 {
+		return err
+	}
+	return nil
+}
+
+// Return a filtered frame from a buffersink filter. Error return of EAGAIN
+// means that more frames need to be added to produce output, while io.EOF
+// means that the end of the stream has been reached
+func AVBufferSink_get_frame(ctx *ff.AVFilterContext, frame *ff.AVFrame) error {
+	if err := ff.AVError(C.av_buffersink_get_frame((*C.AVFilterContext)(unsafe.Pointer(ctx)), (*C.AVFrame)(unsafe.Pointer(frame)))); err != 0 {
+		if err == ff.AVERROR_EOF {
+			return io.EOF
+		} else if err.IsErrno(syscall.EAGAIN) {
+			return syscall.EAGAIN
+		} else {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,7 +3,12 @@ package whisper
 import "errors"
 
 var (
-	ErrTranscriptionFailed = errors.New("whisper_full failed")
+	ErrTranscriptionFailed  = errors.New("whisper_full failed")
+	ErrMelFailed            = errors.New("whisper_pcm_to_mel failed")
+	ErrLanguageDetectFailed = errors.New("whisper_lang_auto_detect failed")
+	ErrTokenizeFailed       = errors.New("whisper_tokenize failed")
+	ErrEncodeFailed         = errors.New("whisper_encode failed")
+	ErrDecodeFailed         = errors.New("whisper_decode failed")
 )
 
 type HTTPError struct {
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"unsafe"
 )
 
@@ -20,6 +21,12 @@ import (
 extern void whisper_progress_cb_ex(struct whisper_context * ctx, struct whisper_state * state, int progress, void * user_data);
 extern void whisper_segment_cb_ex(struct whisper_context * ctx, struct whisper_state * state, int n, void * user_data);
 extern bool whisper_abort_cb_ex(void * user_data);
+extern bool whisper_encoder_begin_cb_ex(struct whisper_context * ctx, struct whisper_state * state, void * user_data);
+extern void whisper_logits_filter_cb_ex(struct whisper_context * ctx, struct whisper_state * state, whisper_token_data * tokens, int n_tokens, float * logits, void * user_data);
+
+static void whisper_logits_filter_cb(struct whisper_context * ctx, struct whisper_state * state, const whisper_token_data * tokens, int n_tokens, float * logits, void * user_data) {
+	whisper_logits_filter_cb_ex(ctx, state, (whisper_token_data *)tokens, n_tokens, logits, user_data);
+}
 
 // Set callbacks
 static void set_callbacks(struct whisper_full_params* params,  bool enabled) {
@@ -33,6 +40,24 @@ static void set_callbacks(struct whisper_full_params* params,  bool enabled) {
 		params->new_segment_callback = NULL;
 	}
 }
+
+// Set encoder begin callback, which is called before each run of the encoder
+static void set_encoder_begin_callback(struct whisper_full_params* params, bool enabled) {
+	if (enabled) {
+		params->encoder_begin_callback = whisper_encoder_begin_cb_ex;
+	} else {
+		params->encoder_begin_callback = NULL;
+	}
+}
+
+// Set logits filter callback, which is called for every decoded token
+static void set_logits_filter_callback(struct whisper_full_params* params, bool enabled) {
+	if (enabled) {
+		params->logits_filter_callback = whisper_logits_filter_cb;
+	} else {
+		params->logits_filter_callback = NULL;
+	}
+}
 */
 import "C"
 
@@ -53,6 +78,20 @@ type SegmentCallback func(segment int)
 // If it returns true, the computation is aborted
 type AbortCallback func() bool
 
+// Handle is a context or a state, which is used to identify callbacks. When
+// a context is shared between concurrent transcriptions, use the state
+type Handle interface {
+	handle() unsafe.Pointer
+}
+
+// Called before each run of the encoder. If it returns false, the
+// computation is aborted
+type EncoderBeginCallback func() bool
+
+// Called before sampling each token, with the ids of the tokens decoded so far
+// and the logits for the next token, which can be modified in place
+type LogitsFilterCallback func(tokens []int32, logits []float32)
+
 ///////////////////////////////////////////////////////////////////////////////
 // GLOBALS
 
@@ -62,10 +101,13 @@ const (
 )
 
 var (
-	// Map a uintptr context to a callback
+	// Map a uintptr context or state to a callback
+	cbLock     sync.RWMutex
 	progressCb = map[uint]ProgressCallback{}
 	segmentCb  = map[uint]SegmentCallback{}
 	abortCb    = map[uint]AbortCallback{}
+	encoderCb  = map[uint]EncoderBeginCallback{}
+	logitsCb   = map[uint]LogitsFilterCallback{}
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -218,6 +260,10 @@ func (c *FullParams) SetNumThreads(v int) {
 	c.n_threads = (C.int)(v)
 }
 
+func (c *FullParams) NumThreads() int {
+	return int(c.n_threads)
+}
+
 func (c *FullParams) SetMaxTextCtx(v int) {
 	c.n_max_text_ctx = (C.int)(v)
 }
@@ -311,8 +357,11 @@ func (c *FullParams) SetPrompt(v string) {
 	c.initial_prompt = C.CString(v)
 }
 
-func (c *FullParams) SetProgressCallback(ctx *Context, cb ProgressCallback) {
-	key := cbkey(unsafe.Pointer(ctx))
+func (c *FullParams) SetProgressCallback(h Handle, cb ProgressCallback) {
+	cbLock.Lock()
+	defer cbLock.Unlock()
+
+	key := cbkey(h.handle())
 	if cb == nil {
 		c.progress_callback_user_data = nil
 		delete(progressCb, key)
@@ -322,8 +371,11 @@ func (c *FullParams) SetProgressCallback(ctx *Context, cb ProgressCallback) {
 	}
 }
 
-func (c *FullParams) SetSegmentCallback(ctx *Context, cb SegmentCallback) {
-	key := cbkey(unsafe.Pointer(ctx))
+func (c *FullParams) SetSegmentCallback(h Handle, cb SegmentCallback) {
+	cbLock.Lock()
+	defer cbLock.Unlock()
+
+	key := cbkey(h.handle())
 	if cb == nil {
 		c.new_segment_callback_user_data = nil
 		delete(segmentCb, key)
@@ -333,8 +385,11 @@ func (c *FullParams) SetSegmentCallback(ctx *Context, cb SegmentCallback) {
 	}
 }
 
-func (c *FullParams) SetAbortCallback(ctx *Context, cb AbortCallback) {
-	key := cbkey(unsafe.Pointer(ctx))
+func (c *FullParams) SetAbortCallback(h Handle, cb AbortCallback) {
+	cbLock.Lock()
+	defer cbLock.Unlock()
+
+	key := cbkey(h.handle())
 	if cb == nil {
 		c.abort_callback_user_data = nil
 		delete(abortCb, key)
@@ -344,6 +399,38 @@ func (c *FullParams) SetAbortCallback(ctx *Context, cb AbortCallback) {
 	}
 }
 
+func (c *FullParams) SetEncoderBeginCallback(h Handle, cb EncoderBeginCallback) {
+	cbLock.Lock()
+	defer cbLock.Unlock()
+
+	key := cbkey(h.handle())
+	if cb == nil {
+		C.set_encoder_begin_callback((*C.struct_whisper_full_params)(c), C.bool(false))
+		c.encoder_begin_callback_user_data = nil
+		delete(encoderCb, key)
+	} else {
+		C.set_encoder_begin_callback((*C.struct_whisper_full_params)(c), C.bool(true))
+		c.encoder_begin_callback_user_data = unsafe.Pointer(uintptr(key))
+		encoderCb[key] = cb
+	}
+}
+
+func (c *FullParams) SetLogitsFilterCallback(h Handle, cb LogitsFilterCallback) {
+	cbLock.Lock()
+	defer cbLock.Unlock()
+
+	key := cbkey(h.handle())
+	if cb == nil {
+		C.set_logits_filter_callback((*C.struct_whisper_full_params)(c), C.bool(false))
+		c.logits_filter_callback_user_data = nil
+		delete(logitsCb, key)
+	} else {
+		C.set_logits_filter_callback((*C.struct_whisper_full_params)(c), C.bool(true))
+		c.logits_filter_callback_user_data = unsafe.Pointer(uintptr(key))
+		logitsCb[key] = cb
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
@@ -351,27 +438,67 @@ func cbkey(ptr unsafe.Pointer) uint {
 	return uint(uintptr(ptr))
 }
 
+func (ctx *Context) handle() unsafe.Pointer {
+	return unsafe.Pointer(ctx)
+}
+
+func (state *State) handle() unsafe.Pointer {
+	return unsafe.Pointer(state)
+}
+
 //export whisper_progress_cb_ex
 func whisper_progress_cb_ex(ctx *C.struct_whisper_context, state *C.struct_whisper_state, progress C.int, user_data unsafe.Pointer) {
-	key := cbkey(user_data)
-	if cb, ok := progressCb[key]; ok {
+	cbLock.RLock()
+	cb, ok := progressCb[cbkey(user_data)]
+	cbLock.RUnlock()
+	if ok {
 		cb(int(progress))
 	}
 }
 
 //export whisper_segment_cb_ex
 func whisper_segment_cb_ex(ctx *C.struct_whisper_context, state *C.struct_whisper_state, n C.int, user_data unsafe.Pointer) {
-	key := cbkey(user_data)
-	if cb, ok := segmentCb[key]; ok {
+	cbLock.RLock()
+	cb, ok := segmentCb[cbkey(user_data)]
+	cbLock.RUnlock()
+	if ok {
 		cb(int(n))
 	}
 }
 
 //export whisper_abort_cb_ex
 func whisper_abort_cb_ex(user_data unsafe.Pointer) C.bool {
-	key := cbkey(user_data)
-	if cb, ok := abortCb[key]; ok {
+	cbLock.RLock()
+	cb, ok := abortCb[cbkey(user_data)]
+	cbLock.RUnlock()
+	if ok {
 		return C.bool(cb())
 	}
 	return C.bool(false)
 }
+
+//export whisper_encoder_begin_cb_ex
+func whisper_encoder_begin_cb_ex(ctx *C.struct_whisper_context, state *C.struct_whisper_state, user_data unsafe.Pointer) C.bool {
+	cbLock.RLock()
+	cb, ok := encoderCb[cbkey(user_data)]
+	cbLock.RUnlock()
+	if ok {
+		return C.bool(cb())
+	}
+	return C.bool(true)
+}
+
+//export whisper_logits_filter_cb_ex
+func whisper_logits_filter_cb_ex(ctx *C.struct_whisper_context, state *C.struct_whisper_state, tokens *C.whisper_token_data, n_tokens C.int, logits *C.float, user_data unsafe.Pointer) {
+	cbLock.RLock()
+	cb, ok := logitsCb[cbkey(user_data)]
+	cbLock.RUnlock()
+	if ok {
+		data := unsafe.Slice(tokens, int(n_tokens))
+		ids := make([]int32, len(data))
+		for i := range data {
+			ids[i] = int32(data[i].id)
+		}
+		cb(ids, unsafe.Slice((*float32)(unsafe.Pointer(logits)), int(C.whisper_n_vocab(ctx))))
+	}
+}
@@ -1,6 +1,9 @@
 package whisper
 
 import (
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -11,12 +14,21 @@ import (
 #cgo pkg-config: libwhisper
 #include <whisper.h>
 #include <stdlib.h>
+#include <stdint.h>
 
-extern void callLog(enum ggml_log_level level,char* text, void* user_data);
+extern void callLog(enum ggml_log_level level,char* text, uintptr_t tag);
 
-// Logging callback
+// Tag for log lines from the current thread, or zero
+static _Thread_local uintptr_t whisper_log_tag;
+
+// Set the tag for log lines from the current thread
+static void whisper_log_set_tag(uintptr_t tag) {
+	whisper_log_tag = tag;
+}
+
+// Logging callback, with the tag of the thread which logged the text
 static void whisper_log_cb(enum ggml_log_level level, const char* text, void* user_data) {
-	callLog(level, (char*)text, user_data);
+	callLog(level, (char*)text, whisper_log_tag);
 }
 
 // Set or unset logging callback
@@ -41,7 +53,11 @@ type (
 // GLOBALS
 
 var (
-	cbLog func(level LogLevel, text string)
+	cbLog func(level LogLevel, tag, text string)
+
+	// Tags for log lines, keyed by the value set for the thread
+	logTags sync.Map
+	logTag  atomic.Uintptr
 )
 
 const (
@@ -74,6 +90,18 @@ func (v LogLevel) String() string {
 
 // Set logging output
 func Whisper_log_set(fn func(level LogLevel, text string)) {
+	if fn == nil {
+		Whisper_log_set_tagged(nil)
+	} else {
+		Whisper_log_set_tagged(func(level LogLevel, _, text string) {
+			fn(level, text)
+		})
+	}
+}
+
+// Set logging output, which receives the tag set with Whisper_log_tag for
+// the thread which logged the text, or an empty tag
+func Whisper_log_set_tagged(fn func(level LogLevel, tag, text string)) {
 	cbLog = fn
 	if fn == nil {
 		C.whisper_log_set_ex(nil)
@@ -82,6 +110,28 @@ func Whisper_log_set(fn func(level LogLevel, text string)) {
 	}
 }
 
+// Tag log lines from whisper.cpp while fn runs, such as with a request id.
+// The goroutine is locked to its thread while fn runs, so that calls into
+// whisper.cpp from fn log on the thread which has the tag. Log lines from
+// threads which whisper.cpp starts are not tagged
+func Whisper_log_tag(tag string, fn func() error) error {
+	if tag == "" {
+		return fn()
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Set the tag for the thread, and remove it when done
+	key := logTag.Add(1)
+	logTags.Store(key, tag)
+	C.whisper_log_set_tag(C.uintptr_t(key))
+	defer func() {
+		C.whisper_log_set_tag(0)
+		logTags.Delete(key)
+	}()
+	return fn()
+}
+
 // Call logging output
 func Whisper_log(level LogLevel, text string, user_data unsafe.Pointer) {
 	cStr := C.CString(text)
@@ -93,8 +143,15 @@ func Whisper_log(level LogLevel, text string, user_data unsafe.Pointer) {
 // PRIVATE METHODS
 
 //export callLog
-func callLog(level C.enum_ggml_log_level, text *C.char, user_data unsafe.Pointer) {
-	if cbLog != nil {
-		cbLog(LogLevel(level), C.GoString(text))
+func callLog(level C.enum_ggml_log_level, text *C.char, key C.uintptr_t) {
+	if cbLog == nil {
+		return
+	}
+	var tag string
+	if key != 0 {
+		if v, ok := logTags.Load(uintptr(key)); ok {
+			tag = v.(string)
+		}
 	}
+	cbLog(LogLevel(level), tag, C.GoString(text))
 }
@@ -0,0 +1,167 @@
+package whisper
+
+import (
+	"unsafe"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CGO
+
+/*
+#cgo pkg-config: libwhisper
+#include <whisper.h>
+#include <stdlib.h>
+*/
+import "C"
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// State holds the results of a transcription, so that a single context (the
+// loaded model) can be shared between concurrent transcriptions
+type State C.struct_whisper_state
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new state for the context. Returns nil on error.
+func Whisper_init_state(ctx *Context) *State {
+	return (*State)(C.whisper_init_state((*C.struct_whisper_context)(ctx)))
+}
+
+// Frees all memory allocated by the state.
+func Whisper_free_state(state *State) {
+	C.whisper_free_state((*C.struct_whisper_state)(state))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC FUNCTIONS
+
+// Run the entire model using the state: PCM -> log mel spectrogram -> encoder
+// -> decoder -> text. Results are stored in the state, not the context, so the
+// context can be used concurrently with different states
+func Whisper_full_with_state(ctx *Context, state *State, params FullParams, samples []float32) error {
+	// Free any allocated memory in params after use
+	defer params.Close()
+
+	// Run the model
+	if len(samples) == 0 {
+		return ErrTranscriptionFailed
+	}
+	if C.whisper_full_with_state((*C.struct_whisper_context)(ctx), (*C.struct_whisper_state)(state), (C.struct_whisper_full_params)(params), (*C.float)(&samples[0]), C.int(len(samples))) != 0 {
+		return ErrTranscriptionFailed
+	}
+	return nil
+}
+
+// Convert RAW PCM audio to log mel spectrogram, which is stored in the state
+func Whisper_pcm_to_mel_with_state(ctx *Context, state *State, samples []float32, threads int) error {
+	if len(samples) == 0 {
+		return ErrMelFailed
+	}
+	if C.whisper_pcm_to_mel_with_state((*C.struct_whisper_context)(ctx), (*C.struct_whisper_state)(state), (*C.float)(&samples[0]), C.int(len(samples)), C.int(threads)) != 0 {
+		return ErrMelFailed
+	}
+	return nil
+}
+
+// Use mel data in the state at offset_ms to try and auto-detect the spoken
+// language. Returns the probabilities of all languages, indexed by language
+// id, and the most probable language id
+func Whisper_lang_auto_detect_with_state(ctx *Context, state *State, offset_ms, threads int) ([]float32, int, error) {
+	probs := make([]float32, Whisper_lang_max_id()+1)
+	id := int(C.whisper_lang_auto_detect_with_state((*C.struct_whisper_context)(ctx), (*C.struct_whisper_state)(state), C.int(offset_ms), C.int(threads), (*C.float)(&probs[0])))
+	if id < 0 {
+		return nil, -1, ErrLanguageDetectFailed
+	}
+	return probs, id, nil
+}
+
+// Run the encoder on the mel spectrogram stored in the state, starting at
+// offset (in mel frames)
+func Whisper_encode_with_state(ctx *Context, state *State, offset, threads int) error {
+	if C.whisper_encode_with_state((*C.struct_whisper_context)(ctx), (*C.struct_whisper_state)(state), C.int(offset), C.int(threads)) != 0 {
+		return ErrEncodeFailed
+	}
+	return nil
+}
+
+// Run the decoder on the tokens, using the encoder output stored in the
+// state. n_past is the number of tokens to use from previous decoder calls
+func Whisper_decode_with_state(ctx *Context, state *State, tokens []int32, n_past, threads int) error {
+	if len(tokens) == 0 {
+		return ErrDecodeFailed
+	}
+	if C.whisper_decode_with_state((*C.struct_whisper_context)(ctx), (*C.struct_whisper_state)(state), (*C.whisper_token)(unsafe.Pointer(&tokens[0])), C.int(len(tokens)), C.int(n_past), C.int(threads)) != 0 {
+		return ErrDecodeFailed
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Number of generated text segments
+func (state *State) NumSegments() int {
+	return int(C.whisper_full_n_segments_from_state((*C.struct_whisper_state)(state)))
+}
+
+// Language id associated with the state
+func (state *State) LangId() int {
+	return int(C.whisper_full_lang_id_from_state((*C.struct_whisper_state)(state)))
+}
+
+// Get the start time of the specified segment
+func (state *State) SegmentT0(n int) int64 {
+	return int64(C.whisper_full_get_segment_t0_from_state((*C.struct_whisper_state)(state), C.int(n)))
+}
+
+// Get the end time of the specified segment
+func (state *State) SegmentT1(n int) int64 {
+	return int64(C.whisper_full_get_segment_t1_from_state((*C.struct_whisper_state)(state), C.int(n)))
+}
+
+// Get whether the next segment is predicted as a speaker turn
+func (state *State) SegmentSpeakerTurnNext(n int) bool {
+	return (bool)(C.whisper_full_get_segment_speaker_turn_next_from_state((*C.struct_whisper_state)(state), C.int(n)))
+}
+
+// Get the text of the specified segment
+func (state *State) SegmentText(n int) string {
+	return C.GoString(C.whisper_full_get_segment_text_from_state((*C.struct_whisper_state)(state), C.int(n)))
+}
+
+// Get the no speech probability of the specified segment
+func (state *State) SegmentNoSpeechProb(n int) float32 {
+	return float32(C.whisper_full_get_segment_no_speech_prob_from_state((*C.struct_whisper_state)(state), C.int(n)))
+}
+
+// Get number of tokens in the specified segment
+func (state *State) SegmentNumTokens(n int) int {
+	return int(C.whisper_full_n_tokens_from_state((*C.struct_whisper_state)(state), C.int(n)))
+}
+
+// Get token data for the specified token in the specified segment
+func (state *State) SegmentTokenData(n, i int) TokenData {
+	return (TokenData)(C.whisper_full_get_token_data_from_state((*C.struct_whisper_state)(state), C.int(n), C.int(i)))
+}
+
+// Return a segment from the state, or nil
+func (ctx *Context) SegmentFromState(state *State, n int) *Segment {
+	if n < 0 || n >= state.NumSegments() {
+		return nil
+	}
+	tokens := make([]Token, state.SegmentNumTokens(n))
+	for i := range tokens {
+		tokens[i] = ctx.Token(state.SegmentTokenData(n, i))
+	}
+	return &Segment{
+		Id:           int32(n),
+		Text:         state.SegmentText(n),
+		SpeakerTurn:  state.SegmentSpeakerTurnNext(n),
+		NoSpeechProb: state.SegmentNoSpeechProb(n),
+		Tokens:       tokens,
+		T0:           tsToDuration(C.int64_t(state.SegmentT0(n))),
+		T1:           tsToDuration(C.int64_t(state.SegmentT1(n))),
+	}
+}
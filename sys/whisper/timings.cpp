@@ -0,0 +1,13 @@
+#include <whisper.h>
+
+// whisper_get_timings returns a struct allocated with new, which cannot be
+// released from Go with free. Copy the timings and delete the struct here.
+extern "C" bool go_whisper_get_timings(struct whisper_context * ctx, struct whisper_timings * out) {
+    struct whisper_timings * timings = whisper_get_timings(ctx);
+    if (timings == nullptr) {
+        return false;
+    }
+    *out = *timings;
+    delete timings;
+    return true;
+}
@@ -0,0 +1,70 @@
+package whisper
+
+import (
+	"encoding/json"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CGO
+
+/*
+#cgo pkg-config: libwhisper
+#include <whisper.h>
+#include <stdbool.h>
+
+// Defined in timings.cpp
+extern bool go_whisper_get_timings(struct whisper_context* ctx, struct whisper_timings* out);
+*/
+import "C"
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Performance timings for the context's default state, in milliseconds
+type Timings struct {
+	SampleMS float32 `json:"sample_ms"`
+	EncodeMS float32 `json:"encode_ms"`
+	DecodeMS float32 `json:"decode_ms"`
+	BatchdMS float32 `json:"batchd_ms"`
+	PromptMS float32 `json:"prompt_ms"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC FUNCTIONS
+
+// Return performance timings for the context's default state, or nil if
+// there is no default state
+func Whisper_get_timings(ctx *Context) *Timings {
+	var timings C.struct_whisper_timings
+	if !C.go_whisper_get_timings((*C.struct_whisper_context)(ctx), &timings) {
+		return nil
+	}
+	return &Timings{
+		SampleMS: float32(timings.sample_ms),
+		EncodeMS: float32(timings.encode_ms),
+		DecodeMS: float32(timings.decode_ms),
+		BatchdMS: float32(timings.batchd_ms),
+		PromptMS: float32(timings.prompt_ms),
+	}
+}
+
+// Print performance timings to the log
+func Whisper_print_timings(ctx *Context) {
+	C.whisper_print_timings((*C.struct_whisper_context)(ctx))
+}
+
+// Reset performance timings
+func Whisper_reset_timings(ctx *Context) {
+	C.whisper_reset_timings((*C.struct_whisper_context)(ctx))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (t Timings) String() string {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
@@ -77,6 +77,40 @@ func Whisper_lang_str_full(id int) string {
 	return C.GoString(C.whisper_lang_str_full(C.int(id)))
 }
 
+// Return the number of tokens in the model vocabulary
+func Whisper_n_vocab(ctx *Context) int {
+	return int(C.whisper_n_vocab((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the start of transcript token
+func Whisper_token_sot(ctx *Context) int32 {
+	return int32(C.whisper_token_sot((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the end of transcript token
+func Whisper_token_eot(ctx *Context) int32 {
+	return int32(C.whisper_token_eot((*C.struct_whisper_context)(ctx)))
+}
+
+// Convert text into tokens
+func Whisper_tokenize(ctx *Context, text string) ([]int32, error) {
+	cText := C.CString(text)
+	defer C.free(unsafe.Pointer(cText))
+
+	// There are never more tokens than bytes in the text
+	tokens := make([]int32, len(text)+1)
+	n := C.whisper_tokenize((*C.struct_whisper_context)(ctx), cText, (*C.whisper_token)(unsafe.Pointer(&tokens[0])), C.int(len(tokens)))
+	if n < 0 {
+		return nil, ErrTokenizeFailed
+	}
+	return tokens[:n], nil
+}
+
+// Return the text for a token
+func Whisper_token_to_str(ctx *Context, token int32) string {
+	return C.GoString(C.whisper_token_to_str((*C.struct_whisper_context)(ctx), C.whisper_token(token)))
+}
+
 // Return model capabilities
 func Whisper_is_multilingual(ctx *Context) bool {
 	return C.whisper_is_multilingual((*C.struct_whisper_context)(ctx)) != 0
@@ -96,6 +130,110 @@ func Whisper_full(ctx *Context, params FullParams, samples []float32) error {
 	return nil
 }
 
+// Split the samples into n_processors chunks and process them in parallel,
+// each with its own state. The results are merged into the context's default
+// state. Timestamps may be inaccurate at the boundaries between chunks
+func Whisper_full_parallel(ctx *Context, params FullParams, samples []float32, n_processors int) error {
+	// Free any allocated memory in params after use
+	defer params.Close()
+
+	// Run the model
+	if len(samples) == 0 {
+		return ErrTranscriptionFailed
+	}
+	if C.whisper_full_parallel((*C.struct_whisper_context)(ctx), (C.struct_whisper_full_params)(params), (*C.float)(&samples[0]), C.int(len(samples)), C.int(n_processors)) != 0 {
+		return ErrTranscriptionFailed
+	}
+	return nil
+}
+
+// Run the encoder on the mel spectrogram stored in the context's default
+// state, starting at offset (in mel frames)
+func Whisper_encode(ctx *Context, offset, threads int) error {
+	if C.whisper_encode((*C.struct_whisper_context)(ctx), C.int(offset), C.int(threads)) != 0 {
+		return ErrEncodeFailed
+	}
+	return nil
+}
+
+// Run the decoder on the tokens, using the encoder output stored in the
+// context's default state. n_past is the number of tokens to use from
+// previous decoder calls
+func Whisper_decode(ctx *Context, tokens []int32, n_past, threads int) error {
+	if len(tokens) == 0 {
+		return ErrDecodeFailed
+	}
+	if C.whisper_decode((*C.struct_whisper_context)(ctx), (*C.whisper_token)(unsafe.Pointer(&tokens[0])), C.int(len(tokens)), C.int(n_past), C.int(threads)) != 0 {
+		return ErrDecodeFailed
+	}
+	return nil
+}
+
+// Return the model vocabulary size
+func Whisper_model_n_vocab(ctx *Context) int {
+	return int(C.whisper_model_n_vocab((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the model audio context size
+func Whisper_model_n_audio_ctx(ctx *Context) int {
+	return int(C.whisper_model_n_audio_ctx((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the model audio state size
+func Whisper_model_n_audio_state(ctx *Context) int {
+	return int(C.whisper_model_n_audio_state((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the number of audio attention heads
+func Whisper_model_n_audio_head(ctx *Context) int {
+	return int(C.whisper_model_n_audio_head((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the number of audio layers
+func Whisper_model_n_audio_layer(ctx *Context) int {
+	return int(C.whisper_model_n_audio_layer((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the model text context size
+func Whisper_model_n_text_ctx(ctx *Context) int {
+	return int(C.whisper_model_n_text_ctx((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the model text state size
+func Whisper_model_n_text_state(ctx *Context) int {
+	return int(C.whisper_model_n_text_state((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the number of text attention heads
+func Whisper_model_n_text_head(ctx *Context) int {
+	return int(C.whisper_model_n_text_head((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the number of text layers
+func Whisper_model_n_text_layer(ctx *Context) int {
+	return int(C.whisper_model_n_text_layer((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the number of mel frequency bands
+func Whisper_model_n_mels(ctx *Context) int {
+	return int(C.whisper_model_n_mels((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the model weight type (e.g. 0 = F32, 1 = F16, 8 = Q5_1)
+func Whisper_model_ftype(ctx *Context) int {
+	return int(C.whisper_model_ftype((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the model type (e.g. 1 = tiny, 2 = base)
+func Whisper_model_type(ctx *Context) int {
+	return int(C.whisper_model_type((*C.struct_whisper_context)(ctx)))
+}
+
+// Return the model type as a string (e.g. "tiny", "base")
+func Whisper_model_type_readable(ctx *Context) string {
+	return C.GoString(C.whisper_model_type_readable((*C.struct_whisper_context)(ctx)))
+}
+
 // Number of generated text segments
 // A segment can be a few words, a sentence, or even a paragraph.
 func (ctx *Context) NumSegments() int {
@@ -122,6 +260,11 @@ func (ctx *Context) SegmentSpeakerTurnNext(n int) bool {
 	return (bool)(C.whisper_full_get_segment_speaker_turn_next((*C.struct_whisper_context)(ctx), C.int(n)))
 }
 
+// Get the no speech probability of the specified segment
+func (ctx *Context) SegmentNoSpeechProb(n int) float32 {
+	return float32(C.whisper_full_get_segment_no_speech_prob((*C.struct_whisper_context)(ctx), C.int(n)))
+}
+
 // Get the text of the specified segment
 func (ctx *Context) SegmentText(n int) string {
 	return C.GoString(C.whisper_full_get_segment_text((*C.struct_whisper_context)(ctx), C.int(n)))
@@ -152,3 +295,27 @@ func (ctx *Context) SegmentTokenProb(n, i int) float32 {
 func (ctx *Context) SegmentTokenData(n, i int) TokenData {
 	return (TokenData)(C.whisper_full_get_token_data((*C.struct_whisper_context)(ctx), C.int(n), C.int(i)))
 }
+
+// Convert RAW PCM audio to log mel spectrogram, which is stored in the
+// context's default state. Required before calling Whisper_lang_auto_detect.
+func Whisper_pcm_to_mel(ctx *Context, samples []float32, threads int) error {
+	if len(samples) == 0 {
+		return ErrMelFailed
+	}
+	if C.whisper_pcm_to_mel((*C.struct_whisper_context)(ctx), (*C.float)(&samples[0]), C.int(len(samples)), C.int(threads)) != 0 {
+		return ErrMelFailed
+	}
+	return nil
+}
+
+// Use mel data at offset_ms to try and auto-detect the spoken language.
+// Returns the probabilities of all languages, indexed by language id,
+// and the most probable language id
+func Whisper_lang_auto_detect(ctx *Context, offset_ms, threads int) ([]float32, int, error) {
+	probs := make([]float32, Whisper_lang_max_id()+1)
+	id := int(C.whisper_lang_auto_detect((*C.struct_whisper_context)(ctx), C.int(offset_ms), C.int(threads), (*C.float)(&probs[0])))
+	if id < 0 {
+		return nil, -1, ErrLanguageDetectFailed
+	}
+	return probs, id, nil
+}
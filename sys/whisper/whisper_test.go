@@ -537,6 +537,271 @@ func Test_whisper_05(t *testing.T) {
 	})
 }
 
+func Test_whisper_06(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create a file for the model
+	w, err := os.Create(filepath.Join(t.TempDir(), MODEL_TINY))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	defer w.Close()
+
+	// Read the model
+	client := whisper.NewClient(MODEL_URL)
+	if !assert.NotNil(client) {
+		t.SkipNow()
+	}
+	if _, err := client.Get(context.Background(), w, MODEL_TINY); !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Create a context
+	params := whisper.DefaultContextParams()
+	params.SetUseGpu(false)
+	ctx := whisper.Whisper_init_from_file_with_params(w.Name(), params)
+	if !assert.NotNil(ctx) {
+		t.SkipNow()
+	}
+	defer whisper.Whisper_free(ctx)
+
+	for _, sample := range []struct {
+		path, lang string
+	}{
+		{SAMPLE_EN, "en"}, {SAMPLE_FR, "fr"}, {SAMPLE_DE, "de"},
+	} {
+		t.Run("DetectLanguage_"+sample.lang, func(t *testing.T) {
+			data, err := LoadSamples(sample.path)
+			if !assert.NoError(err) {
+				t.SkipNow()
+			}
+
+			// Compute the mel spectrogram, then detect the language
+			assert.NoError(whisper.Whisper_pcm_to_mel(ctx, data, 4))
+			probs, id, err := whisper.Whisper_lang_auto_detect(ctx, 0, 4)
+			if !assert.NoError(err) {
+				t.SkipNow()
+			}
+			assert.Len(probs, whisper.Whisper_lang_max_id()+1)
+			assert.Equal(sample.lang, whisper.Whisper_lang_str(id))
+			t.Logf("Detected %q with probability %.2f", whisper.Whisper_lang_str_full(id), probs[id])
+		})
+	}
+}
+
+func Test_whisper_07(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create a file for the model
+	w, err := os.Create(filepath.Join(t.TempDir(), MODEL_TINY))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	defer w.Close()
+
+	// Read the model
+	client := whisper.NewClient(MODEL_URL)
+	if !assert.NotNil(client) {
+		t.SkipNow()
+	}
+	if _, err := client.Get(context.Background(), w, MODEL_TINY); !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Create a context
+	params := whisper.DefaultContextParams()
+	params.SetUseGpu(false)
+	ctx := whisper.Whisper_init_from_file_with_params(w.Name(), params)
+	if !assert.NotNil(ctx) {
+		t.SkipNow()
+	}
+	defer whisper.Whisper_free(ctx)
+
+	t.Run("Tokenize", func(t *testing.T) {
+		tokens, err := whisper.Whisper_tokenize(ctx, " ask not what your country can do for you")
+		if !assert.NoError(err) {
+			t.SkipNow()
+		}
+		assert.NotEmpty(tokens)
+
+		var text string
+		for _, token := range tokens {
+			text += whisper.Whisper_token_to_str(ctx, token)
+		}
+		assert.Equal(" ask not what your country can do for you", text)
+	})
+
+	t.Run("LogitsFilter", func(t *testing.T) {
+		data, err := LoadSamples(SAMPLE_EN)
+		if !assert.NoError(err) {
+			t.SkipNow()
+		}
+
+		var calls int
+		params := whisper.DefaultFullParams(whisper.SAMPLING_GREEDY)
+		params.SetLanguage("en")
+		params.SetLogitsFilterCallback(ctx, func(tokens []int32, logits []float32) {
+			assert.Len(logits, whisper.Whisper_n_vocab(ctx))
+			calls++
+		})
+		assert.NoError(whisper.Whisper_full(ctx, params, data))
+		params.SetLogitsFilterCallback(ctx, nil)
+		assert.NotZero(calls)
+	})
+}
+
+func Test_whisper_08(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create a file for the model
+	w, err := os.Create(filepath.Join(t.TempDir(), MODEL_TINY))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	defer w.Close()
+
+	// Read the model
+	client := whisper.NewClient(MODEL_URL)
+	if !assert.NotNil(client) {
+		t.SkipNow()
+	}
+	if _, err := client.Get(context.Background(), w, MODEL_TINY); !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Create a context
+	params := whisper.DefaultContextParams()
+	params.SetUseGpu(false)
+	ctx := whisper.Whisper_init_from_file_with_params(w.Name(), params)
+	if !assert.NotNil(ctx) {
+		t.SkipNow()
+	}
+	defer whisper.Whisper_free(ctx)
+
+	t.Run("Model", func(t *testing.T) {
+		assert.Equal("tiny", whisper.Whisper_model_type_readable(ctx))
+		assert.Equal(whisper.Whisper_n_vocab(ctx), whisper.Whisper_model_n_vocab(ctx))
+		assert.Equal(1500, whisper.Whisper_model_n_audio_ctx(ctx))
+		assert.Equal(384, whisper.Whisper_model_n_audio_state(ctx))
+		assert.Equal(6, whisper.Whisper_model_n_audio_head(ctx))
+		assert.Equal(4, whisper.Whisper_model_n_audio_layer(ctx))
+		assert.Equal(448, whisper.Whisper_model_n_text_ctx(ctx))
+		assert.Equal(384, whisper.Whisper_model_n_text_state(ctx))
+		assert.Equal(6, whisper.Whisper_model_n_text_head(ctx))
+		assert.Equal(4, whisper.Whisper_model_n_text_layer(ctx))
+		assert.Equal(80, whisper.Whisper_model_n_mels(ctx))
+		assert.GreaterOrEqual(whisper.Whisper_model_ftype(ctx), 0)
+		assert.GreaterOrEqual(whisper.Whisper_model_type(ctx), 0)
+	})
+
+	t.Run("EncodeDecode", func(t *testing.T) {
+		data, err := LoadSamples(SAMPLE_EN)
+		if !assert.NoError(err) {
+			t.SkipNow()
+		}
+		whisper.Whisper_reset_timings(ctx)
+		assert.NoError(whisper.Whisper_pcm_to_mel(ctx, data, 4))
+		assert.NoError(whisper.Whisper_encode(ctx, 0, 4))
+		assert.NoError(whisper.Whisper_decode(ctx, []int32{whisper.Whisper_token_sot(ctx)}, 0, 4))
+		assert.ErrorIs(whisper.Whisper_decode(ctx, nil, 0, 4), whisper.ErrDecodeFailed)
+
+		timings := whisper.Whisper_get_timings(ctx)
+		if assert.NotNil(timings) {
+			assert.Greater(timings.EncodeMS, float32(0))
+			t.Log(timings)
+		}
+		whisper.Whisper_print_timings(ctx)
+	})
+}
+
+func Test_whisper_09(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create a file for the model
+	w, err := os.Create(filepath.Join(t.TempDir(), MODEL_TINY))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	defer w.Close()
+
+	// Read the model
+	client := whisper.NewClient(MODEL_URL)
+	if !assert.NotNil(client) {
+		t.SkipNow()
+	}
+	if _, err := client.Get(context.Background(), w, MODEL_TINY); !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Create a context
+	params := whisper.DefaultContextParams()
+	params.SetUseGpu(false)
+	ctx := whisper.Whisper_init_from_file_with_params(w.Name(), params)
+	if !assert.NotNil(ctx) {
+		t.SkipNow()
+	}
+	defer whisper.Whisper_free(ctx)
+
+	data, err := LoadSamples(SAMPLE_EN)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	t.Run("FullWithState", func(t *testing.T) {
+		state := whisper.Whisper_init_state(ctx)
+		if !assert.NotNil(state) {
+			t.SkipNow()
+		}
+		defer whisper.Whisper_free_state(state)
+
+		params := whisper.DefaultFullParams(whisper.SAMPLING_GREEDY)
+		params.SetLanguage("auto")
+		assert.NoError(whisper.Whisper_full_with_state(ctx, state, params, data))
+		assert.Equal("en", whisper.Whisper_lang_str(state.LangId()))
+		if assert.NotZero(state.NumSegments()) {
+			segment := ctx.SegmentFromState(state, 0)
+			if assert.NotNil(segment) {
+				assert.Contains(segment.Text, "ask not what your country can do for you")
+				assert.NotEmpty(segment.Tokens)
+				assert.GreaterOrEqual(segment.NoSpeechProb, float32(0))
+				assert.Less(segment.NoSpeechProb, float32(0.5))
+			}
+		}
+		assert.Nil(ctx.SegmentFromState(state, state.NumSegments()))
+	})
+
+	t.Run("DetectLanguageWithState", func(t *testing.T) {
+		state := whisper.Whisper_init_state(ctx)
+		if !assert.NotNil(state) {
+			t.SkipNow()
+		}
+		defer whisper.Whisper_free_state(state)
+
+		assert.NoError(whisper.Whisper_pcm_to_mel_with_state(ctx, state, data, 4))
+		probs, id, err := whisper.Whisper_lang_auto_detect_with_state(ctx, state, 0, 4)
+		if assert.NoError(err) {
+			assert.Equal("en", whisper.Whisper_lang_str(id))
+			assert.Greater(probs[id], float32(0.5))
+		}
+		assert.NoError(whisper.Whisper_encode_with_state(ctx, state, 0, 4))
+		assert.NoError(whisper.Whisper_decode_with_state(ctx, state, []int32{whisper.Whisper_token_sot(ctx)}, 0, 4))
+	})
+
+	t.Run("FullParallel", func(t *testing.T) {
+		params := whisper.DefaultFullParams(whisper.SAMPLING_GREEDY)
+		params.SetLanguage("en")
+		assert.NoError(whisper.Whisper_full_parallel(ctx, params, data, 2))
+		if assert.NotZero(ctx.NumSegments()) {
+			var text []string
+			for i := 0; i < ctx.NumSegments(); i++ {
+				text = append(text, ctx.SegmentText(i))
+				assert.GreaterOrEqual(ctx.SegmentNoSpeechProb(i), float32(0))
+			}
+			t.Log(strings.Join(text, ""))
+		}
+	})
+}
+
 //////////////////////////////////////////////////////////////////////////////
 
 // Return samples as []float32
@@ -555,3 +820,21 @@ func LoadSamples(path string) ([]float32, error) {
 		return buf.AsFloat32Buffer().Data, nil
 	}
 }
+
+func Test_whisper_10(t *testing.T) {
+	assert := assert.New(t)
+
+	// Log lines are tagged while a function runs
+	var tags []string
+	whisper.Whisper_log_set_tagged(func(level whisper.LogLevel, tag, text string) {
+		tags = append(tags, tag)
+	})
+	defer whisper.Whisper_log_set(nil)
+	whisper.Whisper_log(whisper.LogLevelInfo, "untagged", nil)
+	assert.NoError(whisper.Whisper_log_tag("request", func() error {
+		whisper.Whisper_log(whisper.LogLevelInfo, "tagged", nil)
+		return nil
+	}))
+	whisper.Whisper_log(whisper.LogLevelInfo, "untagged", nil)
+	assert.Equal([]string{"", "request", ""}, tags)
+}
@@ -5,15 +5,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"runtime"
 	"strings"
+	"time"
 
 	// Packages
 	ffmpeg "github.com/mutablelogic/go-media/pkg/ffmpeg"
+	logging "github.com/mutablelogic/go-whisper/pkg/logging"
+	metrics "github.com/mutablelogic/go-whisper/pkg/metrics"
 	pool "github.com/mutablelogic/go-whisper/pkg/pool"
 	schema "github.com/mutablelogic/go-whisper/pkg/schema"
 	store "github.com/mutablelogic/go-whisper/pkg/store"
 	task "github.com/mutablelogic/go-whisper/pkg/task"
+	tracing "github.com/mutablelogic/go-whisper/pkg/tracing"
+	translate "github.com/mutablelogic/go-whisper/pkg/translate"
 	whisper "github.com/mutablelogic/go-whisper/sys/whisper"
 
 	// Namespace imports
@@ -25,8 +32,12 @@ import (
 
 // Whisper represents a whisper service for running transcription and translation
 type Whisper struct {
-	pool  *pool.ContextPool
-	store *store.Store
+	pool       *pool.ContextPool
+	store      *store.Store
+	translator translate.Translator
+	metrics    *metrics.Metrics
+	logger     *slog.Logger
+	tracer     *tracing.Tracer
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -61,20 +72,43 @@ func New(path string, opt ...Opt) (*Whisper, error) {
 
 	// Create a new whisper service
 	w := new(Whisper)
+	w.translator = o.translator
+	w.tracer = o.tracer
+	w.metrics = metrics.New()
 	if store, err := store.NewStore(path, extModel, defaultModelUrl); err != nil {
 		return nil, err
 	} else {
 		w.store = store
 	}
 
+	// Register models with sources
+	for _, model := range o.models {
+		if _, err := w.store.Register(model.Id, model.Source); err != nil {
+			return nil, err
+		}
+	}
+
 	if pool := pool.NewContextPool(path, o.MaxConcurrent, o.gpu); pool == nil {
 		return nil, ErrInternalAppError
 	} else {
 		w.pool = pool
 	}
 
-	// Logging
-	if o.logfn != nil {
+	// Logging. Log lines from whisper.cpp are tagged with the request id
+	// while a request is transcribed
+	if o.logger != nil {
+		w.logger = o.logger
+		w.pool.SetLogger(o.logger)
+		whisper.Whisper_log_set_tagged(func(level whisper.LogLevel, id, text string) {
+			if text = strings.TrimSpace(text); text == "" {
+				return
+			}
+			o.logger.Log(logging.WithRequestId(context.Background(), id), logLevel(level), text, "source", "whisper")
+		})
+		ffmpeg.SetLogging(o.debug, func(text string) {
+			o.logger.Debug(strings.TrimSpace(text), "source", "ffmpeg")
+		})
+	} else if o.logfn != nil {
 		whisper.Whisper_log_set(func(level whisper.LogLevel, text string) {
 			if !o.debug && level > whisper.LogLevelError {
 				return
@@ -162,6 +196,12 @@ func (w *Whisper) DeleteModelById(id string) error {
 	return nil
 }
 
+// Register a model which is loaded from a source rather than the models
+// directory, such as a model embedded in the binary or held in blob storage
+func (w *Whisper) RegisterModel(id string, source schema.Source) (*schema.Model, error) {
+	return w.store.Register(id, source)
+}
+
 // Download a model by path, where the directory is the root of the model
 // within the models directory. The model is returned immediately if it
 // already exists in the store
@@ -173,20 +213,108 @@ func (w *Whisper) DownloadModel(ctx context.Context, path string, fn func(curByt
 // return an existing one. The context can then be used to run the Transcribe
 // function, and after the context is returned to the pool.
 func (w *Whisper) WithModel(model *schema.Model, fn func(task *task.Context) error) error {
+	return w.WithModelContext(context.Background(), model, fn)
+}
+
+// Get a task for the specified model, like WithModel. When tracing is
+// enabled, spans are recorded for acquiring the context and loading the
+// model, as children of the span in the context
+func (w *Whisper) WithModelContext(ctx context.Context, model *schema.Model, fn func(task *task.Context) error) error {
 	if model == nil || fn == nil {
 		return ErrBadParameter
 	}
 
 	// Get a context from the pool
+	ctx, span := tracing.Start(tracing.WithTracer(ctx, w.tracer), "pool.acquire", tracing.String("model", model.Id))
 	task, err := w.pool.Get(model)
 	if err != nil {
+		span.End(err)
 		return err
 	}
 	defer w.pool.Put(task)
 
+	// The model is loaded at the end of acquiring the context
+	if load := time.Duration(task.Timings().Load); load > 0 {
+		end := time.Now()
+		tracing.Record(ctx, "model.load", end.Add(-load), end,
+			tracing.String("model", model.Id),
+			tracing.Duration("duration", load),
+		)
+	}
+	span.End(nil)
+
+	// Record spans for each call to whisper.cpp
+	task.SetTracer(w.tracer)
+
 	// Copy parameters
 	task.CopyParams()
 
 	// Execute the function
 	return fn(task)
 }
+
+// Return the maximum number of contexts which can be used at once, beyond
+// which WithModel returns an error rather than waiting for a context
+func (w *Whisper) MaxConcurrent() int {
+	return w.pool.Max()
+}
+
+// Return the structured logger, or nil if none was set
+func (w *Whisper) Logger() *slog.Logger {
+	return w.logger
+}
+
+// Return the tracer, or nil if tracing is not enabled
+func (w *Whisper) Tracer() *tracing.Tracer {
+	return w.tracer
+}
+
+// Return the metrics collector, for recording requests
+func (w *Whisper) Metrics() *metrics.Metrics {
+	return w.metrics
+}
+
+// Write the metrics in the Prometheus text exposition format, including
+// the occupancy of the context pool
+func (w *Whisper) WriteMetrics(dest io.Writer) error {
+	return w.metrics.Write(dest,
+		metrics.Gauge{Name: "whisper_pool_contexts_in_use", Help: "Number of contexts in use", Value: float64(w.pool.N())},
+		metrics.Gauge{Name: "whisper_pool_contexts_max", Help: "Maximum number of contexts", Value: float64(w.pool.Max())},
+		metrics.Gauge{Name: "whisper_pool_models_loaded", Help: "Number of models loaded", Value: float64(w.pool.Loaded())},
+	)
+}
+
+// Return true if a text translation backend is available, for translation
+// to languages other than English
+func (w *Whisper) CanTranslateText() bool {
+	return w.translator != nil
+}
+
+// Translate the text of segments in place from the source language to the
+// target language, using the text translation backend. The timing of the
+// segments is preserved
+func (w *Whisper) TranslateSegments(ctx context.Context, source, target string, segments ...*schema.Segment) error {
+	return translate.Segments(ctx, w.translator, source, target, segments...)
+}
+
+// Translate a transcription in place to the target language, using the text
+// translation backend. The timing of the segments is preserved
+func (w *Whisper) TranslateTranscription(ctx context.Context, target string, transcription *schema.Transcription) error {
+	return translate.Transcription(ctx, w.translator, target, transcription)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Map whisper.cpp log levels to structured log levels. Informational
+// messages from whisper.cpp are verbose, so are logged at debug level
+func logLevel(level whisper.LogLevel) slog.Level {
+	switch level {
+	case whisper.LogLevelError:
+		return slog.LevelError
+	case whisper.LogLevelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelDebug
+	}
+}
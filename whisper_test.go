@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"os"
+	"slices"
 	"testing"
+	"time"
 
 	// Packages
 	wav "github.com/go-audio/wav"
@@ -339,6 +341,45 @@ func Test_whisper_006(t *testing.T) {
 //////////////////////////////////////////////////////////////////////////////
 
 // Return samples as []float32
+func Test_whisper_007(t *testing.T) {
+	assert := assert.New(t)
+	service, err := whisper.New(t.TempDir(), whisper.OptMaxConcurrent(1))
+	if !assert.Nil(err) {
+		t.SkipNow()
+	}
+	defer service.Close()
+	model, err := service.DownloadModel(context.Background(), MODEL_TINY, nil)
+	if err != nil {
+		t.Skip("model not downloaded:", err)
+	}
+	samples, err := LoadSamples(SAMPLE_EN)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Progress is reported across both halves of the samples, and does not
+	// go backwards
+	half := len(samples) / 2
+	total := time.Duration(len(samples)) * time.Second / whisper.SampleRate
+	var progress []float64
+	assert.NoError(service.WithModel(model, func(task *task.Context) error {
+		task.SetProgress(total, func(v float64) {
+			progress = append(progress, v)
+		})
+		if err := task.Transcribe(context.Background(), 0, samples[:half], nil); err != nil {
+			return err
+		}
+		if assert.NotEmpty(progress) {
+			assert.InDelta(0.5, progress[len(progress)-1], 0.05)
+		}
+		return task.Transcribe(context.Background(), total/2, samples[half:], nil)
+	}))
+	if assert.NotEmpty(progress) {
+		assert.True(slices.IsSorted(progress), progress)
+		assert.InDelta(1.0, progress[len(progress)-1], 0.05)
+	}
+}
+
 func LoadSamples(path string) ([]float32, error) {
 	fh, err := os.Open(path)
 	if err != nil {